@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"wallet/config"
+	"wallet/service"
+)
+
+// runCommand 执行维护命令，返回进程退出码：0 成功，1 发现问题，2 参数或执行错误
+func runCommand(name string, args []string) int {
+	switch name {
+	case "reconcile":
+		return reconcileCommand(args)
+	case "verify-chain":
+		return verifyChainCommand()
+	case "replay":
+		return replayCommand(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		fmt.Fprintln(os.Stderr, "usage: wallet [config | reconcile [-freeze] | verify-chain | replay [-wallet N] [-from-scratch] [-dry-run]]")
+		return 2
+	}
+}
+
+// configCommand 以 YAML 输出合并配置文件、环境变量和命令行参数后生效的配置，隐藏密码等敏感字段
+func configCommand() int {
+	out, err := config.GetConf().Dump()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to dump config: %v\n", err)
+		return 2
+	}
+	os.Stdout.Write(out)
+	return 0
+}
+
+// reconcileCommand 按交易记录重新计算所有余额并输出差异报告
+func reconcileCommand(args []string) int {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	freeze := flags.Bool("freeze", false, "freeze wallets with discrepancies")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	report, err := service.NewReconciliationService().Run("command", *freeze)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "reconciliation failed: %v\n", err)
+		return 2
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return 2
+	}
+
+	if report.Run.Discrepancies > 0 {
+		return 1
+	}
+	return 0
+}
+
+// verifyChainCommand 校验交易哈希链，输出第一个断开的位置
+func verifyChainCommand() int {
+	report, err := service.NewChainService().Verify()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chain verification failed: %v\n", err)
+		return 2
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return 2
+	}
+
+	if !report.OK {
+		return 1
+	}
+	return 0
+}
+
+// replayCommand 按事件流重放钱包，修正与事件不一致的余额、透支额度和冻结状态
+func replayCommand(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	walletID := flags.Uint("wallet", 0, "replay only this wallet")
+	fromScratch := flags.Bool("from-scratch", false, "ignore snapshots and rebuild wallet entries")
+	dryRun := flags.Bool("dry-run", false, "report mismatches without fixing them")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	report, err := service.NewReplayService().Rebuild(service.ReplayOptions{
+		WalletID:    *walletID,
+		FromScratch: *fromScratch,
+		DryRun:      *dryRun,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "replay failed: %v\n", err)
+		return 2
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return 2
+	}
+
+	if len(report.Mismatches) > 0 || len(report.Failures) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -1,15 +1,27 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Http
 type Http struct {
-	Port int `yaml:"port"`
+	Port            int           `yaml:"port"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`     // 读取整个请求（含请求体）的超时
+	WriteTimeout    time.Duration `yaml:"write_timeout"`    // 写响应的超时；实时推送连接按每次写入计算
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`     // keep-alive 空闲连接的超时
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // 停机时等待进行中的请求和后台任务的最长时间
+	TrustedProxies  []string      `yaml:"trusted_proxies"`  // 可信反向代理的 IP 或 CIDR，只有来自这些地址的 X-Forwarded-For 才用于确定客户端IP，为空则不信任任何代理
 	// Domain   string `yaml:"domain"`
 	// Protocol string `yaml:"protocol"`
 }
@@ -20,58 +32,287 @@ type MySQL struct {
 	Port     int    `yaml:"port"`
 	DBName   string `yaml:"db_name"`
 	User     string `yaml:"user"`
-	Password string `yaml:"password"`
-	// MaxIdleConns    int    `yaml:"max_idle_conns"`
-	// MaxOpenConns    int    `yaml:"max_open_conns"`
-	// ConnMaxLefeTime int    `yaml:"conn_max_lefe_time"`
-	Charset string `yaml:"charset"`
+	Password string `yaml:"password" secret:"true"`
+	Charset  string `yaml:"charset"`
+
+	// 连接池，只读副本使用相同的设置
+	MaxIdleConns    int           `yaml:"max_idle_conns"`     // 空闲连接池中连接的最大数量
+	MaxOpenConns    int           `yaml:"max_open_conns"`     // 打开数据库连接的最大数量
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`  // 连接可复用的最大时间
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"` // 连接空闲的最长时间，0 表示不限
+
+	// 超时，0 表示不限
+	ConnectTimeout time.Duration `yaml:"connect_timeout"` // 建立连接的超时
+	ReadTimeout    time.Duration `yaml:"read_timeout"`    // 读取结果的 I/O 超时
+	WriteTimeout   time.Duration `yaml:"write_timeout"`   // 发送请求的 I/O 超时
+	QueryTimeout   time.Duration `yaml:"query_timeout"`   // 单条 SQL 的执行超时，调用方的 context 期限更早时以其为准
+
+	Replicas             []MySQLReplica `yaml:"replicas"`               // 只读副本，用于只读查询，不配置时全部使用主库
+	ReplicaCheckInterval time.Duration  `yaml:"replica_check_interval"` // 检查只读副本是否可用的间隔
+}
+
+// MySQLReplica 只读副本，未设置的字段使用主库的值
+type MySQLReplica struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password" secret:"true"`
+}
+
+// LogFileConf
+type LogFileConf struct {
+	Path        string        `yaml:"path"`         // 当前日志文件，轮转出的文件在同一目录，文件名带时间戳
+	MaxSizeMB   int           `yaml:"max_size_mb"`  // 文件超过该大小时轮转
+	RotateEvery time.Duration `yaml:"rotate_every"` // 按时间轮转的间隔，如 24h 为每天零点（本地时间），0 表示只按大小轮转
+	MaxAgeDays  int           `yaml:"max_age_days"` // 轮转出的文件保留天数，0 表示不按时间删除
+	MaxBackups  int           `yaml:"max_backups"`  // 最多保留的轮转文件数，0 表示不按个数删除
+	Compress    bool          `yaml:"compress"`     // gzip 压缩轮转出的文件
 }
 
 // LogConf
 type LogConf struct {
-	Level string `yaml:"level"`
+	Level       string        `yaml:"level"`                         // debug, info, warn, error，可通过管理接口在运行时修改
+	Format      string        `yaml:"format" reload:"restart"`       // json, text
+	ServiceName string        `yaml:"service_name" reload:"restart"` // 每行日志的 service 字段
+	Outputs     []string      `yaml:"outputs" reload:"restart"`      // stdout, file
+	File        LogFileConf   `yaml:"file" reload:"restart"`
+	SlowSQL     time.Duration `yaml:"slow_sql" reload:"restart"` // 超过该耗时的 SQL 以 warn 级别记录
+}
+
+// WalletConf
+type WalletConf struct {
+	DefaultCurrency    string   `yaml:"default_currency" reload:"restart"` // 注册时创建的钱包币种，口袋、共享钱包等按该币种查找钱包
+	Currencies         []string `yaml:"currencies" reload:"restart"`       // 支持的币种
+	HouseUserID        int      `yaml:"house_user_id" reload:"restart"`    // 收取利息等内部款项的平台用户，0 表示不入账
+	OverdraftDailyRate float64  `yaml:"overdraft_daily_rate"`              // 透支日利率，如 0.0005
+}
+
+// InterestTier
+type InterestTier struct {
+	MinBalance float64 `yaml:"min_balance"`
+	AnnualRate float64 `yaml:"annual_rate"`
+}
+
+// InterestConf
+type InterestConf struct {
+	Enabled    bool                      `yaml:"enabled"`
+	Tiers      []InterestTier            `yaml:"tiers"`      // 按日终余额匹配 min_balance 最高的档位
+	Currencies map[string][]InterestTier `yaml:"currencies"` // 按币种覆盖 tiers
+}
+
+// FXConf
+type FXConf struct {
+	Base      string             `yaml:"base"`       // rates 的基准币种
+	Rates     map[string]float64 `yaml:"rates"`      // 1 单位基准币种可兑换的数量
+	RatesFile string             `yaml:"rates_file"` // 可选，YAML 格式的汇率文件，覆盖 base 和 rates
+	Spread    float64            `yaml:"spread"`     // 报价点差，如 0.005 表示 0.5%
+	QuoteTTL  time.Duration      `yaml:"quote_ttl"`  // 报价有效期
+}
+
+// AuthConf
+type AuthConf struct {
+	AdminToken   string `yaml:"admin_token" secret:"true"`   // 请求头 X-Admin-Token 与之相同时具有管理员权限，为空则禁用
+	GatewayToken string `yaml:"gateway_token" secret:"true"` // API 网关认证用户后随 X-User-ID 发送的 X-Gateway-Token，与之相同时信任该用户ID，为空则禁用
+}
+
+// StatementConf
+type StatementConf struct {
+	Dir            string        `yaml:"dir"`                            // 异步生成的对账单文件目录
+	AsyncThreshold int64         `yaml:"async_threshold"`                // 交易笔数超过该值时异步生成
+	LinkTTL        time.Duration `yaml:"link_ttl"`                       // 下载链接有效期
+	PollInterval   time.Duration `yaml:"poll_interval" reload:"restart"` // 后台生成排队对账单的间隔
+}
+
+// ReconciliationConf
+type ReconciliationConf struct {
+	Interval time.Duration `yaml:"interval" reload:"restart"` // 定时对账间隔，0 表示默认 24h
+	Freeze   bool          `yaml:"freeze"`                    // 定时对账发现差异时冻结相关钱包
+}
+
+// OutboxSinkConf
+type OutboxSinkConf struct {
+	Type    string        `yaml:"type"`    // log, http
+	URL     string        `yaml:"url"`     // http 类型的接收地址
+	Timeout time.Duration `yaml:"timeout"` // http 请求超时
+}
+
+// OutboxConf
+type OutboxConf struct {
+	PollInterval time.Duration    `yaml:"poll_interval"` // 发布间隔
+	BatchSize    int              `yaml:"batch_size"`    // 每批发布的事件数
+	MaxBackoff   time.Duration    `yaml:"max_backoff"`   // 发布失败重试的最大间隔
+	Sinks        []OutboxSinkConf `yaml:"sinks"`
+}
+
+// WebhookConf
+type WebhookConf struct {
+	PollInterval         time.Duration `yaml:"poll_interval" reload:"restart"`          // 投递间隔
+	BatchSize            int           `yaml:"batch_size"`                              // 每批投递数
+	Timeout              time.Duration `yaml:"timeout" reload:"restart"`                // 回调请求超时
+	MaxAttempts          int           `yaml:"max_attempts"`                            // 超过后进入死信
+	InitialBackoff       time.Duration `yaml:"initial_backoff"`                         // 首次重试间隔，之后每次翻倍
+	MaxBackoff           time.Duration `yaml:"max_backoff"`                             // 最大重试间隔
+	AllowPrivateNetworks bool          `yaml:"allow_private_networks" reload:"restart"` // 允许回调到回环、内网及链路本地地址，仅用于本地开发和测试
+}
+
+// StreamConf
+type StreamConf struct {
+	PollInterval time.Duration `yaml:"poll_interval" reload:"restart"` // 读取新事件的间隔
+	Heartbeat    time.Duration `yaml:"heartbeat"`                      // 心跳间隔，防止代理断开空闲连接
+	MaxReplay    int           `yaml:"max_replay" reload:"restart"`    // 重连时最多补发的事件数，超过则要求客户端重新拉取
+	GapTimeout   time.Duration `yaml:"gap_timeout" reload:"restart"`   // 等待未提交事件的最长时间
+	BufferSize   int           `yaml:"buffer_size" reload:"restart"`   // 每个连接的缓冲，写满时断开慢连接
+}
+
+// EventStoreConf
+type EventStoreConf struct {
+	SnapshotEvery uint `yaml:"snapshot_every"` // 钱包每追加多少个事件保存一次快照
 }
 
+// ChainConf
+type ChainConf struct {
+	PollInterval time.Duration `yaml:"poll_interval" reload:"restart"` // 把已提交的交易追加到哈希链的间隔
+	BatchSize    int           `yaml:"batch_size"`                     // 每批追加的交易数
+}
+
+// HealthConf
+type HealthConf struct {
+	Timeout       time.Duration `yaml:"timeout"`        // 每项就绪检查的超时
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"` // 停机时 /readyz 先返回 503 的时长，等负载均衡摘除实例后再停止接收请求
+}
+
+// ReloadConf
+type ReloadConf struct {
+	Watch    bool          `yaml:"watch"`    // 监视配置文件，内容变化时热加载
+	Interval time.Duration `yaml:"interval"` // 检查配置文件的间隔
+}
+
+// RateLimitConf 限流，每个请求计入客户端IP 的令牌桶，带 API key、用户ID（X-User-ID）时同时计入各自的令牌桶
+type RateLimitConf struct {
+	Enabled bool          `yaml:"enabled"`
+	Read    RateLimitRule `yaml:"read"`  // 所有 /api/v1 接口
+	Money   RateLimitRule `yaml:"money"` // 资金变动接口另外的限制：存取款、转账、换汇、口袋及共享钱包的资金划转
+}
+
+// RateLimitRule 令牌桶：每秒补充 rate 个令牌，最多积累 burst 个，每个请求消耗一个
+type RateLimitRule struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// TracingConf
+type TracingConf struct {
+	Exporter    string  `yaml:"exporter"`     // none, stdout, otlp
+	Endpoint    string  `yaml:"endpoint"`     // otlp 类型的 OTLP/HTTP 接收地址，如 localhost:4318
+	Insecure    bool    `yaml:"insecure"`     // otlp 使用 http 而非 https
+	ServiceName string  `yaml:"service_name"` // 上报的 service.name
+	SampleRatio float64 `yaml:"sample_ratio"` // 新链路的采样比例 (0, 1]，上游已采样的请求始终记录
+}
+
+// Config 配置。带 reload:"restart" 标签的字段（或其所在的部分）在启动时使用，
+// 修改后需要重启才能生效，热加载时保持原值；其余字段在使用时读取，热加载后立即生效
 type Config struct {
-	Http  Http    `yaml:"http"`
-	MySQL MySQL   `yaml:"mysql"`
-	Log   LogConf `yaml:"log"`
+	Http           Http               `yaml:"http" reload:"restart"`
+	MySQL          MySQL              `yaml:"mysql" reload:"restart"`
+	Log            LogConf            `yaml:"log"`
+	Wallet         WalletConf         `yaml:"wallet"`
+	Interest       InterestConf       `yaml:"interest"`
+	FX             FXConf             `yaml:"fx"`
+	Auth           AuthConf           `yaml:"auth"`
+	Statement      StatementConf      `yaml:"statement"`
+	Reconciliation ReconciliationConf `yaml:"reconciliation"`
+	Outbox         OutboxConf         `yaml:"outbox" reload:"restart"`
+	Webhook        WebhookConf        `yaml:"webhook"`
+	Stream         StreamConf         `yaml:"stream"`
+	EventStore     EventStoreConf     `yaml:"event_store"`
+	Chain          ChainConf          `yaml:"chain"`
+	Health         HealthConf         `yaml:"health"`
+	RateLimit      RateLimitConf      `yaml:"rate_limit"`
+	Tracing        TracingConf        `yaml:"tracing" reload:"restart"`
+	Reload         ReloadConf         `yaml:"reload" reload:"restart"`
 }
 
-var conf *Config
+var (
+	// conf 当前生效的配置，热加载时整体替换
+	conf atomic.Pointer[Config]
+	// loadMu 串行执行配置的加载和热加载
+	loadMu sync.Mutex
+	// configFile 加载的配置文件路径，未使用配置文件时为空
+	configFile string
+)
 
+// GetConf 返回当前生效的配置。返回值由多个 goroutine 共享，不能修改；
+// 热加载后再次调用得到新的配置
 func GetConf() *Config {
-	if conf == nil {
+	if c := conf.Load(); c != nil {
+		return c
+	}
 
-		if err := InitConfig(); err != nil {
-			fmt.Printf("Warning: Failed to initialize config: %v\n", err)
-		}
+	if err := InitConfig(); err != nil {
+		fmt.Printf("Warning: Failed to initialize config: %v\n", err)
+		conf.CompareAndSwap(nil, &Config{})
 	}
-	return conf
+	return conf.Load()
 }
 
-// InitConfig
+// InitConfig 加载配置：配置文件由 -config 参数或 CONFIG_PATH 指定，再用环境变量和命令行参数覆盖
 func InitConfig() error {
-	configPath := os.Getenv("CONFIG_PATH")
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	loaded, path, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	storeConfig(loaded, path)
+	return nil
+}
 
+// storeConfig 替换当前配置，记录配置文件及其内容摘要供热加载比较
+func storeConfig(loaded *Config, path string) {
+	conf.Store(loaded)
+	configFile = path
+	watchedSum = nil
+	if path != "" {
+		watchedSum, _ = fileSum(path)
+	}
+}
+
+// loadConfig 读取配置文件并应用覆盖和校验，返回配置及使用的配置文件路径
+func loadConfig() (*Config, string, error) {
+	configPath := configPathFlag
 	if configPath == "" {
+		configPath = os.Getenv("CONFIG_PATH")
+	}
+	explicitPath := configPath != ""
+	if !explicitPath {
 		configPath = "./config/config.yaml"
 	}
 
-	conf = &Config{}
+	loaded := &Config{}
 
-	fmt.Printf("Loading configuration from: %s\n", configPath)
-	err := getYamlConf(configPath, conf)
+	// 输出到标准错误，不混入 wallet config 的输出
+	fmt.Fprintf(os.Stderr, "Loading configuration from: %s\n", configPath)
+	err := getYamlConf(configPath, loaded)
+	// 未指定配置文件且默认文件不存在时，只使用环境变量和命令行参数
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		if explicitPath || !errors.Is(err, os.ErrNotExist) {
+			return nil, "", fmt.Errorf("failed to load configuration: %w", err)
+		}
+		configPath = ""
+	}
+
+	// 环境变量和命令行参数
+	if err := applyOverrides(loaded); err != nil {
+		return nil, "", fmt.Errorf("invalid configuration override: %w", err)
 	}
 
 	// 配置校验
-	if err := validateConfig(conf); err != nil {
-		return fmt.Errorf("invalid configuration: %w", err)
+	if err := validateConfig(loaded); err != nil {
+		return nil, "", fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	return nil
+	return loaded, configPath, nil
 }
 
 // getYamlConf
@@ -100,8 +341,231 @@ func validateConfig(config *Config) error {
 	if config.MySQL.User == "" {
 		return fmt.Errorf("MySQL username is required")
 	}
+	if config.MySQL.MaxIdleConns <= 0 {
+		config.MySQL.MaxIdleConns = 10
+	}
+	if config.MySQL.MaxOpenConns <= 0 {
+		config.MySQL.MaxOpenConns = 100
+	}
+	if config.MySQL.MaxIdleConns > config.MySQL.MaxOpenConns {
+		return fmt.Errorf("MySQL max idle connections must not exceed max open connections")
+	}
+	if config.MySQL.ConnMaxLifetime <= 0 {
+		config.MySQL.ConnMaxLifetime = time.Hour
+	}
+	if config.MySQL.ConnMaxIdleTime < 0 || config.MySQL.ConnectTimeout < 0 || config.MySQL.ReadTimeout < 0 ||
+		config.MySQL.WriteTimeout < 0 || config.MySQL.QueryTimeout < 0 {
+		return fmt.Errorf("MySQL timeouts must not be negative")
+	}
+	for i := range config.MySQL.Replicas {
+		replica := &config.MySQL.Replicas[i]
+		if replica.Host == "" {
+			return fmt.Errorf("MySQL replica host is required")
+		}
+		if replica.Port == 0 {
+			replica.Port = config.MySQL.Port
+		}
+		if replica.User == "" {
+			replica.User = config.MySQL.User
+			replica.Password = config.MySQL.Password
+		}
+	}
+	if config.MySQL.ReplicaCheckInterval <= 0 {
+		config.MySQL.ReplicaCheckInterval = 10 * time.Second
+	}
+	if config.Log.Level == "" {
+		config.Log.Level = LevelInfo
+	}
+	if _, ok := levelMap[config.Log.Level]; !ok {
+		return fmt.Errorf("unknown log level %q", config.Log.Level)
+	}
+	if config.Log.Format == "" {
+		config.Log.Format = "json"
+	}
+	if config.Log.Format != "json" && config.Log.Format != "text" {
+		return fmt.Errorf("unknown log format %q", config.Log.Format)
+	}
+	if config.Log.ServiceName == "" {
+		config.Log.ServiceName = "wallet"
+	}
+	if len(config.Log.Outputs) == 0 {
+		config.Log.Outputs = []string{"stdout", "file"}
+	}
+	for _, output := range config.Log.Outputs {
+		if output != "stdout" && output != "file" {
+			return fmt.Errorf("unknown log output %q", output)
+		}
+	}
+	if config.Log.File.Path == "" {
+		config.Log.File.Path = "log/app.log"
+	}
+	if config.Log.File.MaxSizeMB <= 0 {
+		config.Log.File.MaxSizeMB = 100
+	}
+	if config.Log.File.RotateEvery < 0 || config.Log.File.MaxAgeDays < 0 || config.Log.File.MaxBackups < 0 {
+		return fmt.Errorf("log file rotation settings must not be negative")
+	}
+	if config.Log.SlowSQL <= 0 {
+		config.Log.SlowSQL = time.Second
+	}
+	for _, proxy := range config.Http.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+		}
+	}
 	if config.Http.Port == 0 {
 		config.Http.Port = 8090 // 设置默认端口
 	}
+	if config.Http.ReadTimeout <= 0 {
+		config.Http.ReadTimeout = 15 * time.Second
+	}
+	if config.Http.WriteTimeout <= 0 {
+		config.Http.WriteTimeout = 30 * time.Second
+	}
+	if config.Http.IdleTimeout <= 0 {
+		config.Http.IdleTimeout = 60 * time.Second
+	}
+	if config.Http.ShutdownTimeout <= 0 {
+		config.Http.ShutdownTimeout = 30 * time.Second
+	}
+	if config.Wallet.DefaultCurrency == "" {
+		config.Wallet.DefaultCurrency = "USD"
+	}
+	config.Wallet.DefaultCurrency = strings.ToUpper(config.Wallet.DefaultCurrency)
+	for i, currency := range config.Wallet.Currencies {
+		config.Wallet.Currencies[i] = strings.ToUpper(currency)
+	}
+	if !slices.Contains(config.Wallet.Currencies, config.Wallet.DefaultCurrency) {
+		config.Wallet.Currencies = append(config.Wallet.Currencies, config.Wallet.DefaultCurrency)
+	}
+	if config.FX.Spread < 0 || config.FX.Spread >= 1 {
+		return fmt.Errorf("fx spread must be in [0, 1)")
+	}
+	if config.FX.QuoteTTL == 0 {
+		config.FX.QuoteTTL = 30 * time.Second
+	}
+	if config.Statement.Dir == "" {
+		config.Statement.Dir = "./statements"
+	}
+	if config.Statement.AsyncThreshold <= 0 {
+		config.Statement.AsyncThreshold = 1000
+	}
+	if config.Statement.LinkTTL == 0 {
+		config.Statement.LinkTTL = 24 * time.Hour
+	}
+	if config.Statement.PollInterval <= 0 {
+		config.Statement.PollInterval = 10 * time.Second
+	}
+	if config.Reconciliation.Interval <= 0 {
+		config.Reconciliation.Interval = 24 * time.Hour
+	}
+	if config.Chain.PollInterval <= 0 {
+		config.Chain.PollInterval = time.Second
+	}
+	if config.Chain.BatchSize <= 0 {
+		config.Chain.BatchSize = 500
+	}
+	if config.Outbox.PollInterval <= 0 {
+		config.Outbox.PollInterval = time.Second
+	}
+	if config.Outbox.BatchSize <= 0 {
+		config.Outbox.BatchSize = 100
+	}
+	if config.Outbox.MaxBackoff <= 0 {
+		config.Outbox.MaxBackoff = 5 * time.Minute
+	}
+	for i := range config.Outbox.Sinks {
+		if config.Outbox.Sinks[i].Timeout <= 0 {
+			config.Outbox.Sinks[i].Timeout = 5 * time.Second
+		}
+	}
+	if config.Webhook.PollInterval <= 0 {
+		config.Webhook.PollInterval = 5 * time.Second
+	}
+	if config.Webhook.BatchSize <= 0 {
+		config.Webhook.BatchSize = 50
+	}
+	if config.Webhook.Timeout <= 0 {
+		config.Webhook.Timeout = 10 * time.Second
+	}
+	if config.Webhook.MaxAttempts <= 0 {
+		config.Webhook.MaxAttempts = 8
+	}
+	if config.Webhook.InitialBackoff <= 0 {
+		config.Webhook.InitialBackoff = 10 * time.Second
+	}
+	if config.Webhook.MaxBackoff <= 0 {
+		config.Webhook.MaxBackoff = time.Hour
+	}
+	if config.Stream.PollInterval <= 0 {
+		config.Stream.PollInterval = 500 * time.Millisecond
+	}
+	if config.Stream.Heartbeat <= 0 {
+		config.Stream.Heartbeat = 15 * time.Second
+	}
+	if config.Stream.MaxReplay <= 0 {
+		config.Stream.MaxReplay = 1000
+	}
+	if config.Stream.GapTimeout <= 0 {
+		config.Stream.GapTimeout = 10 * time.Second
+	}
+	if config.Stream.BufferSize <= 0 {
+		config.Stream.BufferSize = 64
+	}
+	if config.Health.Timeout <= 0 {
+		config.Health.Timeout = 2 * time.Second
+	}
+	if config.Health.ShutdownDelay < 0 {
+		return fmt.Errorf("health shutdown delay must not be negative")
+	}
+	if config.Tracing.Exporter == "" {
+		config.Tracing.Exporter = "none"
+	}
+	switch config.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		return fmt.Errorf("unknown tracing exporter %q", config.Tracing.Exporter)
+	}
+	if config.Tracing.ServiceName == "" {
+		config.Tracing.ServiceName = "wallet"
+	}
+	if config.Tracing.SampleRatio <= 0 || config.Tracing.SampleRatio > 1 {
+		config.Tracing.SampleRatio = 1
+	}
+	if config.RateLimit.Read.Rate <= 0 {
+		config.RateLimit.Read.Rate = 20
+	}
+	if config.RateLimit.Read.Burst <= 0 {
+		config.RateLimit.Read.Burst = 100
+	}
+	if config.RateLimit.Money.Rate <= 0 {
+		config.RateLimit.Money.Rate = 2
+	}
+	if config.RateLimit.Money.Burst <= 0 {
+		config.RateLimit.Money.Burst = 30
+	}
+	if config.Reload.Interval <= 0 {
+		config.Reload.Interval = 5 * time.Second
+	}
+	if config.EventStore.SnapshotEvery == 0 {
+		config.EventStore.SnapshotEvery = 100
+	}
+	if config.Wallet.OverdraftDailyRate < 0 {
+		return fmt.Errorf("wallet overdraft daily rate must not be negative")
+	}
+	for _, tier := range config.Interest.Tiers {
+		if tier.AnnualRate < 0 || tier.MinBalance < 0 {
+			return fmt.Errorf("interest tiers must not be negative")
+		}
+	}
+	for _, tiers := range config.Interest.Currencies {
+		for _, tier := range tiers {
+			if tier.AnnualRate < 0 || tier.MinBalance < 0 {
+				return fmt.Errorf("interest tiers must not be negative")
+			}
+		}
+	}
 	return nil
 }
@@ -1,56 +1,90 @@
 package config
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os"
-	"time"
 
 	"wallet/models"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
 func InitDB(config *Config) (*gorm.DB, error) {
-	// 构建DSN (数据源名称)
+	db, err := openDB(config, config.MySQL.Host, config.MySQL.Port, config.MySQL.User, config.MySQL.Password, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect database: %w", err)
+	}
+
+	log.Println("Database connection established successfully")
+
+	// 设置全局DB变量
+	DB = db
+
+	// 自动迁移表结构
+	if err := db.AutoMigrate(migrateModels()...); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	// 钱包改为按币种区分后，移除旧的 user_id 唯一索引
+	if db.Migrator().HasIndex(&models.Wallets{}, "idx_wallets_user_id") {
+		if err := db.Migrator().DropIndex(&models.Wallets{}, "idx_wallets_user_id"); err != nil {
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+
+	// 交易哈希链的链头，只有一行
+	if err := db.FirstOrCreate(&models.ChainHead{ID: models.ChainHeadID}).Error; err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	// 事件发布锁，只有一行
+	if err := db.FirstOrCreate(&models.OutboxRelayLock{ID: 1}).Error; err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	log.Println("Database migration completed successfully")
+
+	// 迁移可能较慢，之后才限制单条 SQL 的执行时间
+	if config.MySQL.QueryTimeout > 0 {
+		if err := db.Use(QueryTimeout(config.MySQL.QueryTimeout)); err != nil {
+			return nil, err
+		}
+	}
+
+	// 只读副本
+	if err := initReplicas(config); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// openDB 按 MySQL 配置连接一个实例并设置连接池；ping 为 false 时不检查连通性
+func openDB(config *Config, host string, port int, user, password string, ping bool) (*gorm.DB, error) {
+	// 构建DSN (数据源名称)，超时为 0 时不限
 	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=True&loc=Local",
-		config.MySQL.User,
-		config.MySQL.Password,
-		config.MySQL.Host,
-		config.MySQL.Port,
+		"%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=True&loc=Local&timeout=%s&readTimeout=%s&writeTimeout=%s",
+		user,
+		password,
+		host,
+		port,
 		config.MySQL.DBName,
 		config.MySQL.Charset,
+		config.MySQL.ConnectTimeout,
+		config.MySQL.ReadTimeout,
+		config.MySQL.WriteTimeout,
 	)
-	// 配置GORM日志级别
-	logLevel := logger.Info
-	if config.Log.Level == "debug" {
-		logLevel = logger.Info
-	} else if config.Log.Level == "warn" {
-		logLevel = logger.Warn
-	} else if config.Log.Level == "error" {
-		logLevel = logger.Error
-	}
-
-	newLogger := logger.New(
-		log.New(os.Stdout, "\r\n", log.LstdFlags), // 输出到标准输出
-		logger.Config{
-			SlowThreshold:             time.Second, // 慢SQL阈值
-			LogLevel:                  logLevel,    // 日志级别
-			IgnoreRecordNotFoundError: true,        // 忽略记录不存在错误
-			Colorful:                  true,        // 彩色输出
-		},
-	)
-
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: newLogger,
+		// SQL 写入应用日志：debug 级别输出每条 SQL，慢 SQL 为 warn，出错为 error
+		Logger:               NewGormLogger(config.Log.SlowSQL),
+		DisableAutomaticPing: !ping,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect database: %w", err)
+		return nil, err
 	}
 
 	sqlDB, err := db.DB()
@@ -59,24 +93,95 @@ func InitDB(config *Config) (*gorm.DB, error) {
 	}
 
 	// 设置连接池参数
-	sqlDB.SetMaxIdleConns(10)           // 设置空闲连接池中连接的最大数量
-	sqlDB.SetMaxOpenConns(100)          // 设置打开数据库连接的最大数量
-	sqlDB.SetConnMaxLifetime(time.Hour) // 设置连接可复用的最大时间
+	sqlDB.SetMaxIdleConns(config.MySQL.MaxIdleConns)       // 设置空闲连接池中连接的最大数量
+	sqlDB.SetMaxOpenConns(config.MySQL.MaxOpenConns)       // 设置打开数据库连接的最大数量
+	sqlDB.SetConnMaxLifetime(config.MySQL.ConnMaxLifetime) // 设置连接可复用的最大时间
+	sqlDB.SetConnMaxIdleTime(config.MySQL.ConnMaxIdleTime) // 设置连接空闲的最长时间
 
-	log.Println("Database connection established successfully")
+	return db, nil
+}
 
-	// 设置全局DB变量
-	DB = db
+// migrateModels 返回需要自动迁移的模型
+func migrateModels() []interface{} {
+	return []interface{}{
+		&models.Users{}, &models.Wallets{}, &models.Transaction{}, &models.Pockets{},
+		&models.SharedWallets{}, &models.SharedWalletMembers{}, &models.PendingOperations{}, &models.OperationApprovals{}, &models.OverdraftCharges{},
+		&models.InterestAccruals{}, &models.InterestPayouts{}, &models.FxQuotes{}, &models.FxExchanges{}, &models.TransactionStatusHistory{},
+		&models.StatementJobs{}, &models.BalanceSnapshots{}, &models.ReconciliationRuns{}, &models.ReconciliationDiscrepancies{},
+		&models.ChainHead{}, &models.OutboxEvents{}, &models.OutboxRelayLock{},
+		&models.ApiClients{}, &models.WebhookSubscriptions{}, &models.WebhookDeliveries{}, &models.WebhookAttempts{},
+		&models.WalletEvents{}, &models.WalletSnapshots{}, &models.WalletEntries{},
+	}
+}
 
-	// 自动迁移表结构
-	if err := db.AutoMigrate(&models.Users{}, &models.Wallets{}, &models.Transaction{}); err != nil {
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
+// PingDB 在 ctx 的期限内检查数据库是否可用
+func PingDB(ctx context.Context) error {
+	if DB == nil {
+		return errors.New("database not initialized")
 	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
 
-	log.Println("Database migration completed successfully")
-	return db, nil
+// PendingMigrations 对比模型与数据库中的表结构，返回缺少的列（表名.列名）；
+// 为空表示迁移已是最新
+func PendingMigrations(ctx context.Context) ([]string, error) {
+	if DB == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	var columns []struct {
+		TableName  string
+		ColumnName string
+	}
+	if err := DB.WithContext(ctx).Raw(
+		"SELECT table_name AS table_name, column_name AS column_name FROM information_schema.columns WHERE table_schema = DATABASE()",
+	).Scan(&columns).Error; err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		existing[column.TableName+"."+column.ColumnName] = true
+	}
+
+	missing := []string{}
+	for _, model := range migrateModels() {
+		stmt := &gorm.Statement{DB: DB}
+		if err := stmt.Parse(model); err != nil {
+			return nil, err
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			if name := stmt.Schema.Table + "." + field.DBName; !existing[name] {
+				missing = append(missing, name)
+			}
+		}
+	}
+
+	return missing, nil
 }
 
 func GetDB() *gorm.DB {
 	return DB
 }
+
+// CloseDB 关闭主库及只读副本的连接池，停机时在请求和后台任务都结束后调用
+func CloseDB() error {
+	if DB == nil {
+		return nil
+	}
+	replicaErr := closeReplicas()
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	if err := sqlDB.Close(); err != nil {
+		return err
+	}
+	return replicaErr
+}
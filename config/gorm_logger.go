@@ -0,0 +1,112 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// gormLogger 把 GORM 的日志写入全局日志实例：每条 SQL 为 debug 级别，慢 SQL 为 warn，
+// 执行出错为 error。是否输出由日志级别决定，运行时修改级别对 SQL 日志同样生效。
+type gormLogger struct {
+	slowThreshold time.Duration
+	level         logger.LogLevel
+}
+
+// NewGormLogger 创建 GORM 日志，slowThreshold 为慢 SQL 阈值
+func NewGormLogger(slowThreshold time.Duration) logger.Interface {
+	return &gormLogger{slowThreshold: slowThreshold, level: logger.Info}
+}
+
+// LogMode 实现 logger.Interface
+func (l *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	copied := *l
+	copied.level = level
+	return &copied
+}
+
+// Info 实现 logger.Interface
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Info {
+		l.print(ctx, slog.LevelInfo, fmt.Sprintf(msg, data...))
+	}
+}
+
+// Warn 实现 logger.Interface
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Warn {
+		l.print(ctx, slog.LevelWarn, fmt.Sprintf(msg, data...))
+	}
+}
+
+// Error 实现 logger.Interface
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Error {
+		l.print(ctx, slog.LevelError, fmt.Sprintf(msg, data...))
+	}
+}
+
+// Trace 实现 logger.Interface，记录一条 SQL 的耗时、影响行数及错误
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+
+	var level slog.Level
+	var msg string
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		level, msg = slog.LevelError, "sql failed"
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= logger.Warn:
+		level, msg = slog.LevelWarn, "slow sql"
+	case l.level >= logger.Info:
+		level, msg = slog.LevelDebug, "sql"
+	default:
+		return
+	}
+
+	target := LoggerFromContext(ctx)
+	if !target.slog.Enabled(target.context(), level) {
+		return
+	}
+	sql, rows := fc()
+	fields := []interface{}{"caller", caller(), "elapsed_ms", float64(elapsed.Microseconds()) / 1000, "rows", rows, "sql", sql}
+	if level == slog.LevelError {
+		fields = append(fields, "error", err.Error())
+	}
+	target.write(target.context(), level, msg, 0, fields)
+}
+
+// print 输出 GORM 的其他日志，如迁移信息
+func (l *gormLogger) print(ctx context.Context, level slog.Level, msg string) {
+	target := LoggerFromContext(ctx)
+	if target.slog.Enabled(target.context(), level) {
+		target.write(target.context(), level, msg, 0, []interface{}{"caller", caller()})
+	}
+}
+
+// caller 返回 GORM 之外发起查询的代码位置
+func caller() string {
+	var pcs [24]uintptr
+	// 跳过 runtime.Callers、caller 及调用它的 Trace 或 print
+	n := runtime.Callers(3, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.Contains(frame.File, "/gorm.io/") {
+			return fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
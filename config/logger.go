@@ -1,14 +1,19 @@
 package config
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // 日志级别
@@ -20,222 +25,382 @@ const (
 	LevelFatal = "fatal"
 )
 
-// 日志级别映射到整数，便于比较
-var levelMap = map[string]int{
-	LevelDebug: 0,
-	LevelInfo:  1,
-	LevelWarn:  2,
-	LevelError: 3,
-	LevelFatal: 4,
+// slogLevelFatal 记录后程序退出，高于 slog 的 error 级别
+const slogLevelFatal = slog.LevelError + 4
+
+// 日志级别映射到 slog 级别
+var levelMap = map[string]slog.Level{
+	LevelDebug: slog.LevelDebug,
+	LevelInfo:  slog.LevelInfo,
+	LevelWarn:  slog.LevelWarn,
+	LevelError: slog.LevelError,
+	LevelFatal: slogLevelFatal,
 }
 
-// Logger 日志管理器
+// Logger 日志管理器，基于 log/slog，派生实例共用级别
 type Logger struct {
-	level  int
-	output io.Writer
-	sync.Mutex
-	serviceName string
+	slog  *slog.Logger
+	level *slog.LevelVar
+	ctx   context.Context // WithContext 绑定的上下文，用于输出链路信息
 }
 
 var (
 	// 全局日志实例
 	globalLogger *Logger
-	once         sync.Once
+	// 日志文件，停机时关闭
+	logFile  io.Closer
+	once     sync.Once
+	loggerMu sync.RWMutex
 )
 
-// InitLogger 初始化日志管理器
+// InitLogger 按配置初始化全局日志实例
 func InitLogger() {
 	once.Do(func() {
-		// 从config包获取配置
-		conf := GetConf()
-		level := conf.Log.Level
-		if level == "" {
-			level = LevelInfo // 默认info级别
+		conf := GetConf().Log
+		logger, closer, err := NewLoggerFromConf(conf)
+		if err != nil {
+			fmt.Printf("Failed to open log file: %v\n", err)
+			// 打开文件失败时只输出到标准输出
+			conf.Outputs = []string{"stdout"}
+			logger, closer, _ = NewLoggerFromConf(conf)
 		}
 
-		// 创建日志目录
-		logDir := "log"
-		if _, err := os.Stat(logDir); os.IsNotExist(err) {
-			os.Mkdir(logDir, 0755)
-		}
+		loggerMu.Lock()
+		globalLogger = logger
+		logFile = closer
+		loggerMu.Unlock()
 
-		// 创建日志文件
-		fileName := filepath.Join(logDir, fmt.Sprintf("app-%s.log", time.Now().Format("2006-01-02")))
-		logFile, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			fmt.Printf("Failed to open log file: %v\n", err)
-			// 如果打开文件失败，使用标准输出
-			globalLogger = &Logger{
-				level:       levelMap[level],
-				output:      os.Stdout,
-				serviceName: "blog-service",
+		logger.Info("Logger initialized successfully", "log_level", conf.Level, "outputs", conf.Outputs)
+
+		// 热加载修改 log.level 时调整级别；通过管理接口设置的级别保持到配置文件修改该字段
+		Subscribe(func(prev, next *Config) {
+			if prev.Log.Level != next.Log.Level {
+				GetLogger().SetLevel(next.Log.Level)
+			}
+		})
+	})
+}
+
+// NewLoggerFromConf 按配置创建日志实例，返回的 io.Closer 关闭日志文件，可能为 nil
+func NewLoggerFromConf(conf LogConf) (*Logger, io.Closer, error) {
+	var writers []io.Writer
+	var closer io.Closer
+	for _, output := range conf.Outputs {
+		switch output {
+		case "stdout":
+			writers = append(writers, os.Stdout)
+		case "file":
+			file, err := newRotatingFile(conf.File)
+			if err != nil {
+				return nil, nil, err
 			}
-			return
+			writers = append(writers, file)
+			closer = file
 		}
+	}
+	if len(writers) == 0 {
+		writers = append(writers, io.Discard)
+	}
+
+	logger := newLogger(io.MultiWriter(writers...), conf.Level, conf.Format, conf.ServiceName)
+	return logger, closer, nil
+}
+
+// NewLogger 创建以 JSON 格式输出到 output 的日志实例
+func NewLogger(output io.Writer, level string) *Logger {
+	return newLogger(output, level, "json", "wallet")
+}
 
-		// 创建一个io.MultiWriter，同时输出到控制台和文件
-		multiWriter := io.MultiWriter(os.Stdout, logFile)
+// newLogger 创建日志实例，format 为 json 或 text
+func newLogger(output io.Writer, level, format, serviceName string) *Logger {
+	levelVar := new(slog.LevelVar)
+	if lvl, ok := levelMap[level]; ok {
+		levelVar.Set(lvl)
+	}
+
+	opts := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       levelVar,
+		ReplaceAttr: replaceAttr,
+	}
+	var handler slog.Handler
+	if format == "text" {
+		handler = slog.NewTextHandler(output, opts)
+	} else {
+		handler = slog.NewJSONHandler(output, opts)
+	}
 
-		globalLogger = &Logger{
-			level:       levelMap[level],
-			output:      multiWriter,
-			serviceName: "blog-service",
+	return &Logger{
+		slog:  slog.New(&traceHandler{handler}).With("service", serviceName),
+		level: levelVar,
+	}
+}
+
+// replaceAttr 保持原有的字段名和格式：time、level、caller、message
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	// 同名的附加字段按原样输出
+	switch a.Key {
+	case slog.TimeKey:
+		if a.Value.Kind() == slog.KindTime {
+			return slog.String("time", a.Value.Time().Format("2006-01-02 15:04:05.000"))
+		}
+	case slog.LevelKey:
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			return slog.String("level", levelName(level))
+		}
+	case slog.MessageKey:
+		a.Key = "message"
+	case slog.SourceKey:
+		source, ok := a.Value.Any().(*slog.Source)
+		if !ok || source.File == "" {
+			return slog.Attr{}
 		}
+		return slog.String("caller", fmt.Sprintf("%s:%d", filepath.Base(source.File), source.Line))
+	}
+	return a
+}
 
-		// 记录初始化日志
-		globalLogger.Info("Logger initialized successfully", "level", level)
-	})
+// levelName 返回 slog 级别对应的日志级别名
+func levelName(level slog.Level) string {
+	switch {
+	case level >= slogLevelFatal:
+		return LevelFatal
+	case level >= slog.LevelError:
+		return LevelError
+	case level >= slog.LevelWarn:
+		return LevelWarn
+	case level >= slog.LevelInfo:
+		return LevelInfo
+	default:
+		return LevelDebug
+	}
+}
+
+// traceHandler 为带链路的日志加上 trace_id 和 span_id
+type traceHandler struct {
+	slog.Handler
+}
+
+// Handle 实现 slog.Handler
+func (h *traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs 实现 slog.Handler
+func (h *traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup 实现 slog.Handler
+func (h *traceHandler) WithGroup(name string) slog.Handler {
+	return &traceHandler{h.Handler.WithGroup(name)}
 }
 
 // GetLogger 获取全局日志实例
 func GetLogger() *Logger {
-	if globalLogger == nil {
+	loggerMu.RLock()
+	logger := globalLogger
+	loggerMu.RUnlock()
+	if logger == nil {
 		InitLogger()
+		loggerMu.RLock()
+		logger = globalLogger
+		loggerMu.RUnlock()
 	}
-	return globalLogger
+	return logger
 }
 
-// 设置日志级别
-func (l *Logger) SetLevel(level string) {
-	l.Lock()
-	defer l.Unlock()
-	if lvl, ok := levelMap[level]; ok {
-		l.level = lvl
+// SetLogger 替换全局日志实例
+func SetLogger(logger *Logger) {
+	once.Do(func() {})
+	loggerMu.Lock()
+	globalLogger = logger
+	loggerMu.Unlock()
+}
+
+// CloseLogger 关闭日志文件
+func CloseLogger() error {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+	if logFile == nil {
+		return nil
+	}
+	err := logFile.Close()
+	logFile = nil
+	return err
+}
+
+// WithContext 返回绑定 ctx 的日志实例，输出时自动带上 ctx 中链路的 trace_id 和 span_id
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	return &Logger{slog: l.slog, level: l.level, ctx: ctx}
+}
+
+// With 返回附加了 fields（key-value 对）的日志实例，如请求 ID
+func (l *Logger) With(fields ...interface{}) *Logger {
+	return &Logger{slog: l.slog.With(fields...), level: l.level, ctx: l.ctx}
+}
+
+// SetLevel 设置日志级别，对该实例及其派生实例立即生效
+func (l *Logger) SetLevel(level string) error {
+	lvl, ok := levelMap[level]
+	if !ok {
+		return fmt.Errorf("unknown log level %q", level)
 	}
+	l.level.Set(lvl)
+	return nil
+}
+
+// Level 返回当前日志级别
+func (l *Logger) Level() string {
+	return levelName(l.level.Level())
+}
+
+// Enabled 判断该级别的日志是否会输出
+func (l *Logger) Enabled(level string) bool {
+	return l.slog.Enabled(l.context(), levelMap[level])
 }
 
 // Debug 调试级别日志
 func (l *Logger) Debug(msg string, fields ...interface{}) {
-	if l.level <= levelMap[LevelDebug] {
-		writeLog(l, LevelDebug, msg, fields)
-	}
+	l.log(slog.LevelDebug, msg, fields)
 }
 
 // Info 信息级别日志
 func (l *Logger) Info(msg string, fields ...interface{}) {
-	if l.level <= levelMap[LevelInfo] {
-		writeLog(l, LevelInfo, msg, fields)
-	}
+	l.log(slog.LevelInfo, msg, fields)
 }
 
 // Warn 警告级别日志
 func (l *Logger) Warn(msg string, fields ...interface{}) {
-	if l.level <= levelMap[LevelWarn] {
-		writeLog(l, LevelWarn, msg, fields)
-	}
+	l.log(slog.LevelWarn, msg, fields)
 }
 
 // Error 错误级别日志
 func (l *Logger) Error(msg string, fields ...interface{}) {
-	if l.level <= levelMap[LevelError] {
-		writeLog(l, LevelError, msg, fields)
-	}
+	l.log(slog.LevelError, msg, fields)
 }
 
 // Fatal 致命错误级别日志，记录后程序退出
 func (l *Logger) Fatal(msg string, fields ...interface{}) {
-	if l.level <= levelMap[LevelFatal] {
-		writeLog(l, LevelFatal, msg, fields)
-		os.Exit(1)
-	}
+	l.log(slogLevelFatal, msg, fields)
+	os.Exit(1)
 }
 
-// writeLog 写入日志
-func writeLog(l *Logger, level string, msg string, fields []interface{}) {
-	l.Lock()
-	defer l.Unlock()
+// context 返回绑定的上下文
+func (l *Logger) context() context.Context {
+	if l.ctx == nil {
+		return context.Background()
+	}
+	return l.ctx
+}
 
-	// 获取调用者信息
-	_, file, line, ok := runtime.Caller(3)
-	caller := "unknown"
-	if ok {
-		// 获取文件名，不包含路径
-		fileName := filepath.Base(file)
-		caller = fmt.Sprintf("%s:%d", fileName, line)
+// log 输出一条日志，caller 为调用日志方法的位置；日志方法必须直接调用 log
+func (l *Logger) log(level slog.Level, msg string, fields []interface{}) {
+	ctx := l.context()
+	if !l.slog.Enabled(ctx, level) {
+		return
 	}
 
-	// 格式化时间
-	now := time.Now().Format("2006-01-02 15:04:05.000")
+	// 跳过 runtime.Callers、log 和日志方法本身
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	l.write(ctx, level, msg, pcs[0], fields)
+}
 
-	// 构建基本日志信息
-	logMsg := map[string]interface{}{
-		"time":    now,
-		"level":   level,
-		"service": l.serviceName,
-		"caller":  caller,
-		"message": msg,
-	}
+// write 输出一条日志；pc 为 0 时不输出 caller
+func (l *Logger) write(ctx context.Context, level slog.Level, msg string, pc uintptr, fields []interface{}) {
+	r := slog.NewRecord(time.Now(), level, msg, pc)
+	r.Add(fields...)
+	_ = l.slog.Handler().Handle(ctx, r)
+}
 
-	// 处理额外字段
-	if len(fields) > 0 {
-		// 确保字段数量为偶数（key-value对）
-		if len(fields)%2 != 0 {
-			fields = append(fields, "") // 添加一个空值，避免索引越界
-		}
+// loggerKey 是 context 中请求日志实例的键
+type loggerKey struct{}
 
-		for i := 0; i < len(fields); i += 2 {
-			key, ok := fields[i].(string)
-			if !ok {
-				key = fmt.Sprintf("field_%d", i/2)
-			}
-			logMsg[key] = fields[i+1]
-		}
-	}
+// ContextWithLogger 返回携带 logger 的 ctx，由请求中间件放入请求日志实例
+func ContextWithLogger(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
 
-	// 转换为JSON
-	jsonBytes, err := json.Marshal(logMsg)
-	if err != nil {
-		// 如果JSON转换失败，使用简单格式
-		fmt.Fprintf(l.output, "%s [%s] %s - %s\n", now, level, caller, msg)
-		return
+// LoggerFromContext 返回 ctx 中的请求日志实例并绑定 ctx；ctx 中没有时返回全局日志实例
+func LoggerFromContext(ctx context.Context) *Logger {
+	if ctx == nil {
+		return GetLogger()
+	}
+	if logger, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return logger.WithContext(ctx)
 	}
+	return GetLogger().WithContext(ctx)
+}
+
+// LogWriter 返回按行写入日志的 io.Writer，用于接管 gin、标准库 log 等只支持 io.Writer 的输出
+func LogWriter(level string) io.Writer {
+	return &lineWriter{level: levelMap[level]}
+}
 
-	// 写入日志
-	fmt.Fprintf(l.output, "%s\n", string(jsonBytes))
+// lineWriter 把每行输出记为一条日志
+type lineWriter struct {
+	level slog.Level
+}
+
+// Write 实现 io.Writer
+func (w *lineWriter) Write(p []byte) (int, error) {
+	logger := GetLogger()
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		msg := strings.TrimSpace(string(line))
+		if msg == "" {
+			continue
+		}
+		if logger.slog.Enabled(context.Background(), w.level) {
+			logger.write(context.Background(), w.level, msg, 0, nil)
+		}
+	}
+	return len(p), nil
 }
 
 // Debug 全局调试日志
 func Debug(msg string, fields ...interface{}) {
-	GetLogger().Debug(msg, fields...)
+	GetLogger().log(slog.LevelDebug, msg, fields)
 }
 
 // Info 全局信息日志
 func Info(msg string, fields ...interface{}) {
-	GetLogger().Info(msg, fields...)
+	GetLogger().log(slog.LevelInfo, msg, fields)
 }
 
 // Warn 全局警告日志
 func Warn(msg string, fields ...interface{}) {
-	GetLogger().Warn(msg, fields...)
+	GetLogger().log(slog.LevelWarn, msg, fields)
 }
 
 // Error 全局错误日志
 func Error(msg string, fields ...interface{}) {
-	GetLogger().Error(msg, fields...)
+	GetLogger().log(slog.LevelError, msg, fields)
 }
 
 // Fatal 全局致命错误日志
 func Fatal(msg string, fields ...interface{}) {
-	GetLogger().Fatal(msg, fields...)
+	GetLogger().log(slogLevelFatal, msg, fields)
+	os.Exit(1)
 }
 
 // WithTrace 记录带有请求追踪信息的日志
 func WithTrace(traceID string, level string, msg string, fields ...interface{}) {
-	newFields := append([]interface{}{"trace_id", traceID}, fields...)
-
-	logger := GetLogger()
-	switch level {
-	case LevelDebug:
-		logger.Debug(msg, newFields...)
-	case LevelInfo:
-		logger.Info(msg, newFields...)
-	case LevelWarn:
-		logger.Warn(msg, newFields...)
-	case LevelError:
-		logger.Error(msg, newFields...)
-	case LevelFatal:
-		logger.Fatal(msg, newFields...)
-	default:
-		logger.Info(msg, newFields...)
+	lvl, ok := levelMap[level]
+	if !ok {
+		lvl = slog.LevelInfo
+	}
+	GetLogger().log(lvl, msg, append([]interface{}{"trace_id", traceID}, fields...))
+	if lvl == slogLevelFatal {
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 配置的覆盖顺序（后者优先）：默认值 < 配置文件 < 环境变量 < 命令行参数。
+// 每个字段按 yaml 路径命名：mysql.password 对应环境变量 WALLET_MYSQL_PASSWORD
+// 和命令行参数 -mysql.password；环境变量 WALLET_MYSQL_PASSWORD_FILE 从文件读取该值，
+// 适用于挂载的密钥文件。
+
+// EnvPrefix 覆盖配置的环境变量前缀
+const EnvPrefix = "WALLET_"
+
+// secretMask 输出配置时替换敏感字段的值
+const secretMask = "******"
+
+var (
+	// flagOverrides 命令行参数指定的值，按字段路径索引，由 ParseFlags 设置
+	flagOverrides map[string]string
+	// configPathFlag 命令行参数 -config 指定的配置文件路径
+	configPathFlag string
+)
+
+// configField 配置中可单独覆盖的字段
+type configField struct {
+	path    string // yaml 路径，如 mysql.password
+	env     string // 环境变量名，如 WALLET_MYSQL_PASSWORD
+	index   []int  // 在 Config 中的位置
+	typ     reflect.Type
+	restart bool // 自身或所在部分带 reload:"restart" 标签，热加载时保持原值
+}
+
+// configFields 返回 Config 的所有字段，嵌套的结构体展开为其字段；
+// 列表、map 作为整体覆盖，值为 YAML，字符串列表也可以用逗号分隔
+func configFields() []configField {
+	var fields []configField
+	var walk func(t reflect.Type, prefix string, index []int, restart bool)
+	walk = func(t reflect.Type, prefix string, index []int, restart bool) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			path := prefix + name
+			fieldIndex := append(append([]int{}, index...), i)
+			fieldRestart := restart || field.Tag.Get("reload") == "restart"
+			if field.Type.Kind() == reflect.Struct {
+				walk(field.Type, path+".", fieldIndex, fieldRestart)
+				continue
+			}
+			fields = append(fields, configField{
+				path:    path,
+				env:     EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_")),
+				index:   fieldIndex,
+				typ:     field.Type,
+				restart: fieldRestart,
+			})
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "", nil, false)
+	return fields
+}
+
+// ParseFlags 解析命令行参数中的配置覆盖，返回其余参数（如维护命令）；
+// 需在 InitConfig 之前调用
+func ParseFlags(args []string) ([]string, error) {
+	flags := flag.NewFlagSet("wallet", flag.ContinueOnError)
+	path := flags.String("config", "", "config file, overrides CONFIG_PATH")
+	overrides := map[string]string{}
+	for _, field := range configFields() {
+		flags.Var(&overrideFlag{path: field.path, overrides: overrides, isBool: field.typ.Kind() == reflect.Bool},
+			field.path, fmt.Sprintf("overrides %s (env %s)", field.path, field.env))
+	}
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: wallet [-config file] [-<field> value ...] [command]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	flagOverrides = overrides
+	configPathFlag = *path
+	return flags.Args(), nil
+}
+
+// overrideFlag 记录某个字段的命令行参数值
+type overrideFlag struct {
+	path      string
+	overrides map[string]string
+	isBool    bool
+}
+
+// String 实现 flag.Value
+func (f *overrideFlag) String() string {
+	if f.overrides == nil {
+		return ""
+	}
+	return f.overrides[f.path]
+}
+
+// Set 实现 flag.Value
+func (f *overrideFlag) Set(value string) error {
+	f.overrides[f.path] = value
+	return nil
+}
+
+// IsBoolFlag 布尔字段可以只写参数名，如 -interest.enabled
+func (f *overrideFlag) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// applyOverrides 用环境变量和命令行参数覆盖配置文件中的值
+func applyOverrides(config *Config) error {
+	root := reflect.ValueOf(config).Elem()
+	for _, field := range configFields() {
+		value, source, ok, err := lookupOverride(field)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := setField(root.FieldByIndex(field.index), value); err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+	}
+	return nil
+}
+
+// lookupOverride 按命令行参数、环境变量、环境变量 _FILE 的顺序查找字段的值
+func lookupOverride(field configField) (value, source string, ok bool, err error) {
+	if value, ok := flagOverrides[field.path]; ok {
+		return value, "flag -" + field.path, true, nil
+	}
+
+	value, fromEnv := os.LookupEnv(field.env)
+	file, fromFile := os.LookupEnv(field.env + "_FILE")
+	switch {
+	case fromEnv && fromFile:
+		return "", "", false, fmt.Errorf("both %s and %s_FILE are set", field.env, field.env)
+	case fromEnv:
+		return value, "env " + field.env, true, nil
+	case fromFile:
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", "", false, fmt.Errorf("%s_FILE: %w", field.env, err)
+		}
+		// 密钥文件通常以换行结尾
+		return strings.TrimRight(string(content), "\r\n"), "env " + field.env + "_FILE", true, nil
+	}
+	return "", "", false, nil
+}
+
+// setField 把字符串形式的值写入字段：字符串原样使用，字符串列表可用逗号分隔，
+// 其他类型按 YAML 解析，如 30s、true、[USD, EUR]、{USD: 1, EUR: 0.92}
+func setField(v reflect.Value, value string) error {
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(value)
+		return nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "["):
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items).Convert(v.Type()))
+		return nil
+	}
+
+	parsed := reflect.New(v.Type())
+	if err := yaml.Unmarshal([]byte(value), parsed.Interface()); err != nil {
+		return fmt.Errorf("invalid %s value %q: %w", v.Type(), value, err)
+	}
+	v.Set(parsed.Elem())
+	return nil
+}
+
+// Masked 返回隐藏了敏感字段（带 secret:"true" 标签）的配置副本，用于输出
+func (c *Config) Masked() *Config {
+	masked := *c
+	maskSecrets(reflect.ValueOf(&masked).Elem())
+	return &masked
+}
+
+// maskSecrets 隐藏 v 中的敏感字段；列表先复制再修改，不影响原配置
+func maskSecrets(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if v.Type().Field(i).Tag.Get("secret") == "true" && field.Kind() == reflect.String {
+				if field.String() != "" {
+					field.SetString(secretMask)
+				}
+				continue
+			}
+			maskSecrets(field)
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(copied, v)
+		v.Set(copied)
+		for i := 0; i < v.Len(); i++ {
+			maskSecrets(v.Index(i))
+		}
+	}
+}
+
+// Dump 以 YAML 输出生效的配置，敏感字段已隐藏
+func (c *Config) Dump() ([]byte, error) {
+	return yaml.Marshal(c.Masked())
+}
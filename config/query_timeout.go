@@ -0,0 +1,78 @@
+package config
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// queryTimeoutKey 保存语句执行前的 context 及取消函数
+const queryTimeoutKey = "wallet:query_timeout"
+
+// queryTimeout 为每条 SQL 设置执行超时
+type queryTimeout struct {
+	timeout time.Duration
+}
+
+// queryTimeoutState 语句执行前的 context，执行后恢复，语句复用时不会沿用已取消的 context
+type queryTimeoutState struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// QueryTimeout 返回限制单条 SQL 执行时间的 GORM 插件：超过 timeout 时取消语句，
+// context 已有更早的期限时以其为准。db.Rows() 返回的结果在语句之后读取，不受限制
+func QueryTimeout(timeout time.Duration) gorm.Plugin {
+	return queryTimeout{timeout: timeout}
+}
+
+// Name 实现 gorm.Plugin
+func (queryTimeout) Name() string {
+	return "query_timeout"
+}
+
+// Initialize 实现 gorm.Plugin
+func (p queryTimeout) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("query_timeout:before_create", p.before),
+		cb.Create().After("gorm:create").Register("query_timeout:after_create", p.after),
+		cb.Query().Before("gorm:query").Register("query_timeout:before_query", p.before),
+		cb.Query().After("gorm:query").Register("query_timeout:after_query", p.after),
+		cb.Update().Before("gorm:update").Register("query_timeout:before_update", p.before),
+		cb.Update().After("gorm:update").Register("query_timeout:after_update", p.after),
+		cb.Delete().Before("gorm:delete").Register("query_timeout:before_delete", p.before),
+		cb.Delete().After("gorm:delete").Register("query_timeout:after_delete", p.after),
+		cb.Raw().Before("gorm:raw").Register("query_timeout:before_raw", p.before),
+		cb.Raw().After("gorm:raw").Register("query_timeout:after_raw", p.after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// before 为语句的 context 加上超时
+func (p queryTimeout) before(db *gorm.DB) {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	db.InstanceSet(queryTimeoutKey, queryTimeoutState{ctx: db.Statement.Context, cancel: cancel})
+	db.Statement.Context = timeoutCtx
+}
+
+// after 释放超时并恢复语句原来的 context
+func (p queryTimeout) after(db *gorm.DB) {
+	value, _ := db.InstanceGet(queryTimeoutKey)
+	state, ok := value.(queryTimeoutState)
+	if !ok {
+		return
+	}
+	db.InstanceSet(queryTimeoutKey, nil)
+	state.cancel()
+	db.Statement.Context = state.ctx
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// ReloadResult 一次热加载的结果，字段按 yaml 路径表示，如 log.level
+type ReloadResult struct {
+	Applied  []string `json:"applied"`  // 已生效的修改
+	Rejected []string `json:"rejected"` // 需要重启才能生效、未应用的修改
+}
+
+var (
+	// subscribers 配置变更的回调
+	subscribers   []func(prev, next *Config)
+	subscribersMu sync.Mutex
+
+	// watchedSum 配置文件上次检查时内容的摘要
+	watchedSum []byte
+)
+
+// Subscribe 注册配置变更的回调：热加载应用了修改后，按注册顺序以变更前后的配置调用。
+// 回调中不能修改配置，也不能再调用 Reload
+func Subscribe(fn func(prev, next *Config)) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	subscribers = append(subscribers, fn)
+}
+
+// Reload 重新加载配置文件、环境变量和命令行参数，并整体替换当前配置。
+// 加载或校验失败时保持当前配置；只能重启生效的字段保持原值，记录 warn 日志
+func Reload() (*ReloadResult, error) {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+	return reload()
+}
+
+// ReloadIfChanged 在配置文件内容变化时热加载，由后台任务定期调用；
+// 未使用配置文件时不做任何事
+func ReloadIfChanged(ctx context.Context) error {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	if configFile == "" {
+		return nil
+	}
+	sum, err := fileSum(configFile)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(watchedSum, sum) {
+		return nil
+	}
+	// 加载失败时也记下摘要，文件再次修改前不重复报错
+	watchedSum = sum
+
+	_, err = reload()
+	return err
+}
+
+// fileSum 返回文件内容的摘要
+func fileSum(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	return sum[:], nil
+}
+
+// reload 执行热加载，调用方持有 loadMu
+func reload() (*ReloadResult, error) {
+	prev := conf.Load()
+	next, path, err := loadConfig()
+	if prev == nil {
+		// 尚未加载过配置，等同于 InitConfig
+		if err != nil {
+			return nil, err
+		}
+		storeConfig(next, path)
+		return &ReloadResult{}, nil
+	}
+
+	logger := GetLogger()
+	if err != nil {
+		logger.Error("config reload failed, keeping current config", "error", err.Error())
+		return nil, err
+	}
+	// 文件内容已处理，后台检查不再重复加载
+	watchedSum, _ = fileSum(path)
+
+	result := &ReloadResult{Applied: []string{}, Rejected: []string{}}
+	prevValue := reflect.ValueOf(prev).Elem()
+	nextValue := reflect.ValueOf(next).Elem()
+	for _, field := range configFields() {
+		prevField := prevValue.FieldByIndex(field.index)
+		nextField := nextValue.FieldByIndex(field.index)
+		if reflect.DeepEqual(prevField.Interface(), nextField.Interface()) {
+			continue
+		}
+		if field.restart {
+			nextField.Set(prevField)
+			result.Rejected = append(result.Rejected, field.path)
+		} else {
+			result.Applied = append(result.Applied, field.path)
+		}
+	}
+
+	if len(result.Rejected) > 0 {
+		logger.Warn("config changes require a restart and were not applied", "fields", result.Rejected)
+	}
+	if len(result.Applied) == 0 {
+		return result, nil
+	}
+
+	storeConfig(next, path)
+	logger.Info("config reloaded", "fields", result.Applied)
+
+	subscribersMu.Lock()
+	callbacks := append([]func(prev, next *Config){}, subscribers...)
+	subscribersMu.Unlock()
+	for _, fn := range callbacks {
+		fn(prev, next)
+	}
+	return result, nil
+}
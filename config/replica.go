@@ -0,0 +1,121 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// replica 只读副本的连接及可用状态
+type replica struct {
+	name    string // host:port
+	db      *gorm.DB
+	up      atomic.Bool
+	checked atomic.Bool // 已检查过至少一次
+}
+
+var (
+	// replicas 配置的只读副本，InitDB 时连接
+	replicas []*replica
+	// replicaNext 轮流选择只读副本
+	replicaNext atomic.Uint64
+)
+
+// initReplicas 连接配置的只读副本。连接时不检查连通性，副本由 CheckReplicas 检查可用后才会使用，
+// 副本不可用不影响启动
+func initReplicas(config *Config) error {
+	replicas = nil
+	for _, conf := range config.MySQL.Replicas {
+		db, err := openDB(config, conf.Host, conf.Port, conf.User, conf.Password, false)
+		if err != nil {
+			return fmt.Errorf("failed to connect database replica %s:%d: %w", conf.Host, conf.Port, err)
+		}
+		if config.MySQL.QueryTimeout > 0 {
+			if err := db.Use(QueryTimeout(config.MySQL.QueryTimeout)); err != nil {
+				return err
+			}
+		}
+		replicas = append(replicas, &replica{name: fmt.Sprintf("%s:%d", conf.Host, conf.Port), db: db})
+	}
+
+	return CheckReplicas(context.Background())
+}
+
+// GetReadDB 返回只读查询使用的连接：轮流使用可用的只读副本，没有可用的副本时使用主库。
+// 副本有复制延迟，只用于可以接受稍旧数据的查询，如列表、交易记录和对账单；
+// 余额变更及需要读到刚写入数据的查询使用 GetDB
+func GetReadDB() *gorm.DB {
+	n := len(replicas)
+	start := replicaNext.Add(1)
+	for i := 0; i < n; i++ {
+		if r := replicas[(start+uint64(i))%uint64(n)]; r.up.Load() {
+			return r.db
+		}
+	}
+	return DB
+}
+
+// CheckReplicas 检查每个只读副本是否可用，首次检查及状态变化时输出日志；由后台任务定期调用
+func CheckReplicas(ctx context.Context) error {
+	for _, r := range replicas {
+		err := pingReplica(ctx, r)
+		up := err == nil
+		changed := r.up.Swap(up) != up
+		first := !r.checked.Swap(true)
+		if !changed && !first {
+			continue
+		}
+		if up {
+			GetLogger().Info("database replica available", "replica", r.name)
+		} else {
+			GetLogger().Warn("database replica unavailable, reads fall back to the primary", "replica", r.name, "error", err.Error())
+		}
+	}
+	return nil
+}
+
+// pingReplica 在 health.timeout 内检查一个只读副本的连通性
+func pingReplica(ctx context.Context, r *replica) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, GetConf().Health.Timeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+// ReplicaStatus 返回各只读副本是否可用，键为 host:port
+func ReplicaStatus() map[string]bool {
+	status := make(map[string]bool, len(replicas))
+	for _, r := range replicas {
+		status[r.name] = r.up.Load()
+	}
+	return status
+}
+
+// ReplicaDBs 返回各只读副本的连接，键为 host:port，用于安装插件和导出连接池指标
+func ReplicaDBs() map[string]*gorm.DB {
+	dbs := make(map[string]*gorm.DB, len(replicas))
+	for _, r := range replicas {
+		dbs[r.name] = r.db
+	}
+	return dbs
+}
+
+// closeReplicas 关闭只读副本的连接池
+func closeReplicas() error {
+	var firstErr error
+	for _, r := range replicas {
+		sqlDB, err := r.db.DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
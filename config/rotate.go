@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// rotatingFile 日志文件：超过大小或到达轮转时间时轮转，并按保留天数和个数删除旧文件
+type rotatingFile struct {
+	mu     sync.Mutex
+	file   *lumberjack.Logger
+	every  time.Duration
+	rotate time.Time // 下一次按时间轮转的时刻
+}
+
+// newRotatingFile 打开日志文件，目录不存在时创建
+func newRotatingFile(conf LogFileConf) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(conf.Path), 0755); err != nil {
+		return nil, err
+	}
+
+	f := &rotatingFile{
+		file: &lumberjack.Logger{
+			Filename:   conf.Path,
+			MaxSize:    conf.MaxSizeMB,
+			MaxAge:     conf.MaxAgeDays,
+			MaxBackups: conf.MaxBackups,
+			LocalTime:  true,
+			Compress:   conf.Compress,
+		},
+		every: conf.RotateEvery,
+	}
+	if f.every > 0 {
+		f.rotate = nextRotation(time.Now(), f.every)
+	}
+	return f, nil
+}
+
+// Write 实现 io.Writer，到达轮转时间后先轮转再写入
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.every > 0 {
+		if now := time.Now(); !now.Before(f.rotate) {
+			f.rotate = nextRotation(now, f.every)
+			if err := f.file.Rotate(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return f.file.Write(p)
+}
+
+// Close 实现 io.Closer
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// nextRotation 返回 now 之后的下一个轮转时刻，从本地时间零点起按 every 对齐，
+// 如 24h 为次日零点，1h 为下一个整点
+func nextRotation(now time.Time, every time.Duration) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return midnight.Add(now.Sub(midnight).Truncate(every) + every)
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"wallet/service"
+	"wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateApiClient registers a partner API client; the key is only returned here
+func CreateApiClient(c *gin.Context) {
+	type CreateClientRequest struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	var req CreateClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	clientService := NewApiClientService()
+	client, key, err := clientService.CreateClient(req.Name)
+	if err != nil {
+		if err.Error() == "name required" {
+			utils.BadRequest(c, "Name is required")
+		} else {
+			utils.InternalError(c, "Failed to create API client")
+		}
+		return
+	}
+
+	utils.Created(c, gin.H{"client": client, "api_key": key})
+}
+
+// GetApiClients lists the API clients
+func GetApiClients(c *gin.Context) {
+	clientService := NewApiClientService()
+	clients, err := clientService.GetClients()
+	if err != nil {
+		utils.InternalError(c, "Failed to fetch API clients")
+		return
+	}
+
+	utils.Success(c, clients)
+}
+
+// NewApiClientService creates API client service instance
+func NewApiClientService() *service.ApiClientServiceImpl {
+	return service.NewApiClientService()
+}
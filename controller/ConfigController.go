@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"net/http"
+
+	"wallet/config"
+	"wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEffectiveConfig returns the configuration in effect after env and flag
+// overrides, as YAML, with secrets masked
+func GetEffectiveConfig(c *gin.Context) {
+	out, err := config.GetConf().Dump()
+	if err != nil {
+		utils.InternalError(c, "Failed to dump config")
+		return
+	}
+
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", out)
+}
+
+// ReloadConfig reloads the config file, env vars and flags. Restart-only fields
+// keep their running values and are reported as rejected.
+func ReloadConfig(c *gin.Context) {
+	result, err := config.Reload()
+	if err != nil {
+		utils.BadRequest(c, "Invalid configuration: "+err.Error())
+		return
+	}
+
+	utils.Success(c, result)
+}
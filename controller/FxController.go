@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"net/http"
+
+	"wallet/service"
+	"wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateFxQuote quotes a conversion between two currencies for a user
+func CreateFxQuote(c *gin.Context) {
+	type QuoteRequest struct {
+		UserID       int    `json:"user_id" binding:"required"`
+		FromCurrency string `json:"from_currency" binding:"required,len=3"`
+		ToCurrency   string `json:"to_currency" binding:"required,len=3"`
+	}
+
+	var req QuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	fxService := NewFxService().WithContext(c.Request.Context())
+	quote, err := fxService.CreateQuote(req.UserID, req.FromCurrency, req.ToCurrency)
+	if err != nil {
+		switch err.Error() {
+		case "wallet not found":
+			utils.NotFound(c, "Wallet not found")
+		case "same currency":
+			utils.BadRequest(c, "Cannot exchange a currency to itself")
+		case "unsupported currency":
+			utils.BadRequest(c, "Unsupported currency")
+		case "rate unavailable":
+			utils.Error(c, http.StatusServiceUnavailable, "Exchange rate unavailable")
+		default:
+			utils.InternalError(c, "Failed to create quote")
+		}
+		return
+	}
+
+	utils.Created(c, quote)
+}
+
+// ExchangeCurrency converts funds between a user's currency wallets using a quote
+func ExchangeCurrency(c *gin.Context) {
+	type ExchangeRequest struct {
+		UserID  int     `json:"user_id" binding:"required"`
+		QuoteID uint    `json:"quote_id" binding:"required"`
+		Amount  float64 `json:"amount" binding:"required,gt=0"`
+	}
+
+	var req ExchangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	fxService := NewFxService().WithContext(c.Request.Context())
+	result, err := fxService.Exchange(req.UserID, req.QuoteID, req.Amount)
+	if err != nil {
+		switch err.Error() {
+		case "quote not found":
+			utils.NotFound(c, "Quote not found")
+		case "wallet not found":
+			utils.NotFound(c, "Wallet not found")
+		case "quote already used":
+			utils.BadRequest(c, "Quote already used")
+		case "quote expired":
+			utils.BadRequest(c, "Quote expired")
+		case "amount too small":
+			utils.BadRequest(c, "Amount too small to exchange")
+		case "insufficient balance":
+			utils.BadRequest(c, "Insufficient balance")
+		case "wallet frozen":
+			utils.Forbidden(c, "Wallet is frozen")
+		default:
+			utils.InternalError(c, "Failed to exchange")
+		}
+		return
+	}
+
+	utils.Success(c, result)
+}
+
+// NewFxService creates currency exchange service instance
+func NewFxService() *service.FxServiceImpl {
+	return service.NewFxService()
+}
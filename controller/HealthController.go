@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"net/http"
+
+	"wallet/config"
+	"wallet/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Livez reports that the process is up and serving HTTP. It checks no
+// dependencies, so a database outage does not get the process restarted.
+func Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"state":  health.CurrentState().String(),
+	})
+}
+
+// Readyz runs the readiness checks and answers 503 unless the process is ready
+// and every check passes, with the result of each check
+func Readyz(c *gin.Context) {
+	report := health.DefaultRegistry().Run(c.Request.Context(), config.GetConf().Health.Timeout)
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
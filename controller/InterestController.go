@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	"wallet/service"
+	"wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetInterestHistory retrieves the interest accrual and payout history of user's wallet
+func GetInterestHistory(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid user ID format")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "31"))
+	if err != nil || limit <= 0 {
+		utils.BadRequest(c, "Invalid limit")
+		return
+	}
+
+	interestService := NewInterestService()
+	history, err := interestService.GetInterestHistory(userID, c.Query("currency"), limit)
+	if err != nil {
+		if err.Error() == "wallet not found" {
+			utils.NotFound(c, "Wallet not found")
+		} else {
+			utils.InternalError(c, "Failed to fetch interest history")
+		}
+		return
+	}
+
+	utils.Success(c, history)
+}
+
+// RunInterestAccrual accrues interest for a given day; repeating a day is a no-op
+func RunInterestAccrual(c *gin.Context) {
+	type AccrualRequest struct {
+		Date string `json:"date" binding:"required"` // YYYY-MM-DD
+	}
+
+	var req AccrualRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", req.Date, time.Local)
+	if err != nil {
+		utils.BadRequest(c, "Invalid date format")
+		return
+	}
+
+	interestService := NewInterestService()
+	accrued, err := interestService.Accrue(day)
+	if err != nil {
+		utils.InternalError(c, "Failed to accrue interest")
+		return
+	}
+
+	utils.Success(c, gin.H{"accrued": accrued})
+}
+
+// RunInterestPayout pays out interest accrued up to the end of a given month
+func RunInterestPayout(c *gin.Context) {
+	type PayoutRequest struct {
+		Month string `json:"month" binding:"required"` // YYYY-MM
+	}
+
+	var req PayoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	month, err := time.ParseInLocation("2006-01", req.Month, time.Local)
+	if err != nil {
+		utils.BadRequest(c, "Invalid month format")
+		return
+	}
+
+	interestService := NewInterestService()
+	paid, err := interestService.Payout(month)
+	if err != nil {
+		switch err.Error() {
+		case "house wallet not found":
+			utils.NotFound(c, "House wallet not found")
+		case "insufficient house balance":
+			utils.BadRequest(c, "Insufficient house balance")
+		default:
+			utils.InternalError(c, "Failed to pay out interest")
+		}
+		return
+	}
+
+	utils.Success(c, gin.H{"paid": paid})
+}
+
+// NewInterestService creates interest service instance
+func NewInterestService() *service.InterestServiceImpl {
+	return service.NewInterestService()
+}
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"wallet/config"
+	"wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLogLevel returns the current log level
+func GetLogLevel(c *gin.Context) {
+	utils.Success(c, gin.H{"level": config.GetLogger().Level()})
+}
+
+// SetLogLevel changes the log level of the running process, including SQL logs.
+// The change is not persisted; a restart goes back to log.level of the config.
+func SetLogLevel(c *gin.Context) {
+	type SetLogLevelRequest struct {
+		Level string `json:"level" binding:"required"`
+	}
+
+	var req SetLogLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	logger := config.GetLogger()
+	previous := logger.Level()
+	if err := logger.SetLevel(req.Level); err != nil {
+		utils.BadRequest(c, "Level must be one of debug, info, warn, error, fatal")
+		return
+	}
+	config.LoggerFromContext(c.Request.Context()).Warn("log level changed", "from", previous, "to", req.Level)
+
+	utils.Success(c, gin.H{"level": logger.Level()})
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"strconv"
+
+	"wallet/service"
+	"wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreatePocket creates a named pocket under user's wallet
+func CreatePocket(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid user ID format")
+		return
+	}
+
+	type CreatePocketRequest struct {
+		Name       string  `json:"name" binding:"required,max=100"`
+		GoalAmount float64 `json:"goal_amount" binding:"gte=0"`
+	}
+
+	var req CreatePocketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	pocketService := NewPocketService().WithContext(c.Request.Context())
+	pocket, err := pocketService.CreatePocket(userID, req.Name, req.GoalAmount)
+	if err != nil {
+		switch err.Error() {
+		case "wallet not found":
+			utils.NotFound(c, "Wallet not found")
+		case "pocket already exists":
+			utils.BadRequest(c, "Pocket already exists")
+		default:
+			utils.InternalError(c, "Failed to create pocket")
+		}
+		return
+	}
+
+	utils.Created(c, pocket)
+}
+
+// GetPockets retrieves all pockets of user's wallet
+func GetPockets(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid user ID format")
+		return
+	}
+
+	pocketService := NewPocketService().WithContext(c.Request.Context())
+	pockets, err := pocketService.GetPockets(userID)
+	if err != nil {
+		utils.InternalError(c, "Failed to fetch pockets")
+		return
+	}
+
+	utils.Success(c, pockets)
+}
+
+// DepositToPocket moves funds from main balance into a pocket
+func DepositToPocket(c *gin.Context) {
+	movePocketFunds(c, true)
+}
+
+// WithdrawFromPocket moves funds from a pocket back to main balance
+func WithdrawFromPocket(c *gin.Context) {
+	movePocketFunds(c, false)
+}
+
+// movePocketFunds handles both directions of a pocket move
+func movePocketFunds(c *gin.Context, toPocket bool) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid user ID format")
+		return
+	}
+
+	pocketID, err := strconv.ParseUint(c.Param("pocket_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid pocket ID format")
+		return
+	}
+
+	type MovePocketRequest struct {
+		Amount      float64 `json:"amount" binding:"required,gt=0"`
+		Description string  `json:"description"`
+	}
+
+	var req MovePocketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	pocketService := NewPocketService().WithContext(c.Request.Context())
+	var balance, pocketBalance float64
+	if toPocket {
+		balance, pocketBalance, err = pocketService.MoveToPocket(userID, uint(pocketID), req.Amount, req.Description)
+	} else {
+		balance, pocketBalance, err = pocketService.MoveFromPocket(userID, uint(pocketID), req.Amount, req.Description)
+	}
+	if err != nil {
+		switch err.Error() {
+		case "wallet not found":
+			utils.NotFound(c, "Wallet not found")
+		case "pocket not found":
+			utils.NotFound(c, "Pocket not found")
+		case "insufficient balance":
+			utils.BadRequest(c, "Insufficient balance")
+		case "insufficient pocket balance":
+			utils.BadRequest(c, "Insufficient pocket balance")
+		case "wallet frozen":
+			utils.Forbidden(c, "Wallet is frozen")
+		default:
+			utils.InternalError(c, "Failed to move pocket funds")
+		}
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"balance":        balance,
+		"pocket_balance": pocketBalance,
+	})
+}
+
+// NewPocketService creates pocket service instance
+func NewPocketService() *service.PocketServiceImpl {
+	return service.NewPocketService()
+}
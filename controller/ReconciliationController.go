@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"strconv"
+
+	"wallet/service"
+	"wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RunReconciliation recomputes every balance from the transaction history and
+// reports discrepancies, optionally freezing the affected wallets
+func RunReconciliation(c *gin.Context) {
+	type ReconcileRequest struct {
+		Freeze bool `json:"freeze"`
+	}
+
+	var req ReconcileRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BadRequest(c, "Invalid request parameters")
+			return
+		}
+	}
+
+	reconciliationService := NewReconciliationService()
+	report, err := reconciliationService.Run("api", req.Freeze)
+	if err != nil {
+		utils.InternalError(c, "Failed to reconcile")
+		return
+	}
+
+	utils.Success(c, report)
+}
+
+// GetReconciliationRuns lists the most recent reconciliation runs
+func GetReconciliationRuns(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		utils.BadRequest(c, "Invalid limit")
+		return
+	}
+
+	reconciliationService := NewReconciliationService()
+	runs, err := reconciliationService.GetRuns(limit)
+	if err != nil {
+		utils.InternalError(c, "Failed to fetch reconciliation runs")
+		return
+	}
+
+	utils.Success(c, runs)
+}
+
+// GetReconciliationRun retrieves a reconciliation run with its discrepancies
+func GetReconciliationRun(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid run ID format")
+		return
+	}
+
+	reconciliationService := NewReconciliationService()
+	report, err := reconciliationService.GetRun(uint(id))
+	if err != nil {
+		if err.Error() == "reconciliation run not found" {
+			utils.NotFound(c, "Reconciliation run not found")
+		} else {
+			utils.InternalError(c, "Failed to fetch reconciliation run")
+		}
+		return
+	}
+
+	utils.Success(c, report)
+}
+
+// VerifyTransactionChain walks the transaction hash chain and reports the first broken link
+func VerifyTransactionChain(c *gin.Context) {
+	chainService := NewChainService()
+	report, err := chainService.Verify()
+	if err != nil {
+		utils.InternalError(c, "Failed to verify transaction chain")
+		return
+	}
+
+	utils.Success(c, report)
+}
+
+// NewReconciliationService creates reconciliation service instance
+func NewReconciliationService() *service.ReconciliationServiceImpl {
+	return service.NewReconciliationService()
+}
+
+// NewChainService creates chain service instance
+func NewChainService() *service.ChainServiceImpl {
+	return service.NewChainService()
+}
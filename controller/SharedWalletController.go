@@ -0,0 +1,340 @@
+package controller
+
+import (
+	"strconv"
+
+	"wallet/middleware"
+	"wallet/models"
+	"wallet/service"
+	"wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateSharedWallet creates a shared wallet owned by the requesting user
+func CreateSharedWallet(c *gin.Context) {
+	type CreateSharedWalletRequest struct {
+		Name              string  `json:"name" binding:"required,max=100"`
+		ApprovalThreshold float64 `json:"approval_threshold" binding:"gte=0"`
+		RequiredApprovals int     `json:"required_approvals" binding:"gte=0"`
+	}
+
+	var req CreateSharedWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	userID, _ := middleware.AuthenticatedUserID(c)
+	sharedWalletService := NewSharedWalletService().WithContext(c.Request.Context())
+	wallet, err := sharedWalletService.CreateSharedWallet(userID, req.Name, req.ApprovalThreshold, req.RequiredApprovals)
+	if err != nil {
+		if err.Error() == "user not found" {
+			utils.NotFound(c, "User not found")
+		} else {
+			utils.InternalError(c, "Failed to create shared wallet")
+		}
+		return
+	}
+
+	utils.Created(c, wallet)
+}
+
+// GetUserSharedWallets retrieves the shared wallets the authenticated user belongs to
+func GetUserSharedWallets(c *gin.Context) {
+	userID, _ := middleware.AuthenticatedUserID(c)
+	sharedWalletService := NewSharedWalletService().WithContext(c.Request.Context())
+	wallets, err := sharedWalletService.GetUserSharedWallets(userID)
+	if err != nil {
+		utils.InternalError(c, "Failed to fetch shared wallets")
+		return
+	}
+
+	utils.Success(c, wallets)
+}
+
+// GetSharedWallet retrieves a shared wallet and its members
+func GetSharedWallet(c *gin.Context) {
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid shared wallet ID format")
+		return
+	}
+
+	userID, _ := middleware.AuthenticatedUserID(c)
+
+	sharedWalletService := NewSharedWalletService().WithContext(c.Request.Context())
+	wallet, err := sharedWalletService.GetSharedWallet(uint(walletID), userID)
+	if err != nil {
+		respondSharedWalletError(c, err, "Failed to fetch shared wallet")
+		return
+	}
+
+	utils.Success(c, wallet)
+}
+
+// SetSharedWalletMember adds a member or changes a member's role and spending limit
+func SetSharedWalletMember(c *gin.Context) {
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid shared wallet ID format")
+		return
+	}
+
+	type SetMemberRequest struct {
+		MemberUserID  int     `json:"member_user_id" binding:"required"`
+		Role          string  `json:"role" binding:"required,oneof=owner spender viewer"`
+		SpendingLimit float64 `json:"spending_limit" binding:"gte=0"`
+	}
+
+	var req SetMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	userID, _ := middleware.AuthenticatedUserID(c)
+	sharedWalletService := NewSharedWalletService().WithContext(c.Request.Context())
+	member, err := sharedWalletService.SetMember(uint(walletID), userID, req.MemberUserID, req.Role, req.SpendingLimit)
+	if err != nil {
+		respondSharedWalletError(c, err, "Failed to set member")
+		return
+	}
+
+	utils.Success(c, member)
+}
+
+// SetSharedWalletRule updates the approval rule of a shared wallet
+func SetSharedWalletRule(c *gin.Context) {
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid shared wallet ID format")
+		return
+	}
+
+	type SetRuleRequest struct {
+		ApprovalThreshold float64 `json:"approval_threshold" binding:"gte=0"`
+		RequiredApprovals int     `json:"required_approvals" binding:"gte=0"`
+	}
+
+	var req SetRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	userID, _ := middleware.AuthenticatedUserID(c)
+	sharedWalletService := NewSharedWalletService().WithContext(c.Request.Context())
+	wallet, err := sharedWalletService.SetApprovalRule(uint(walletID), userID, req.ApprovalThreshold, req.RequiredApprovals)
+	if err != nil {
+		respondSharedWalletError(c, err, "Failed to set approval rule")
+		return
+	}
+
+	utils.Success(c, wallet)
+}
+
+// DepositToSharedWallet moves funds from a member's wallet into the shared wallet
+func DepositToSharedWallet(c *gin.Context) {
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid shared wallet ID format")
+		return
+	}
+
+	type SharedDepositRequest struct {
+		Amount      float64 `json:"amount" binding:"required,gt=0"`
+		Description string  `json:"description"`
+	}
+
+	var req SharedDepositRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	userID, _ := middleware.AuthenticatedUserID(c)
+	sharedWalletService := NewSharedWalletService().WithContext(c.Request.Context())
+	balance, err := sharedWalletService.Deposit(uint(walletID), userID, req.Amount, req.Description)
+	if err != nil {
+		respondSharedWalletError(c, err, "Failed to deposit")
+		return
+	}
+
+	utils.Success(c, gin.H{"balance": balance})
+}
+
+// WithdrawFromSharedWallet withdraws from a shared wallet, pending approvals if required
+func WithdrawFromSharedWallet(c *gin.Context) {
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid shared wallet ID format")
+		return
+	}
+
+	type SharedWithdrawRequest struct {
+		Amount      float64 `json:"amount" binding:"required,gt=0"`
+		Description string  `json:"description"`
+	}
+
+	var req SharedWithdrawRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	userID, _ := middleware.AuthenticatedUserID(c)
+	sharedWalletService := NewSharedWalletService().WithContext(c.Request.Context())
+	op, err := sharedWalletService.RequestWithdraw(uint(walletID), userID, req.Amount, req.Description)
+	if err != nil {
+		respondSharedWalletError(c, err, "Failed to withdraw")
+		return
+	}
+
+	respondSharedOperation(c, op)
+}
+
+// TransferFromSharedWallet transfers from a shared wallet to a user, pending approvals if required
+func TransferFromSharedWallet(c *gin.Context) {
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid shared wallet ID format")
+		return
+	}
+
+	type SharedTransferRequest struct {
+		ToUserID    int     `json:"to_user_id" binding:"required"`
+		Amount      float64 `json:"amount" binding:"required,gt=0"`
+		Description string  `json:"description"`
+	}
+
+	var req SharedTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	userID, _ := middleware.AuthenticatedUserID(c)
+	sharedWalletService := NewSharedWalletService().WithContext(c.Request.Context())
+	op, err := sharedWalletService.RequestTransfer(uint(walletID), userID, req.ToUserID, req.Amount, req.Description)
+	if err != nil {
+		respondSharedWalletError(c, err, "Failed to transfer")
+		return
+	}
+
+	respondSharedOperation(c, op)
+}
+
+// GetSharedWalletOperations lists a shared wallet's operations
+func GetSharedWalletOperations(c *gin.Context) {
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid shared wallet ID format")
+		return
+	}
+
+	userID, _ := middleware.AuthenticatedUserID(c)
+
+	sharedWalletService := NewSharedWalletService().WithContext(c.Request.Context())
+	ops, err := sharedWalletService.GetOperations(uint(walletID), userID, c.Query("status"))
+	if err != nil {
+		respondSharedWalletError(c, err, "Failed to fetch operations")
+		return
+	}
+
+	utils.Success(c, ops)
+}
+
+// ApproveSharedWalletOperation approves a pending operation
+func ApproveSharedWalletOperation(c *gin.Context) {
+	decideSharedWalletOperation(c, true)
+}
+
+// RejectSharedWalletOperation rejects a pending operation
+func RejectSharedWalletOperation(c *gin.Context) {
+	decideSharedWalletOperation(c, false)
+}
+
+// decideSharedWalletOperation handles approval and rejection of a pending operation
+func decideSharedWalletOperation(c *gin.Context, approve bool) {
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid shared wallet ID format")
+		return
+	}
+
+	opID, err := strconv.ParseUint(c.Param("op_id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid operation ID format")
+		return
+	}
+
+	userID, _ := middleware.AuthenticatedUserID(c)
+	sharedWalletService := NewSharedWalletService().WithContext(c.Request.Context())
+	if approve {
+		op, err := sharedWalletService.ApproveOperation(uint(walletID), uint(opID), userID)
+		if err != nil {
+			respondSharedWalletError(c, err, "Failed to approve operation")
+			return
+		}
+		utils.Success(c, op)
+		return
+	}
+
+	op, err := sharedWalletService.RejectOperation(uint(walletID), uint(opID), userID)
+	if err != nil {
+		respondSharedWalletError(c, err, "Failed to reject operation")
+		return
+	}
+	utils.Success(c, op)
+}
+
+// respondSharedOperation answers 202 for operations waiting on approvals and 200 otherwise
+func respondSharedOperation(c *gin.Context, op *models.PendingOperations) {
+	if op.Status == "pending" {
+		utils.Accepted(c, op)
+		return
+	}
+	utils.Success(c, op)
+}
+
+// respondSharedWalletError maps shared wallet service errors to responses
+func respondSharedWalletError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "shared wallet not found":
+		utils.NotFound(c, "Shared wallet not found")
+	case "operation not found":
+		utils.NotFound(c, "Operation not found")
+	case "user not found":
+		utils.NotFound(c, "User not found")
+	case "wallet not found":
+		utils.NotFound(c, "Wallet not found")
+	case "recipient wallet not found":
+		utils.NotFound(c, "Recipient wallet not found")
+	case "not a member", "permission denied":
+		utils.Forbidden(c, "Permission denied")
+	case "wallet frozen":
+		utils.Forbidden(c, "Wallet is frozen")
+	case "insufficient balance":
+		utils.BadRequest(c, "Insufficient balance")
+	case "spending limit exceeded":
+		utils.BadRequest(c, "Spending limit exceeded")
+	case "not enough approvers":
+		utils.BadRequest(c, "Required approvals exceed the members able to approve")
+	case "last owner cannot be demoted":
+		utils.BadRequest(c, "Last owner cannot be demoted")
+	case "operation not pending":
+		utils.BadRequest(c, "Operation is not pending")
+	case "cannot approve own operation":
+		utils.BadRequest(c, "Cannot approve own operation")
+	case "already approved":
+		utils.BadRequest(c, "Operation already approved by this member")
+	default:
+		utils.InternalError(c, fallback)
+	}
+}
+
+// NewSharedWalletService creates shared wallet service instance
+func NewSharedWalletService() *service.SharedWalletServiceImpl {
+	return service.NewSharedWalletService()
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"wallet/models"
+	"wallet/service"
+	"wallet/statement"
+	"wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetStatement returns the statement of a user's wallet for [from, to) in csv, json
+// or pdf. Periods with many transactions are generated in the background: the
+// response is then 202 with a link to poll for and download the file.
+func GetStatement(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid user ID format")
+		return
+	}
+
+	from, err := parseTimeParam(c.Query("from"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid from time")
+		return
+	}
+	to, err := parseTimeParam(c.Query("to"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid to time")
+		return
+	}
+	format := c.DefaultQuery("format", statement.FormatJSON)
+
+	statementService := NewStatementService().WithContext(c.Request.Context())
+	st, job, err := statementService.Request(userID, c.Query("currency"), from, to, format)
+	if err != nil {
+		switch {
+		case errors.Is(err, statement.ErrUnsupportedFormat):
+			utils.BadRequest(c, "Unsupported format")
+		case err.Error() == "invalid period":
+			utils.BadRequest(c, "from must be before to")
+		case err.Error() == "wallet not found":
+			utils.NotFound(c, "Wallet not found")
+		default:
+			utils.InternalError(c, "Failed to generate statement")
+		}
+		return
+	}
+
+	if job != nil {
+		utils.Accepted(c, statementJobView(job))
+		return
+	}
+
+	if format == statement.FormatJSON {
+		utils.Success(c, st)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := statement.Write(&buf, st, format); err != nil {
+		utils.InternalError(c, "Failed to render statement")
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+statement.FileName(st.UserID, st.Currency, st.From, st.To, format)+`"`)
+	c.Data(http.StatusOK, statement.ContentType(format), buf.Bytes())
+}
+
+// GetStatementJob reports the status of a background statement
+func GetStatementJob(c *gin.Context) {
+	statementService := NewStatementService().WithContext(c.Request.Context())
+	job, err := statementService.GetJob(c.Param("token"))
+	if err != nil {
+		utils.NotFound(c, "Statement not found")
+		return
+	}
+
+	utils.Success(c, statementJobView(job))
+}
+
+// DownloadStatement serves the file of a ready background statement
+func DownloadStatement(c *gin.Context) {
+	statementService := NewStatementService().WithContext(c.Request.Context())
+	job, err := statementService.OpenDownload(c.Param("token"))
+	if err != nil {
+		switch err.Error() {
+		case "statement not found":
+			utils.NotFound(c, "Statement not found")
+		case "statement expired":
+			utils.Error(c, http.StatusGone, "Download link expired")
+		case "statement not ready":
+			utils.Error(c, http.StatusConflict, "Statement is not ready yet")
+		default:
+			utils.InternalError(c, "Failed to fetch statement")
+		}
+		return
+	}
+
+	c.Header("Content-Type", statement.ContentType(job.Format))
+	c.FileAttachment(job.FilePath, statement.FileName(job.UserID, job.Currency, job.PeriodFrom, job.PeriodTo, job.Format))
+}
+
+// statementJobView adds the status and download links to a background statement
+func statementJobView(job *models.StatementJobs) gin.H {
+	view := gin.H{
+		"job":        job,
+		"status_url": "/api/v1/statements/" + job.Token,
+	}
+	if job.Status == "ready" {
+		view["download_url"] = "/api/v1/statements/" + job.Token + "/download"
+	}
+	return view
+}
+
+// NewStatementService creates statement service instance
+func NewStatementService() *service.StatementServiceImpl {
+	return service.NewStatementService()
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wallet/config"
+	"wallet/middleware"
+	"wallet/stream"
+	"wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamUpdates streams the authenticated user's balance updates and new
+// transactions as Server-Sent Events; X-User-ID alone does not open a stream. A client reconnecting with Last-Event-ID
+// (or last_event_id) first receives the updates it missed.
+func StreamUpdates(c *gin.Context) {
+	userID, ok := middleware.AuthenticatedUserID(c)
+	if !ok {
+		utils.Unauthorized(c, "User authentication required")
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var lastSeq uint64
+	if lastEventID != "" {
+		var err error
+		if lastSeq, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			utils.BadRequest(c, "Invalid last event ID")
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	hub := stream.DefaultHub()
+
+	// Subscribe before reading the backlog so nothing committed in between is lost
+	sub := hub.Subscribe(userID)
+	defer hub.Unsubscribe(sub)
+
+	var backlog []stream.Update
+	complete := true
+	var newest uint
+	if lastEventID != "" {
+		var err error
+		if backlog, complete, err = hub.Backlog(ctx, userID, uint(lastSeq)); err == nil && !complete {
+			newest, err = hub.Newest(ctx)
+		}
+		if err != nil {
+			utils.InternalError(c, "Failed to replay events")
+			return
+		}
+	}
+
+	// The server's write timeout covers a whole response, which would cut the stream
+	// off; give each write its own deadline instead so dead clients are still dropped
+	rc := http.NewResponseController(c.Writer)
+	writeTimeout := config.GetConf().Http.WriteTimeout
+	extendDeadline := func() {
+		if writeTimeout > 0 {
+			rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+		}
+	}
+	extendDeadline()
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	stream.WriteRetry(w, 3*time.Second)
+	sent := make(map[uint]bool, len(backlog))
+	for _, update := range backlog {
+		stream.WriteUpdate(w, update)
+		sent[update.Seq] = true
+	}
+	if !complete {
+		// Too much was missed to replay; resume from now after the client reloads
+		stream.WriteEvent(w, fmt.Sprintf("%d", newest), stream.EventReset, []byte(`{"reason":"too many events to replay"}`))
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(config.GetConf().Stream.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, open := <-sub.C:
+			if !open {
+				// Dropped for falling behind, or shutting down; the client reconnects and replays
+				return
+			}
+			if sent[update.Seq] {
+				continue
+			}
+			extendDeadline()
+			if err := stream.WriteUpdate(w, update); err != nil {
+				return
+			}
+			w.Flush()
+		case <-heartbeat.C:
+			extendDeadline()
+			if err := stream.WriteHeartbeat(w); err != nil {
+				return
+			}
+			w.Flush()
+		}
+	}
+}
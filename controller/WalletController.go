@@ -1,8 +1,12 @@
 package controller
 
 import (
+	"errors"
 	"strconv"
+	"strings"
+	"time"
 
+	"wallet/middleware"
 	"wallet/service"
 	"wallet/utils"
 
@@ -23,13 +27,15 @@ func RegisterUser(c *gin.Context) {
 	}
 
 	// Use service layer for user registration
-	userService := NewUserService()
+	userService := NewUserService().WithContext(c.Request.Context())
 	if userService.UserExistsByEmail(req.Email) {
 		utils.BadRequest(c, "User already exists")
 		return
 	}
 
-	user, wallet, err := userService.RegisterUser(req.Username, req.Email)
+	// Users registered with an API key belong to that client and appear in its webhooks
+	clientID, _ := middleware.CurrentClientID(c)
+	user, wallet, err := userService.RegisterUser(req.Username, req.Email, clientID)
 	if err != nil {
 		utils.InternalError(c, "Failed to create user")
 		return
@@ -49,14 +55,18 @@ func GetBalance(c *gin.Context) {
 	}
 
 	// Use service layer to get balance
-	walletService := NewWalletService()
-	balance, err := walletService.GetBalance(userID)
+	walletService := NewWalletService().WithContext(c.Request.Context())
+	summary, err := walletService.GetBalanceSummary(userID, c.Query("currency"))
 	if err != nil {
-		utils.NotFound(c, "Wallet not found")
+		if err.Error() == "wallet not found" {
+			utils.NotFound(c, "Wallet not found")
+		} else {
+			utils.InternalError(c, "Failed to fetch balance")
+		}
 		return
 	}
 
-	utils.Success(c, gin.H{"balance": balance})
+	utils.Success(c, summary)
 }
 
 // Deposit adds funds to user's wallet
@@ -70,6 +80,7 @@ func Deposit(c *gin.Context) {
 
 	type DepositRequest struct {
 		Amount      float64 `json:"amount" binding:"required,gt=0"`
+		Currency    string  `json:"currency" binding:"omitempty,len=3"`
 		Description string  `json:"description"`
 	}
 
@@ -80,12 +91,17 @@ func Deposit(c *gin.Context) {
 	}
 
 	// Use service layer for deposit operation
-	walletService := NewWalletService()
-	balance, err := walletService.Deposit(userID, req.Amount, req.Description)
+	walletService := NewWalletService().WithContext(c.Request.Context())
+	balance, err := walletService.Deposit(userID, req.Currency, req.Amount, req.Description)
 	if err != nil {
-		if err.Error() == "wallet not found" {
+		switch err.Error() {
+		case "wallet not found":
 			utils.NotFound(c, "Wallet not found")
-		} else {
+		case "unsupported currency":
+			utils.BadRequest(c, "Unsupported currency")
+		case "wallet frozen":
+			utils.Forbidden(c, "Wallet is frozen")
+		default:
 			utils.InternalError(c, "Failed to deposit")
 		}
 		return
@@ -105,6 +121,7 @@ func Withdraw(c *gin.Context) {
 
 	type WithdrawRequest struct {
 		Amount      float64 `json:"amount" binding:"required,gt=0"`
+		Currency    string  `json:"currency" binding:"omitempty,len=3"`
 		Description string  `json:"description"`
 	}
 
@@ -115,14 +132,16 @@ func Withdraw(c *gin.Context) {
 	}
 
 	// Use service layer for withdrawal operation
-	walletService := NewWalletService()
-	balance, err := walletService.Withdraw(userID, req.Amount, req.Description)
+	walletService := NewWalletService().WithContext(c.Request.Context())
+	balance, err := walletService.Withdraw(userID, req.Currency, req.Amount, req.Description)
 	if err != nil {
 		switch err.Error() {
 		case "wallet not found":
 			utils.NotFound(c, "Wallet not found")
 		case "insufficient balance":
 			utils.BadRequest(c, "Insufficient balance")
+		case "wallet frozen":
+			utils.Forbidden(c, "Wallet is frozen")
 		default:
 			utils.InternalError(c, "Failed to withdraw")
 		}
@@ -138,6 +157,7 @@ func Transfer(c *gin.Context) {
 		FromUserID  string  `json:"from_user_id" binding:"required"`
 		ToUserID    string  `json:"to_user_id" binding:"required"`
 		Amount      float64 `json:"amount" binding:"required,gt=0"`
+		Currency    string  `json:"currency" binding:"omitempty,len=3"`
 		Description string  `json:"description"`
 	}
 
@@ -166,8 +186,8 @@ func Transfer(c *gin.Context) {
 	}
 
 	// Use service layer for transfer operation
-	walletService := NewWalletService()
-	fromBalance, toBalance, err := walletService.Transfer(fromUserID, toUserID, req.Amount, req.Description)
+	walletService := NewWalletService().WithContext(c.Request.Context())
+	fromBalance, toBalance, err := walletService.Transfer(fromUserID, toUserID, req.Currency, req.Amount, req.Description)
 	if err != nil {
 		switch err.Error() {
 		case "sender wallet not found":
@@ -176,6 +196,10 @@ func Transfer(c *gin.Context) {
 			utils.NotFound(c, "Recipient wallet not found")
 		case "insufficient balance":
 			utils.BadRequest(c, "Insufficient balance")
+		case "wallet frozen":
+			utils.Forbidden(c, "Wallet is frozen")
+		case "recipient wallet frozen":
+			utils.Forbidden(c, "Recipient wallet is frozen")
 		default:
 			utils.InternalError(c, "Failed to transfer")
 		}
@@ -188,6 +212,160 @@ func Transfer(c *gin.Context) {
 	})
 }
 
+// GetBalanceAsOf retrieves the wallet balance at a past time. at is an RFC3339
+// timestamp, or a YYYY-MM-DD date meaning the end of that day.
+func GetBalanceAsOf(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid user ID format")
+		return
+	}
+
+	value := c.Query("at")
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		day, dayErr := time.ParseInLocation("2006-01-02", value, time.Local)
+		if dayErr != nil {
+			utils.BadRequest(c, "Invalid at time")
+			return
+		}
+		at = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	snapshotService := NewSnapshotService()
+	balance, err := snapshotService.BalanceAt(userID, c.Query("currency"), at)
+	if err != nil {
+		if err.Error() == "wallet not found" {
+			utils.NotFound(c, "Wallet not found")
+		} else {
+			utils.InternalError(c, "Failed to compute balance")
+		}
+		return
+	}
+
+	utils.Success(c, balance)
+}
+
+// GetWalletEntries retrieves the most recent balance movements of a user's wallet
+func GetWalletEntries(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid user ID format")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > service.MaxPageLimit {
+		utils.BadRequest(c, "Invalid limit")
+		return
+	}
+
+	walletService := NewWalletService().WithContext(c.Request.Context())
+	entries, err := walletService.GetEntries(userID, c.Query("currency"), limit)
+	if err != nil {
+		if err.Error() == "wallet not found" {
+			utils.NotFound(c, "Wallet not found")
+		} else {
+			utils.InternalError(c, "Failed to fetch wallet entries")
+		}
+		return
+	}
+
+	utils.Success(c, entries)
+}
+
+// GetWallets retrieves all currency wallets of a user
+func GetWallets(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid user ID format")
+		return
+	}
+
+	walletService := NewWalletService().WithContext(c.Request.Context())
+	wallets, err := walletService.GetWallets(userID)
+	if err != nil {
+		if err.Error() == "wallet not found" {
+			utils.NotFound(c, "Wallet not found")
+		} else {
+			utils.InternalError(c, "Failed to fetch wallets")
+		}
+		return
+	}
+
+	utils.Success(c, wallets)
+}
+
+// SetOverdraftLimit sets the credit line of user's wallet
+func SetOverdraftLimit(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid user ID format")
+		return
+	}
+
+	type OverdraftRequest struct {
+		Limit    float64 `json:"limit" binding:"gte=0"`
+		Currency string  `json:"currency" binding:"omitempty,len=3"`
+	}
+
+	var req OverdraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	overdraftService := NewOverdraftService()
+	wallet, err := overdraftService.SetOverdraftLimit(userID, req.Currency, req.Limit)
+	if err != nil {
+		if err.Error() == "wallet not found" {
+			utils.NotFound(c, "Wallet not found")
+		} else {
+			utils.InternalError(c, "Failed to set overdraft limit")
+		}
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"balance":         wallet.Balance,
+		"overdraft_limit": wallet.OverdraftLimit,
+	})
+}
+
+// SetWalletFrozen freezes or unfreezes a user's wallet, e.g. once a reconciliation
+// discrepancy has been resolved
+func SetWalletFrozen(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		utils.BadRequest(c, "Invalid user ID format")
+		return
+	}
+
+	type FreezeRequest struct {
+		Frozen   *bool  `json:"frozen" binding:"required"`
+		Currency string `json:"currency" binding:"omitempty,len=3"`
+	}
+
+	var req FreezeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	walletService := NewWalletService().WithContext(c.Request.Context())
+	wallet, err := walletService.SetFrozen(userID, req.Currency, *req.Frozen)
+	if err != nil {
+		if err.Error() == "wallet not found" {
+			utils.NotFound(c, "Wallet not found")
+		} else {
+			utils.InternalError(c, "Failed to update wallet")
+		}
+		return
+	}
+
+	utils.Success(c, wallet)
+}
+
 // GetUser retrieves user information
 func GetUser(c *gin.Context) {
 	userID := c.Param("id")
@@ -200,7 +378,7 @@ func GetUser(c *gin.Context) {
 	}
 
 	// Use service layer to get user information
-	userService := NewUserService()
+	userService := NewUserService().WithContext(c.Request.Context())
 	user, err := userService.GetUserByID(idInt)
 	if err != nil {
 		utils.NotFound(c, "User not found")
@@ -213,7 +391,7 @@ func GetUser(c *gin.Context) {
 // GetAllUsers retrieves all users information
 func GetAllUsers(c *gin.Context) {
 	// Use service layer to get all users
-	userService := NewUserService()
+	userService := NewUserService().WithContext(c.Request.Context())
 	users, err := userService.GetAllUsers()
 	if err != nil {
 		utils.InternalError(c, "Failed to fetch users")
@@ -223,26 +401,154 @@ func GetAllUsers(c *gin.Context) {
 	utils.Success(c, users)
 }
 
-// GetUserTransactions retrieves user's transaction history
+// GetUserTransactions retrieves user's transaction history.
+// Supports filters type (comma separated), status, direction (in/out),
+// counterparty_id, currency, min_amount, max_amount, from and to (RFC3339 or
+// YYYY-MM-DD), and pagination by cursor or by page.
 func GetUserTransactions(c *gin.Context) {
-	userIDStr := c.Param("user_id")
+	userIDStr := c.Param("id")
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
 		utils.BadRequest(c, "Invalid user ID format")
 		return
 	}
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > service.MaxPageLimit {
+		utils.BadRequest(c, "Invalid limit")
+		return
+	}
+	pageReq := service.PageRequest{Cursor: c.Query("cursor"), Limit: limit}
+	if pageStr := c.Query("page"); pageStr != "" {
+		pageReq.Page, err = strconv.Atoi(pageStr)
+		if err != nil || pageReq.Page < 1 {
+			utils.BadRequest(c, "Invalid page")
+			return
+		}
+	}
+
+	filter, err := parseTransactionFilter(c)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
 
 	// Use service layer to get transaction records
-	transactionService := NewTransactionService()
-	transactions, err := transactionService.GetUserTransactions(userID, page, limit)
+	transactionService := NewTransactionService().WithContext(c.Request.Context())
+	page, err := transactionService.GetUserTransactions(userID, filter, pageReq)
 	if err != nil {
-		utils.InternalError(c, "Failed to fetch transactions")
+		if err.Error() == "invalid cursor" {
+			utils.BadRequest(c, "Invalid cursor")
+		} else {
+			utils.InternalError(c, "Failed to fetch transactions")
+		}
 		return
 	}
 
-	utils.Success(c, transactions)
+	utils.Success(c, page)
+}
+
+// GetTransaction retrieves one transaction with its state history, related
+// transactions and the counterparty. Only admins and the API client of its
+// parties may see it, see service.TransactionViewer.
+func GetTransaction(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid transaction ID format")
+		return
+	}
+
+	viewer := service.TransactionViewer{IsAdmin: middleware.IsAdmin(c)}
+	viewer.ClientID, _ = middleware.CurrentClientID(c)
+	viewer.UserID, _ = middleware.AuthenticatedUserID(c)
+	if _, ok := middleware.CurrentUserID(c); ok && viewer.UserID == 0 && !viewer.IsAdmin {
+		// An API key does not let its client act for users it did not register
+		utils.Forbidden(c, "Access denied")
+		return
+	}
+
+	transactionService := NewTransactionService().WithContext(c.Request.Context())
+	detail, err := transactionService.GetTransactionDetail(uint(id), viewer)
+	if err != nil {
+		switch err.Error() {
+		case "transaction not found":
+			utils.NotFound(c, "Transaction not found")
+		case "access denied":
+			utils.Forbidden(c, "Access denied")
+		default:
+			utils.InternalError(c, "Failed to fetch transaction")
+		}
+		return
+	}
+
+	utils.Success(c, detail)
+}
+
+// parseTransactionFilter reads transaction filters from the query string
+func parseTransactionFilter(c *gin.Context) (service.TransactionFilter, error) {
+	filter := service.TransactionFilter{
+		Status:   c.Query("status"),
+		Currency: c.Query("currency"),
+	}
+
+	if types := c.Query("type"); types != "" {
+		filter.Types = strings.Split(types, ",")
+	}
+
+	filter.Direction = c.Query("direction")
+	if filter.Direction != "" && filter.Direction != "in" && filter.Direction != "out" {
+		return filter, errors.New("Invalid direction")
+	}
+
+	if counterparty := c.Query("counterparty_id"); counterparty != "" {
+		id, err := strconv.Atoi(counterparty)
+		if err != nil {
+			return filter, errors.New("Invalid counterparty ID format")
+		}
+		filter.CounterpartyID = id
+	}
+
+	for _, bound := range []struct {
+		name string
+		dest **float64
+	}{{"min_amount", &filter.MinAmount}, {"max_amount", &filter.MaxAmount}} {
+		if value := c.Query(bound.name); value != "" {
+			amount, err := strconv.ParseFloat(value, 64)
+			if err != nil || amount < 0 {
+				return filter, errors.New("Invalid " + bound.name)
+			}
+			*bound.dest = &amount
+		}
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return filter, errors.New("min_amount must not exceed max_amount")
+	}
+
+	for _, bound := range []struct {
+		name string
+		dest **time.Time
+	}{{"from", &filter.CreatedFrom}, {"to", &filter.CreatedTo}} {
+		if value := c.Query(bound.name); value != "" {
+			t, err := parseTimeParam(value)
+			if err != nil {
+				return filter, errors.New("Invalid " + bound.name + " time")
+			}
+			*bound.dest = &t
+		}
+	}
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && !filter.CreatedFrom.Before(*filter.CreatedTo) {
+		return filter, errors.New("from must be before to")
+	}
+
+	return filter, nil
+}
+
+// parseTimeParam accepts RFC3339 timestamps or local YYYY-MM-DD dates
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
 }
 
 // NewUserService creates user service instance
@@ -259,3 +565,13 @@ func NewWalletService() *service.WalletServiceImpl {
 func NewTransactionService() *service.TransactionServiceImpl {
 	return service.NewTransactionService()
 }
+
+// NewOverdraftService creates overdraft service instance
+func NewOverdraftService() *service.OverdraftServiceImpl {
+	return service.NewOverdraftService()
+}
+
+// NewSnapshotService creates snapshot service instance
+func NewSnapshotService() *service.SnapshotServiceImpl {
+	return service.NewSnapshotService()
+}
@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"strconv"
+
+	"wallet/middleware"
+	"wallet/service"
+	"wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateWebhookSubscription subscribes a callback URL to events of the client's users.
+// The signing secret is only returned here.
+func CreateWebhookSubscription(c *gin.Context) {
+	type SubscriptionRequest struct {
+		URL        string   `json:"url" binding:"required"`
+		EventTypes []string `json:"event_types"` // empty for all event types
+	}
+
+	var req SubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	clientID, _ := middleware.CurrentClientID(c)
+	webhookService := NewWebhookService()
+	subscription, secret, err := webhookService.CreateSubscription(clientID, req.URL, req.EventTypes)
+	if err != nil {
+		respondWebhookError(c, err, "Failed to create subscription")
+		return
+	}
+
+	utils.Created(c, gin.H{"subscription": subscription, "secret": secret})
+}
+
+// GetWebhookSubscriptions lists the client's subscriptions
+func GetWebhookSubscriptions(c *gin.Context) {
+	clientID, _ := middleware.CurrentClientID(c)
+	webhookService := NewWebhookService()
+	subscriptions, err := webhookService.GetSubscriptions(clientID)
+	if err != nil {
+		utils.InternalError(c, "Failed to fetch subscriptions")
+		return
+	}
+
+	utils.Success(c, subscriptions)
+}
+
+// GetWebhookSubscription retrieves one of the client's subscriptions
+func GetWebhookSubscription(c *gin.Context) {
+	id, ok := webhookSubscriptionID(c)
+	if !ok {
+		return
+	}
+
+	clientID, _ := middleware.CurrentClientID(c)
+	webhookService := NewWebhookService()
+	subscription, err := webhookService.GetSubscription(clientID, id)
+	if err != nil {
+		respondWebhookError(c, err, "Failed to fetch subscription")
+		return
+	}
+
+	utils.Success(c, subscription)
+}
+
+// UpdateWebhookSubscription changes a subscription's URL or event types, or
+// disables it; setting active reactivates a dead-lettered subscription
+func UpdateWebhookSubscription(c *gin.Context) {
+	type UpdateRequest struct {
+		URL        *string  `json:"url"`
+		EventTypes []string `json:"event_types"`
+		Active     *bool    `json:"active"`
+	}
+
+	id, ok := webhookSubscriptionID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	clientID, _ := middleware.CurrentClientID(c)
+	webhookService := NewWebhookService()
+	subscription, err := webhookService.UpdateSubscription(clientID, id, req.URL, req.EventTypes, req.Active)
+	if err != nil {
+		respondWebhookError(c, err, "Failed to update subscription")
+		return
+	}
+
+	utils.Success(c, subscription)
+}
+
+// DeleteWebhookSubscription removes a subscription
+func DeleteWebhookSubscription(c *gin.Context) {
+	id, ok := webhookSubscriptionID(c)
+	if !ok {
+		return
+	}
+
+	clientID, _ := middleware.CurrentClientID(c)
+	webhookService := NewWebhookService()
+	if err := webhookService.DeleteSubscription(clientID, id); err != nil {
+		respondWebhookError(c, err, "Failed to delete subscription")
+		return
+	}
+
+	utils.Success(c, gin.H{"id": id})
+}
+
+// GetWebhookDeliveries lists a subscription's delivery log, optionally filtered by status
+func GetWebhookDeliveries(c *gin.Context) {
+	id, ok := webhookSubscriptionID(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		utils.BadRequest(c, "Invalid limit")
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", "pending", "succeeded", "dead":
+	default:
+		utils.BadRequest(c, "Invalid status")
+		return
+	}
+
+	clientID, _ := middleware.CurrentClientID(c)
+	webhookService := NewWebhookService()
+	deliveries, err := webhookService.GetDeliveries(clientID, id, status, limit)
+	if err != nil {
+		respondWebhookError(c, err, "Failed to fetch deliveries")
+		return
+	}
+
+	utils.Success(c, deliveries)
+}
+
+// GetWebhookDelivery retrieves a delivery with every attempt made
+func GetWebhookDelivery(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid delivery ID format")
+		return
+	}
+
+	clientID, _ := middleware.CurrentClientID(c)
+	webhookService := NewWebhookService()
+	delivery, err := webhookService.GetDelivery(clientID, uint(id))
+	if err != nil {
+		respondWebhookError(c, err, "Failed to fetch delivery")
+		return
+	}
+
+	utils.Success(c, delivery)
+}
+
+// ReplayWebhookEvent queues an event for the subscription again
+func ReplayWebhookEvent(c *gin.Context) {
+	type ReplayRequest struct {
+		EventID string `json:"event_id" binding:"required"`
+	}
+
+	id, ok := webhookSubscriptionID(c)
+	if !ok {
+		return
+	}
+
+	var req ReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "Invalid request parameters")
+		return
+	}
+
+	clientID, _ := middleware.CurrentClientID(c)
+	webhookService := NewWebhookService()
+	delivery, err := webhookService.Replay(clientID, id, req.EventID)
+	if err != nil {
+		respondWebhookError(c, err, "Failed to replay event")
+		return
+	}
+
+	utils.Accepted(c, delivery)
+}
+
+// webhookSubscriptionID parses the subscription ID path parameter, responding on failure
+func webhookSubscriptionID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		utils.BadRequest(c, "Invalid subscription ID format")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondWebhookError maps webhook service errors to responses
+func respondWebhookError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "subscription not found":
+		utils.NotFound(c, "Subscription not found")
+	case "delivery not found":
+		utils.NotFound(c, "Delivery not found")
+	case "event not found":
+		utils.NotFound(c, "Event not found")
+	case "invalid url":
+		utils.BadRequest(c, "Invalid URL")
+	case "url not allowed":
+		utils.BadRequest(c, "URL must resolve to a public address")
+	case "unknown event type":
+		utils.BadRequest(c, "Unknown event type")
+	default:
+		utils.InternalError(c, fallback)
+	}
+}
+
+// NewWebhookService creates webhook service instance
+func NewWebhookService() *service.WebhookServiceImpl {
+	return service.NewWebhookService()
+}
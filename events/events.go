@@ -0,0 +1,206 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet/models"
+
+	"gorm.io/gorm"
+)
+
+// Domain event types
+const (
+	UserRegistered           = "UserRegistered"
+	Deposited                = "Deposited"
+	Withdrawn                = "Withdrawn"
+	Transferred              = "Transferred"
+	PocketMoved              = "PocketMoved"
+	SharedWalletDeposited    = "SharedWalletDeposited"
+	SharedWalletSpent        = "SharedWalletSpent"
+	CurrencyExchanged        = "CurrencyExchanged"
+	InterestPaid             = "InterestPaid"
+	OverdraftInterestCharged = "OverdraftInterestCharged"
+)
+
+// Types lists every domain event type
+var Types = []string{
+	UserRegistered, Deposited, Withdrawn, Transferred, PocketMoved, SharedWalletDeposited,
+	SharedWalletSpent, CurrencyExchanged, InterestPaid, OverdraftInterestCharged,
+}
+
+// Key kinds, the part of an ordering key before the colon
+const (
+	KeyWallet       = "wallet"
+	KeySharedWallet = "shared_wallet"
+	KeyUser         = "user"
+)
+
+// Event is a domain event as delivered to sinks
+type Event struct {
+	ID         string          `json:"id"` // stable across redeliveries, for consumer deduplication
+	Seq        uint            `json:"seq"`
+	Type       string          `json:"type"`
+	Keys       []string        `json:"keys"` // events sharing a key are delivered in order
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// UserRegisteredData is the payload of UserRegistered
+type UserRegisteredData struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	WalletID uint   `json:"wallet_id"`
+	Currency string `json:"currency"`
+}
+
+// BalanceChangedData is the payload of Deposited, Withdrawn, InterestPaid and OverdraftInterestCharged
+type BalanceChangedData struct {
+	TransactionID uint    `json:"transaction_id"`
+	UserID        int     `json:"user_id"`
+	WalletID      uint    `json:"wallet_id"`
+	Currency      string  `json:"currency"`
+	Amount        float64 `json:"amount"`
+	Balance       float64 `json:"balance"` // balance after the movement
+	Description   string  `json:"description,omitempty"`
+}
+
+// TransferredData is the payload of Transferred
+type TransferredData struct {
+	TransactionID uint    `json:"transaction_id"`
+	FromUserID    int     `json:"from_user_id"`
+	ToUserID      int     `json:"to_user_id"`
+	FromWalletID  uint    `json:"from_wallet_id"`
+	ToWalletID    uint    `json:"to_wallet_id"`
+	Currency      string  `json:"currency"`
+	Amount        float64 `json:"amount"`
+	FromBalance   float64 `json:"from_balance"`
+	ToBalance     float64 `json:"to_balance"`
+	Description   string  `json:"description,omitempty"`
+}
+
+// PocketMovedData is the payload of PocketMoved
+type PocketMovedData struct {
+	TransactionID uint    `json:"transaction_id"`
+	UserID        int     `json:"user_id"`
+	WalletID      uint    `json:"wallet_id"`
+	PocketID      uint    `json:"pocket_id"`
+	Currency      string  `json:"currency"`
+	Direction     string  `json:"direction"` // in, out of the pocket
+	Amount        float64 `json:"amount"`
+	Balance       float64 `json:"balance"`
+	PocketBalance float64 `json:"pocket_balance"`
+}
+
+// SharedWalletMovedData is the payload of SharedWalletDeposited and SharedWalletSpent
+type SharedWalletMovedData struct {
+	TransactionID  uint    `json:"transaction_id"`
+	SharedWalletID uint    `json:"shared_wallet_id"`
+	OperationID    uint    `json:"operation_id,omitempty"`
+	Type           string  `json:"type"` // deposit, withdraw, transfer
+	UserID         int     `json:"user_id,omitempty"`
+	WalletID       uint    `json:"wallet_id,omitempty"` // member wallet funded or paid
+	Currency       string  `json:"currency"`
+	Amount         float64 `json:"amount"`
+	Balance        float64 `json:"balance,omitempty"` // balance of WalletID after the movement
+	SharedBalance  float64 `json:"shared_balance"`
+}
+
+// CurrencyExchangedData is the payload of CurrencyExchanged
+type CurrencyExchangedData struct {
+	ExchangeID          uint    `json:"exchange_id"`
+	DebitTransactionID  uint    `json:"debit_transaction_id"`
+	CreditTransactionID uint    `json:"credit_transaction_id"`
+	UserID              int     `json:"user_id"`
+	FromWalletID        uint    `json:"from_wallet_id"`
+	ToWalletID          uint    `json:"to_wallet_id"`
+	FromCurrency        string  `json:"from_currency"`
+	ToCurrency          string  `json:"to_currency"`
+	FromAmount          float64 `json:"from_amount"`
+	ToAmount            float64 `json:"to_amount"`
+	Rate                float64 `json:"rate"`
+	FromBalance         float64 `json:"from_balance"`
+	ToBalance           float64 `json:"to_balance"`
+}
+
+// WalletKey is the ordering key of events touching a wallet
+func WalletKey(walletID uint) string {
+	return fmt.Sprintf("%s:%d", KeyWallet, walletID)
+}
+
+// SharedWalletKey is the ordering key of events touching a shared wallet
+func SharedWalletKey(sharedWalletID uint) string {
+	return fmt.Sprintf("%s:%d", KeySharedWallet, sharedWalletID)
+}
+
+// UserKey is the ordering key of events about a user
+func UserKey(userID int) string {
+	return fmt.Sprintf("%s:%d", KeyUser, userID)
+}
+
+// ParseKey splits an ordering key such as wallet:3 into its kind and ID
+func ParseKey(key string) (string, uint, bool) {
+	kind, id, found := strings.Cut(key, ":")
+	if !found {
+		return "", 0, false
+	}
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return kind, uint(n), true
+}
+
+// Record writes an event to the outbox through tx, so it is stored if and only if
+// the surrounding transaction commits. keys order delivery relative to other events.
+func Record(tx *gorm.DB, eventType string, keys []string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	id, err := newEventID()
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.OutboxEvents{
+		EventID:      id,
+		Type:         eventType,
+		OrderingKeys: strings.Join(keys, ","),
+		Payload:      string(payload),
+		Status:       "pending",
+	}).Error
+}
+
+// FromOutbox converts a stored outbox row into the event delivered to sinks
+func FromOutbox(row *models.OutboxEvents) Event {
+	event := Event{
+		ID:         row.EventID,
+		Seq:        row.ID,
+		Type:       row.Type,
+		Keys:       []string{},
+		OccurredAt: row.CreatedAt,
+		Data:       json.RawMessage(row.Payload),
+	}
+	if row.OrderingKeys != "" {
+		event.Keys = strings.Split(row.OrderingKeys, ",")
+	}
+	return event
+}
+
+// newEventID returns a random UUID (version 4)
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
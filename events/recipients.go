@@ -0,0 +1,76 @@
+package events
+
+import (
+	"wallet/models"
+
+	"gorm.io/gorm"
+)
+
+// Recipients returns, for each event, the users it concerns mapped to the keys
+// linking them to it: the owner of a wallet key, the members of a shared wallet
+// key and the user of a user key.
+func Recipients(db *gorm.DB, events []Event) ([]map[int][]string, error) {
+	var walletIDs, sharedWalletIDs []uint
+	for _, event := range events {
+		for _, key := range event.Keys {
+			kind, id, ok := ParseKey(key)
+			if !ok {
+				continue
+			}
+			switch kind {
+			case KeyWallet:
+				walletIDs = append(walletIDs, id)
+			case KeySharedWallet:
+				sharedWalletIDs = append(sharedWalletIDs, id)
+			}
+		}
+	}
+
+	owners := make(map[uint]int)
+	if len(walletIDs) > 0 {
+		var wallets []models.Wallets
+		if err := db.Select("id", "user_id").Where("id IN ?", walletIDs).Find(&wallets).Error; err != nil {
+			return nil, err
+		}
+		for _, wallet := range wallets {
+			owners[wallet.ID] = wallet.UserID
+		}
+	}
+
+	members := make(map[uint][]int)
+	if len(sharedWalletIDs) > 0 {
+		var rows []models.SharedWalletMembers
+		if err := db.Select("shared_wallet_id", "user_id").Where("shared_wallet_id IN ?", sharedWalletIDs).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			members[row.SharedWalletID] = append(members[row.SharedWalletID], row.UserID)
+		}
+	}
+
+	recipients := make([]map[int][]string, len(events))
+	for i, event := range events {
+		users := make(map[int][]string)
+		for _, key := range event.Keys {
+			kind, id, ok := ParseKey(key)
+			if !ok {
+				continue
+			}
+			switch kind {
+			case KeyWallet:
+				if userID, found := owners[id]; found {
+					users[userID] = append(users[userID], key)
+				}
+			case KeySharedWallet:
+				for _, userID := range members[id] {
+					users[userID] = append(users[userID], key)
+				}
+			case KeyUser:
+				users[int(id)] = append(users[int(id)], key)
+			}
+		}
+		recipients[i] = users
+	}
+
+	return recipients, nil
+}
@@ -0,0 +1,227 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"wallet/config"
+	"wallet/models"
+
+	"gorm.io/gorm"
+)
+
+// relayLockID is the ID of the row leased by the relay
+const relayLockID = 1
+
+// relayLease is how long the relay holding the lease keeps it without renewing.
+// It is renewed between events, so it only runs out when that relay stops.
+const relayLease = time.Minute
+
+// Relay publishes outbox events to sinks. An event is marked published only after
+// every sink accepted it, so delivery is at least once. When an event fails, later
+// events sharing one of its keys wait for it, keeping per-wallet order.
+type Relay struct {
+	mu         sync.RWMutex
+	sinks      []Sink
+	batchSize  int
+	maxBackoff time.Duration
+	owner      string // identifies this relay in the lease row
+}
+
+// NewRelay creates a relay publishing to sinks
+func NewRelay(sinks []Sink, batchSize int, maxBackoff time.Duration) *Relay {
+	return &Relay{sinks: sinks, batchSize: batchSize, maxBackoff: maxBackoff, owner: newRelayOwner()}
+}
+
+// AddSink registers another sink; it receives events published from then on
+func (r *Relay) AddSink(sink Sink) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sinks = append(r.sinks, sink)
+}
+
+// RunOnce publishes one batch of due events and returns how many were published.
+// Only the relay holding the lease publishes; others return at once. No database
+// transaction or row lock is held while sinks are called: each event's outcome is
+// saved on its own after its sinks return.
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	db := config.GetDB().WithContext(ctx)
+	now := time.Now()
+	leaseUntil, err := r.acquire(db, now)
+	if err != nil || leaseUntil.IsZero() {
+		return 0, err
+	}
+	defer r.release()
+
+	// Events in backoff hold back the later events sharing their keys
+	var waiting []models.OutboxEvents
+	if err := db.Select("id", "ordering_keys").
+		Where("status = ? AND next_attempt_at > ?", "pending", now).
+		Find(&waiting).Error; err != nil {
+		return 0, err
+	}
+	blocked := make(map[string]uint)
+	for i := range waiting {
+		block(blocked, FromOutbox(&waiting[i]).Keys, waiting[i].ID)
+	}
+
+	r.mu.RLock()
+	sinks := r.sinks
+	r.mu.RUnlock()
+
+	// Rows not yet due are left out in SQL, and rows held back by a key are skipped
+	// without counting towards the batch, so neither can stall the other events
+	attempted, published := 0, 0
+	var lastID uint
+	for attempted < r.batchSize && ctx.Err() == nil {
+		var rows []models.OutboxEvents
+		if err := db.Where("status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?) AND id > ?", "pending", now, lastID).
+			Order("id ASC").Limit(r.batchSize).Find(&rows).Error; err != nil {
+			return published, err
+		}
+
+		for i := range rows {
+			if attempted >= r.batchSize || ctx.Err() != nil {
+				break
+			}
+			row := &rows[i]
+			lastID = row.ID
+			event := FromOutbox(row)
+			if isBlocked(blocked, event.Keys, row.ID) {
+				continue
+			}
+
+			if time.Until(leaseUntil) < relayLease/2 {
+				if leaseUntil, err = r.acquire(db, time.Now()); err != nil || leaseUntil.IsZero() {
+					return published, err
+				}
+			}
+
+			attempted++
+			updates := map[string]interface{}{}
+			if err := publish(ctx, sinks, event); err != nil {
+				block(blocked, event.Keys, row.ID)
+				row.Attempts++
+				updates["attempts"] = row.Attempts
+				updates["last_error"] = truncate(err.Error(), 255)
+				updates["next_attempt_at"] = time.Now().Add(r.backoff(row.Attempts))
+			} else {
+				updates["status"] = "published"
+				updates["published_at"] = time.Now()
+				published++
+			}
+			if err := db.Model(row).Updates(updates).Error; err != nil {
+				return published, err
+			}
+		}
+
+		if len(rows) < r.batchSize {
+			break
+		}
+	}
+
+	return published, nil
+}
+
+// acquire takes or renews the publishing lease, returning when it runs out, or the
+// zero time when another relay holds it
+func (r *Relay) acquire(db *gorm.DB, now time.Time) (time.Time, error) {
+	until := now.Add(relayLease)
+	if err := db.Model(&models.OutboxRelayLock{}).
+		Where("id = ? AND (owner = ? OR locked_until IS NULL OR locked_until < ?)", relayLockID, r.owner, now).
+		Updates(map[string]interface{}{"owner": r.owner, "locked_until": until}).Error; err != nil {
+		return time.Time{}, err
+	}
+
+	var lock models.OutboxRelayLock
+	if err := db.Where("id = ?", relayLockID).First(&lock).Error; err != nil {
+		return time.Time{}, err
+	}
+	if lock.Owner != r.owner {
+		return time.Time{}, nil
+	}
+	return until, nil
+}
+
+// release gives up the lease so another relay can publish without waiting for it to run out
+func (r *Relay) release() {
+	config.GetDB().Model(&models.OutboxRelayLock{}).
+		Where("id = ? AND owner = ?", relayLockID, r.owner).
+		Update("locked_until", nil)
+}
+
+// Run publishes batches until none is full, so a backlog drains within one call
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		published, err := r.RunOnce(ctx)
+		if err != nil {
+			return err
+		}
+		if published < r.batchSize || ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// publish hands event to every sink, stopping at the first failure
+func publish(ctx context.Context, sinks []Sink, event Event) error {
+	for _, sink := range sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			return fmt.Errorf("%s: %w", sink.Name(), err)
+		}
+	}
+	return nil
+}
+
+// backoff returns the delay before retry number attempts, doubling from one second
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempts && delay < r.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > r.maxBackoff {
+		delay = r.maxBackoff
+	}
+	return delay
+}
+
+// isBlocked reports whether any key of the event with the given ID waits for an
+// earlier event that failed
+func isBlocked(blocked map[string]uint, keys []string, id uint) bool {
+	for _, key := range keys {
+		if from, ok := blocked[key]; ok && from < id {
+			return true
+		}
+	}
+	return false
+}
+
+// block holds back the events after id sharing keys
+func block(blocked map[string]uint, keys []string, id uint) {
+	for _, key := range keys {
+		if from, ok := blocked[key]; !ok || id < from {
+			blocked[key] = id
+		}
+	}
+}
+
+// newRelayOwner returns a random ID for a relay's lease
+func newRelayOwner() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("relay-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
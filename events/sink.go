@@ -0,0 +1,113 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"wallet/config"
+)
+
+// Sink receives published events. Publish may be called again with an event it
+// already accepted, so sinks and their consumers must tolerate duplicates.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event Event) error
+}
+
+// LogSink writes each event as a JSON log line
+type LogSink struct{}
+
+// Name identifies the sink in relay errors
+func (LogSink) Name() string {
+	return "log"
+}
+
+// Publish logs the event
+func (LogSink) Publish(ctx context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	log.Printf("event %s", line)
+	return nil
+}
+
+// HTTPSink POSTs each event as JSON to a URL; any non-2xx response is a failure
+type HTTPSink struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewHTTPSink creates a sink posting to url with the given request timeout
+func NewHTTPSink(url string, timeout time.Duration) *HTTPSink {
+	return &HTTPSink{URL: url, Client: &http.Client{Timeout: timeout}}
+}
+
+// Name identifies the sink in relay errors
+func (s *HTTPSink) Name() string {
+	return "http " + s.URL
+}
+
+// Publish posts the event
+func (s *HTTPSink) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", event.ID)
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SinkFunc adapts a function to a Sink
+type SinkFunc func(ctx context.Context, event Event) error
+
+// Name identifies the sink in relay errors
+func (f SinkFunc) Name() string {
+	return "func"
+}
+
+// Publish calls f
+func (f SinkFunc) Publish(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// SinksFromConfig builds the sinks listed in the outbox configuration
+func SinksFromConfig(conf config.OutboxConf) ([]Sink, error) {
+	sinks := make([]Sink, 0, len(conf.Sinks))
+	for _, sinkConf := range conf.Sinks {
+		switch sinkConf.Type {
+		case "log":
+			sinks = append(sinks, LogSink{})
+		case "http":
+			if sinkConf.URL == "" {
+				return nil, fmt.Errorf("http sink requires a url")
+			}
+			sinks = append(sinks, NewHTTPSink(sinkConf.URL, sinkConf.Timeout))
+		default:
+			return nil, fmt.Errorf("unknown sink type %q", sinkConf.Type)
+		}
+	}
+	return sinks, nil
+}
@@ -0,0 +1,267 @@
+package eventstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"wallet/config"
+	"wallet/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// loadBatch is the number of events read at a time when loading a stream
+const loadBatch = 500
+
+// Append appends changes to wallet's event stream inside tx, the transaction that
+// changed the wallet. wallet must be locked (or created) by tx and already hold the
+// state after the changes; its Version is advanced. Credits and debits are also
+// projected into wallet_entries, and a snapshot is saved every
+// event_store.snapshot_every events.
+//
+// A wallet still at version 0 (not yet imported) is opened first with its state
+// before the changes.
+func Append(tx *gorm.DB, wallet *models.Wallets, changes ...Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	if wallet.Version == 0 && changes[0].Type != WalletOpened {
+		changes = append([]Change{openedBefore(wallet, changes)}, changes...)
+	}
+
+	from := wallet.Version
+	for _, change := range changes {
+		payload, err := json.Marshal(change.Data)
+		if err != nil {
+			return err
+		}
+
+		event := models.WalletEvents{
+			WalletID:      wallet.ID,
+			Version:       wallet.Version + 1,
+			Type:          change.Type,
+			TransactionID: change.TransactionID,
+			Data:          string(payload),
+		}
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		wallet.Version = event.Version
+
+		if entry := project(wallet.UserID, wallet.Currency, &event, change.Data); entry != nil {
+			if err := tx.Create(entry).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Model(&models.Wallets{}).Where("id = ?", wallet.ID).UpdateColumn("version", wallet.Version).Error; err != nil {
+		return err
+	}
+
+	every := config.GetConf().EventStore.SnapshotEvery
+	if every > 0 && from/every != wallet.Version/every {
+		snapshot := &models.WalletSnapshots{
+			WalletID:       wallet.ID,
+			Version:        wallet.Version,
+			UserID:         wallet.UserID,
+			Currency:       wallet.Currency,
+			Balance:        wallet.Balance,
+			OverdraftLimit: wallet.OverdraftLimit,
+			Frozen:         wallet.Frozen,
+		}
+		if err := tx.Create(snapshot).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Load rebuilds a wallet's aggregate from its event stream, starting from its
+// latest snapshot when useSnapshot is set. It returns the aggregate and the number
+// of events applied.
+func Load(db *gorm.DB, walletID uint, useSnapshot bool) (*Wallet, int, error) {
+	wallet := &Wallet{ID: walletID}
+	if useSnapshot {
+		var snapshot models.WalletSnapshots
+		result := db.Where("wallet_id = ?", walletID).Order("version DESC").Limit(1).Find(&snapshot)
+		if result.Error != nil {
+			return nil, 0, result.Error
+		}
+		if result.RowsAffected > 0 {
+			wallet = FromSnapshot(&snapshot)
+		}
+	}
+
+	applied, err := replay(db, wallet, nil)
+	if err != nil {
+		return nil, applied, err
+	}
+	if wallet.Version == 0 {
+		return nil, 0, errors.New("wallet has no events")
+	}
+	return wallet, applied, nil
+}
+
+// Rebuild replays a wallet's whole stream and rewrites its wallet_entries from it
+// inside tx. It returns the aggregate and the number of events applied.
+func Rebuild(tx *gorm.DB, walletID uint) (*Wallet, int, error) {
+	if err := tx.Where("wallet_id = ?", walletID).Delete(&models.WalletEntries{}).Error; err != nil {
+		return nil, 0, err
+	}
+
+	wallet := &Wallet{ID: walletID}
+	var pending []models.WalletEntries
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		err := tx.CreateInBatches(pending, loadBatch).Error
+		pending = pending[:0]
+		return err
+	}
+
+	applied, err := replay(tx, wallet, func(event *models.WalletEvents) error {
+		if event.Type != FundsCredited && event.Type != FundsDebited {
+			return nil
+		}
+		var data FundsData
+		if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+			return err
+		}
+		pending = append(pending, *project(wallet.UserID, wallet.Currency, event, data))
+		if len(pending) >= loadBatch {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, applied, err
+	}
+	if err := flush(); err != nil {
+		return nil, applied, err
+	}
+	if wallet.Version == 0 {
+		return nil, 0, errors.New("wallet has no events")
+	}
+	return wallet, applied, nil
+}
+
+// SaveSnapshot stores the aggregate's current state as a snapshot, unless one
+// already exists at that version
+func SaveSnapshot(tx *gorm.DB, wallet *Wallet) error {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(wallet.Snapshot()).Error
+}
+
+// ImportLegacy opens the event stream of every wallet created before the event
+// store, with its current state. It is safe to run repeatedly and concurrently
+// with traffic, and returns the number of wallets imported.
+func ImportLegacy(db *gorm.DB) (int, error) {
+	var ids []uint
+	if err := db.Model(&models.Wallets{}).Where("version = ?", 0).Order("id ASC").Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	for _, id := range ids {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var wallet models.Wallets
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&wallet).Error; err != nil {
+				return err
+			}
+			if wallet.Version != 0 {
+				return nil // imported meanwhile
+			}
+			if err := Append(tx, &wallet, Opened(&wallet)); err != nil {
+				return err
+			}
+			imported++
+			return nil
+		})
+		if err != nil {
+			return imported, fmt.Errorf("import wallet %d: %w", id, err)
+		}
+	}
+
+	return imported, nil
+}
+
+// replay applies the events of wallet's stream after its version, calling visit
+// after each one, and returns the number applied
+func replay(db *gorm.DB, wallet *Wallet, visit func(*models.WalletEvents) error) (int, error) {
+	applied := 0
+	for {
+		var batch []models.WalletEvents
+		if err := db.Where("wallet_id = ? AND version > ?", wallet.ID, wallet.Version).
+			Order("version ASC").Limit(loadBatch).Find(&batch).Error; err != nil {
+			return applied, err
+		}
+
+		for i := range batch {
+			if err := wallet.Apply(&batch[i]); err != nil {
+				return applied, err
+			}
+			applied++
+			if visit != nil {
+				if err := visit(&batch[i]); err != nil {
+					return applied, err
+				}
+			}
+		}
+
+		if len(batch) < loadBatch {
+			return applied, nil
+		}
+	}
+}
+
+// openedBefore returns the opening event of a wallet not yet in the event store,
+// with its balance before changes
+func openedBefore(wallet *models.Wallets, changes []Change) Change {
+	opened := Opened(wallet)
+	data := opened.Data.(OpenedData)
+	for _, change := range changes {
+		funds, ok := change.Data.(FundsData)
+		if !ok {
+			continue
+		}
+		if change.Type == FundsCredited {
+			data.Balance -= funds.Amount
+		} else {
+			data.Balance += funds.Amount
+		}
+	}
+	data.Balance = roundCents(data.Balance)
+	opened.Data = data
+	return opened
+}
+
+// project returns the wallet_entries row of a credit or debit, nil for other events
+func project(userID int, currency string, event *models.WalletEvents, data interface{}) *models.WalletEntries {
+	funds, ok := data.(FundsData)
+	if !ok {
+		return nil
+	}
+
+	amount := funds.Amount
+	if event.Type == FundsDebited {
+		amount = -amount
+	}
+	entry := &models.WalletEntries{
+		UserID:          userID,
+		WalletID:        event.WalletID,
+		Version:         event.Version,
+		TransactionID:   event.TransactionID,
+		TransactionType: funds.TransactionType,
+		Currency:        currency,
+		Amount:          amount,
+		BalanceAfter:    funds.Balance,
+	}
+	if !event.CreatedAt.IsZero() {
+		entry.CreatedAt = event.CreatedAt
+	}
+	return entry
+}
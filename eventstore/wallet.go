@@ -0,0 +1,197 @@
+package eventstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"wallet/models"
+)
+
+// Wallet event types
+const (
+	WalletOpened      = "WalletOpened"
+	FundsCredited     = "FundsCredited"
+	FundsDebited      = "FundsDebited"
+	OverdraftLimitSet = "OverdraftLimitSet"
+	FreezeSet         = "FreezeSet"
+)
+
+// OpenedData is the payload of WalletOpened. Wallets that existed before the
+// event store open with their balance at import time.
+type OpenedData struct {
+	UserID         int     `json:"user_id"`
+	Currency       string  `json:"currency"`
+	Balance        float64 `json:"balance"`
+	OverdraftLimit float64 `json:"overdraft_limit"`
+	Frozen         bool    `json:"frozen"`
+}
+
+// FundsData is the payload of FundsCredited and FundsDebited
+type FundsData struct {
+	TransactionType string  `json:"transaction_type"`
+	Amount          float64 `json:"amount"`  // always positive
+	Balance         float64 `json:"balance"` // balance after the movement, checked on replay
+}
+
+// OverdraftLimitData is the payload of OverdraftLimitSet
+type OverdraftLimitData struct {
+	Limit float64 `json:"limit"`
+}
+
+// FreezeData is the payload of FreezeSet
+type FreezeData struct {
+	Frozen bool `json:"frozen"`
+}
+
+// Change is an event about to be appended to a wallet's stream
+type Change struct {
+	Type          string
+	TransactionID uint
+	Data          interface{}
+}
+
+// Opened records a new wallet, or an existing one entering the event store
+func Opened(wallet *models.Wallets) Change {
+	return Change{Type: WalletOpened, Data: OpenedData{
+		UserID:         wallet.UserID,
+		Currency:       wallet.Currency,
+		Balance:        wallet.Balance,
+		OverdraftLimit: wallet.OverdraftLimit,
+		Frozen:         wallet.Frozen,
+	}}
+}
+
+// Credited records transaction paying into wallet, whose balance already includes it
+func Credited(wallet *models.Wallets, transaction *models.Transaction) Change {
+	return Change{Type: FundsCredited, TransactionID: transaction.ID, Data: FundsData{
+		TransactionType: transaction.Type,
+		Amount:          transaction.Amount,
+		Balance:         wallet.Balance,
+	}}
+}
+
+// Debited records transaction paid from wallet, whose balance already excludes it
+func Debited(wallet *models.Wallets, transaction *models.Transaction) Change {
+	return Change{Type: FundsDebited, TransactionID: transaction.ID, Data: FundsData{
+		TransactionType: transaction.Type,
+		Amount:          transaction.Amount,
+		Balance:         wallet.Balance,
+	}}
+}
+
+// LimitSet records a new overdraft limit
+func LimitSet(limit float64) Change {
+	return Change{Type: OverdraftLimitSet, Data: OverdraftLimitData{Limit: limit}}
+}
+
+// Frozen records the wallet being frozen or unfrozen
+func Frozen(frozen bool) Change {
+	return Change{Type: FreezeSet, Data: FreezeData{Frozen: frozen}}
+}
+
+// Wallet is the wallet aggregate: the state obtained by applying its events in order
+type Wallet struct {
+	ID             uint    `json:"id"`
+	UserID         int     `json:"user_id"`
+	Currency       string  `json:"currency"`
+	Balance        float64 `json:"balance"`
+	OverdraftLimit float64 `json:"overdraft_limit"`
+	Frozen         bool    `json:"frozen"`
+	Version        uint    `json:"version"`
+}
+
+// FromSnapshot restores the aggregate saved in a snapshot
+func FromSnapshot(snapshot *models.WalletSnapshots) *Wallet {
+	return &Wallet{
+		ID:             snapshot.WalletID,
+		UserID:         snapshot.UserID,
+		Currency:       snapshot.Currency,
+		Balance:        snapshot.Balance,
+		OverdraftLimit: snapshot.OverdraftLimit,
+		Frozen:         snapshot.Frozen,
+		Version:        snapshot.Version,
+	}
+}
+
+// Apply applies the next event of the wallet's stream. It fails when the event is
+// out of sequence or a movement does not lead to the balance it recorded, which
+// means the stream was altered.
+func (w *Wallet) Apply(event *models.WalletEvents) error {
+	if event.Version != w.Version+1 {
+		return fmt.Errorf("event %d: expected version %d, got %d", event.ID, w.Version+1, event.Version)
+	}
+	if w.Version == 0 && event.Type != WalletOpened {
+		return fmt.Errorf("event %d: stream does not start with %s", event.ID, WalletOpened)
+	}
+
+	switch event.Type {
+	case WalletOpened:
+		if w.Version != 0 {
+			return fmt.Errorf("event %d: wallet opened twice", event.ID)
+		}
+		var data OpenedData
+		if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+			return fmt.Errorf("event %d: %w", event.ID, err)
+		}
+		w.ID = event.WalletID
+		w.UserID = data.UserID
+		w.Currency = data.Currency
+		w.Balance = data.Balance
+		w.OverdraftLimit = data.OverdraftLimit
+		w.Frozen = data.Frozen
+
+	case FundsCredited, FundsDebited:
+		var data FundsData
+		if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+			return fmt.Errorf("event %d: %w", event.ID, err)
+		}
+		balance := w.Balance + data.Amount
+		if event.Type == FundsDebited {
+			balance = w.Balance - data.Amount
+		}
+		balance = roundCents(balance)
+		if math.Abs(balance-data.Balance) >= 0.005 {
+			return fmt.Errorf("event %d: balance %.2f does not follow from %.2f", event.ID, data.Balance, w.Balance)
+		}
+		w.Balance = balance
+
+	case OverdraftLimitSet:
+		var data OverdraftLimitData
+		if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+			return fmt.Errorf("event %d: %w", event.ID, err)
+		}
+		w.OverdraftLimit = data.Limit
+
+	case FreezeSet:
+		var data FreezeData
+		if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+			return fmt.Errorf("event %d: %w", event.ID, err)
+		}
+		w.Frozen = data.Frozen
+
+	default:
+		return fmt.Errorf("event %d: unknown type %s", event.ID, event.Type)
+	}
+
+	w.Version = event.Version
+	return nil
+}
+
+// Snapshot returns the aggregate's state as a snapshot row
+func (w *Wallet) Snapshot() *models.WalletSnapshots {
+	return &models.WalletSnapshots{
+		WalletID:       w.ID,
+		Version:        w.Version,
+		UserID:         w.UserID,
+		Currency:       w.Currency,
+		Balance:        w.Balance,
+		OverdraftLimit: w.OverdraftLimit,
+		Frozen:         w.Frozen,
+	}
+}
+
+// roundCents rounds a money amount to cents
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
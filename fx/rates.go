@@ -0,0 +1,111 @@
+package fx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"wallet/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RateProvider supplies mid-market exchange rates
+type RateProvider interface {
+	// Rate returns how many units of to one unit of from buys
+	Rate(from, to string) (float64, error)
+}
+
+// ErrRateUnavailable is returned when a provider has no rate for a currency pair
+var ErrRateUnavailable = errors.New("rate unavailable")
+
+// StaticProvider serves fixed rates quoted against a base currency
+type StaticProvider struct {
+	base  string
+	rates map[string]float64
+}
+
+// NewStaticProvider creates a provider from rates expressed as units per one base unit
+func NewStaticProvider(base string, rates map[string]float64) *StaticProvider {
+	normalized := make(map[string]float64, len(rates)+1)
+	for currency, rate := range rates {
+		normalized[strings.ToUpper(currency)] = rate
+	}
+	base = strings.ToUpper(base)
+	normalized[base] = 1
+
+	return &StaticProvider{base: base, rates: normalized}
+}
+
+// LoadStaticProvider reads a YAML file with base and rates keys
+func LoadStaticProvider(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates file: %w", err)
+	}
+
+	var file struct {
+		Base  string             `yaml:"base"`
+		Rates map[string]float64 `yaml:"rates"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rates file: %w", err)
+	}
+	if file.Base == "" {
+		return nil, fmt.Errorf("rates file has no base currency")
+	}
+
+	return NewStaticProvider(file.Base, file.Rates), nil
+}
+
+// Rate derives the cross rate of from/to through the base currency
+func (p *StaticProvider) Rate(from, to string) (float64, error) {
+	fromRate, ok := p.rates[strings.ToUpper(from)]
+	if !ok || fromRate <= 0 {
+		return 0, ErrRateUnavailable
+	}
+	toRate, ok := p.rates[strings.ToUpper(to)]
+	if !ok || toRate <= 0 {
+		return 0, ErrRateUnavailable
+	}
+
+	return toRate / fromRate, nil
+}
+
+var (
+	defaultProvider RateProvider
+	providerMu      sync.Mutex
+)
+
+// DefaultProvider returns the provider built from the fx section of the config
+func DefaultProvider() (RateProvider, error) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+
+	if defaultProvider != nil {
+		return defaultProvider, nil
+	}
+
+	conf := config.GetConf().FX
+	if conf.RatesFile != "" {
+		provider, err := LoadStaticProvider(conf.RatesFile)
+		if err != nil {
+			return nil, err
+		}
+		defaultProvider = provider
+	} else {
+		defaultProvider = NewStaticProvider(conf.Base, conf.Rates)
+	}
+
+	return defaultProvider, nil
+}
+
+// SetDefaultProvider replaces the provider used by the service layer; nil makes
+// the next DefaultProvider call build it again from the config
+func SetDefaultProvider(provider RateProvider) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	defaultProvider = provider
+}
@@ -0,0 +1,173 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// State is the lifecycle state of the process as seen by probes
+type State int32
+
+const (
+	Starting State = iota // dependencies are being initialised
+	Ready                 // serving traffic
+	Stopping              // draining before shutdown
+)
+
+// String returns the state's name as reported by /readyz
+func (s State) String() string {
+	switch s {
+	case Ready:
+		return "ready"
+	case Stopping:
+		return "stopping"
+	default:
+		return "starting"
+	}
+}
+
+var state atomic.Int32
+
+// SetState records the process state
+func SetState(s State) {
+	state.Store(int32(s))
+}
+
+// CurrentState returns the process state
+func CurrentState() State {
+	return State(state.Load())
+}
+
+// Check inspects one dependency. It returns details to report, and an error
+// when the dependency is not usable.
+type Check func(ctx context.Context) (interface{}, error)
+
+// CheckResult is the outcome of one check
+type CheckResult struct {
+	Status     string      `json:"status"` // ok or fail
+	Error      string      `json:"error,omitempty"`
+	DurationMs int64       `json:"duration_ms"`
+	Details    interface{} `json:"details,omitempty"`
+}
+
+// Report is the readiness of the process: its state and the result of every check
+type Report struct {
+	Status string                 `json:"status"` // ok, or the reason it is not ready: starting, stopping or fail
+	State  string                 `json:"state"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Ready reports whether the process should receive traffic
+func (r *Report) Ready() bool {
+	return r.Status == "ok"
+}
+
+// Registry holds the readiness checks
+type Registry struct {
+	mu     sync.Mutex
+	names  []string
+	checks map[string]Check
+}
+
+var (
+	defaultRegistry *Registry
+	registryMu      sync.Mutex
+)
+
+// DefaultRegistry returns the registry used by /readyz
+func DefaultRegistry() *Registry {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if defaultRegistry == nil {
+		defaultRegistry = NewRegistry()
+	}
+	return defaultRegistry
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]Check)}
+}
+
+// Register adds a check, replacing any check of the same name
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.checks[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.checks[name] = check
+}
+
+// Run runs every check concurrently, each bounded by timeout, and reports the
+// process as ready only when it is in the Ready state and every check passes
+func (r *Registry) Run(ctx context.Context, timeout time.Duration) *Report {
+	r.mu.Lock()
+	names := append([]string(nil), r.names...)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mu.Unlock()
+
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, checks[i], timeout)
+		}(i)
+	}
+	wg.Wait()
+
+	current := CurrentState()
+	report := &Report{Status: "ok", State: current.String(), Checks: make(map[string]CheckResult, len(names))}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != "ok" {
+			report.Status = "fail"
+		}
+	}
+	if current != Ready {
+		report.Status = current.String()
+	}
+
+	return report
+}
+
+// runCheck runs one check with a deadline; a check that ignores its context is
+// reported as failed once the deadline passes
+func runCheck(ctx context.Context, check Check, timeout time.Duration) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		details interface{}
+		err     error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		details, err := check(ctx)
+		done <- outcome{details, err}
+	}()
+
+	var result CheckResult
+	select {
+	case out := <-done:
+		result = CheckResult{Status: "ok", Details: out.details}
+		if out.err != nil {
+			result.Status = "fail"
+			result.Error = out.err.Error()
+		}
+	case <-ctx.Done():
+		result = CheckResult{Status: "fail", Error: ctx.Err().Error()}
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result
+}
@@ -1,35 +1,329 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
 
 	"wallet/config"
+	"wallet/events"
+	"wallet/eventstore"
+	"wallet/fx"
+	"wallet/health"
+	"wallet/metrics"
 	"wallet/router"
+	"wallet/service"
+	"wallet/stream"
+	"wallet/tracing"
+	"wallet/worker"
+
+	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// 命令行参数覆盖配置，其余参数为维护命令
+	args, err := config.ParseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(2)
+	}
+
 	// 初始化配置
 	if err := config.InitConfig(); err != nil {
 		log.Fatalf("Failed to initialize config: %v", err)
 	}
 
-	// 初始化数据库
-	_, err := config.InitDB(config.GetConf())
+	// 输出生效的配置，不连接数据库
+	if len(args) > 0 && args[0] == "config" {
+		os.Exit(configCommand())
+	}
+
+	// 初始化日志，标准库 log 和 gin 的输出也写入日志
+	config.InitLogger()
+	log.SetFlags(0)
+	log.SetOutput(config.LogWriter(config.LevelInfo))
+	gin.DefaultWriter = config.LogWriter(config.LevelDebug)
+	gin.DefaultErrorWriter = config.LogWriter(config.LevelError)
+	defer config.CloseLogger()
+
+	// 维护命令，如 wallet reconcile -freeze
+	if len(args) > 0 {
+		initDatabase()
+		code := runCommand(args[0], args[1:])
+		config.CloseDB()
+		os.Exit(code)
+	}
+
+	// 初始化链路追踪
+	shutdownTracing, err := tracing.Init(config.GetConf().Tracing)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		log.Fatalf("Failed to initialize tracing: %v", err)
 	}
 
+	// 热加载修改汇率配置后，下次报价时按新配置重建汇率来源
+	config.Subscribe(func(prev, next *config.Config) {
+		if !reflect.DeepEqual(prev.FX, next.FX) {
+			fx.SetDefaultProvider(nil)
+		}
+	})
+
 	// 设置路由
 	r := router.SetupRouter()
 
-	// 获取配置的端口
-	port := config.GetConf().Http.Port
+	httpConf := config.GetConf().Http
+	srv := &http.Server{
+		Addr:         fmt.Sprintf(":%d", httpConf.Port),
+		Handler:      r,
+		ReadTimeout:  httpConf.ReadTimeout,
+		WriteTimeout: httpConf.WriteTimeout,
+		IdleTimeout:  httpConf.IdleTimeout,
+	}
+	// 实时推送是长连接，停机时先关闭，否则 Shutdown 会一直等待它们结束
+	srv.RegisterOnShutdown(stream.DefaultHub().Close)
+
+	// 先启动服务器：初始化期间 /livez 正常返回，/readyz 和业务接口返回 503
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	// 启动服务器
-	addr := fmt.Sprintf(":%d", port)
-	log.Printf("Server starting on %s", addr)
-	if err := r.Run(addr); err != nil {
+	// SIGHUP 时热加载配置
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			config.Reload()
+		}
+	}()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on %s", srv.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	initDatabase()
+
+	// 启动后台任务
+	jobs, err := newJobs()
+	if err != nil {
+		log.Fatalf("Failed to initialize background jobs: %v", err)
+	}
+	jobs.Start(context.Background())
+
+	// 就绪检查：数据库连通、迁移已是最新、后台任务在运行
+	checks := health.DefaultRegistry()
+	checks.Register("database", func(ctx context.Context) (interface{}, error) {
+		return nil, config.PingDB(ctx)
+	})
+	checks.Register("migrations", func(ctx context.Context) (interface{}, error) {
+		missing, err := config.PendingMigrations(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) > 0 {
+			return map[string]interface{}{"missing_columns": missing}, errors.New("database schema is behind the models")
+		}
+		return nil, nil
+	})
+	checks.Register("workers", jobs.Check)
+	if replicas := config.ReplicaStatus(); len(replicas) > 0 {
+		// 副本不可用时读取回退到主库，只报告状态，不影响就绪
+		checks.Register("replicas", func(ctx context.Context) (interface{}, error) {
+			return config.ReplicaStatus(), nil
+		})
+	}
+
+	health.SetState(health.Ready)
+	log.Println("Server ready")
+
+	select {
+	case err := <-serveErr:
 		log.Fatalf("Failed to start server: %v", err)
+	case <-ctx.Done():
+	}
+	// 再次收到信号时直接退出
+	stop()
+
+	// 先让 /readyz 返回 503，等负载均衡摘除本实例后再停止接收请求
+	health.SetState(health.Stopping)
+	if delay := config.GetConf().Health.ShutdownDelay; delay > 0 {
+		log.Printf("Shutting down, marked not ready for %s", delay)
+		time.Sleep(delay)
+	}
+
+	// 停止接收新请求，等待进行中的请求（如转账）完成，然后停止后台任务并关闭连接池
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", httpConf.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), httpConf.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server did not drain in time: %v", err)
+		srv.Close()
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("HTTP server stopped: %v", err)
+	}
+	if err := jobs.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Background jobs did not stop in time: %v", err)
+	}
+	if err := config.CloseDB(); err != nil {
+		log.Printf("Failed to close database: %v", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
+	log.Println("Server stopped")
+}
+
+// initDatabase 连接数据库并迁移，为事件存储上线前创建的钱包写入开户事件
+func initDatabase() {
+	db, err := config.InitDB(config.GetConf())
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		metrics.WatchDB(sqlDB, config.GetConf().MySQL.DBName)
+	}
+	// 带链路的请求为每条 SQL 记录子 span
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		log.Fatalf("Failed to install tracing plugin: %v", err)
+	}
+	// 只读副本同样导出连接池指标（按 库名@地址 区分）并记录链路
+	for name, replicaDB := range config.ReplicaDBs() {
+		if sqlDB, err := replicaDB.DB(); err == nil {
+			metrics.WatchDB(sqlDB, config.GetConf().MySQL.DBName+"@"+name)
+		}
+		if err := replicaDB.Use(tracing.GormPlugin{}); err != nil {
+			log.Fatalf("Failed to install tracing plugin: %v", err)
+		}
+	}
+
+	if imported, err := eventstore.ImportLegacy(config.GetDB()); err != nil {
+		log.Fatalf("Failed to import wallets into event store: %v", err)
+	} else if imported > 0 {
+		log.Printf("Imported %d wallets into event store", imported)
+	}
+}
+
+// newJobs 注册所有后台任务
+func newJobs() (*worker.Manager, error) {
+	// 发件箱转发：把与余额变更同一事务写入的领域事件投递到配置的 sink
+	outboxConf := config.GetConf().Outbox
+	sinks, err := events.SinksFromConfig(outboxConf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize outbox sinks: %w", err)
+	}
+	relay := events.NewRelay(sinks, outboxConf.BatchSize, outboxConf.MaxBackoff)
+
+	// 合作方的 webhook 订阅也从发件箱接收事件，由 webhooks 任务投递
+	webhooks := service.NewWebhookService()
+	relay.AddSink(webhooks)
+
+	jobs := worker.NewManager()
+	if len(config.GetConf().MySQL.Replicas) > 0 {
+		jobs.Register(worker.Job{
+			Name:     "replica-health",
+			Interval: config.GetConf().MySQL.ReplicaCheckInterval,
+			Run:      config.CheckReplicas,
+		})
+	}
+	if reloadConf := config.GetConf().Reload; reloadConf.Watch {
+		jobs.Register(worker.Job{
+			Name:     "config-reload",
+			Interval: reloadConf.Interval,
+			Run:      config.ReloadIfChanged,
+		})
 	}
+	jobs.Register(worker.Job{
+		Name:     "outbox-relay",
+		Interval: outboxConf.PollInterval,
+		Run:      relay.Run,
+	})
+	jobs.Register(worker.Job{
+		Name:     "transaction-chain",
+		Interval: config.GetConf().Chain.PollInterval,
+		Run: func(ctx context.Context) error {
+			// 把已提交的交易追加到哈希链；写入交易时不锁链头，资金变动之间不会互相等待
+			_, err := service.NewChainService().Append(ctx)
+			return err
+		},
+	})
+	jobs.Register(worker.Job{
+		Name:     "stream",
+		Interval: config.GetConf().Stream.PollInterval,
+		Run: func(ctx context.Context) error {
+			// 读取新提交的事件，推送给 /api/v1/stream 的连接
+			return stream.DefaultHub().Poll(ctx)
+		},
+	})
+	jobs.Register(worker.Job{
+		Name:     "webhooks",
+		Interval: config.GetConf().Webhook.PollInterval,
+		Run:      webhooks.Run,
+	})
+	jobs.Register(worker.Job{
+		Name:     "overdraft-interest",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			// 按前一天的日终余额计息，同一天重复执行不会重复扣息
+			_, err := service.NewOverdraftService().ChargeInterest(time.Now().AddDate(0, 0, -1))
+			return err
+		},
+	})
+	jobs.Register(worker.Job{
+		Name:     "interest",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			// 计提前一天利息，并发放上个月的利息；重复执行是幂等的
+			interestService := service.NewInterestService()
+			now := time.Now()
+			if _, err := interestService.Accrue(now.AddDate(0, 0, -1)); err != nil {
+				return err
+			}
+			_, err := interestService.Payout(now.AddDate(0, -1, 1-now.Day()))
+			return err
+		},
+	})
+	jobs.Register(worker.Job{
+		Name:     "balance-snapshots",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			// 记录每个钱包在当天零点的余额，历史余额查询从最近的快照推算；重复执行是幂等的
+			now := time.Now()
+			_, err := service.NewSnapshotService().TakeSnapshots(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local))
+			return err
+		},
+	})
+	jobs.Register(worker.Job{
+		Name:     "reconciliation",
+		Interval: config.GetConf().Reconciliation.Interval,
+		Run: func(ctx context.Context) error {
+			// 按交易记录核对所有余额，差异记录到 reconciliation_discrepancies
+			report, err := service.NewReconciliationService().Run("schedule", config.GetConf().Reconciliation.Freeze)
+			if err != nil {
+				return err
+			}
+			if report.Run.Discrepancies > 0 {
+				log.Printf("reconciliation run %d found %d discrepancies", report.Run.ID, report.Run.Discrepancies)
+			}
+			return nil
+		},
+	})
+	jobs.Register(worker.Job{
+		Name:     "statements",
+		Interval: config.GetConf().Statement.PollInterval,
+		Run: func(ctx context.Context) error {
+			// 生成排队及重启前未完成的对账单，并清理过期的下载文件
+			return service.NewStatementService().WithContext(ctx).ProcessPending(ctx)
+		},
+	})
+	return jobs, nil
 }
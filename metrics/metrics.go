@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric exposed on /metrics
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// HTTP metrics, labelled by the matched route pattern rather than the raw path
+// so IDs in the URL do not create a series each
+var (
+	httpRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "wallet_http_requests_total",
+		Help: "HTTP requests handled, by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wallet_http_request_duration_seconds",
+		Help:    "HTTP request latency, by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	httpInFlight = factory.NewGauge(prometheus.GaugeOpts{
+		Name: "wallet_http_requests_in_flight",
+		Help: "HTTP requests being handled.",
+	})
+
+	rateLimited = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "wallet_rate_limited_requests_total",
+		Help: "Requests rejected by rate limiting, by limit.",
+	}, []string{"limit"})
+)
+
+// Money flow metrics
+var (
+	movements = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "wallet_movements_total",
+		Help: "Deposits, withdrawals and transfers attempted, by type, currency and outcome.",
+	}, []string{"type", "currency", "outcome"})
+
+	movementVolume = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "wallet_movement_volume_total",
+		Help: "Amount of deposits, withdrawals and transfers attempted, by type, currency and outcome.",
+	}, []string{"type", "currency", "outcome"})
+
+	insufficientBalance = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "wallet_insufficient_balance_rejections_total",
+		Help: "Operations rejected for insufficient balance, by operation.",
+	}, []string{"operation"})
+
+	lockWait = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wallet_lock_wait_seconds",
+		Help:    "Time spent acquiring wallet row locks, by operation.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation"})
+)
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// WatchDB exports the connection pool statistics of db (sql.DB.Stats) as go_sql_* metrics
+func WatchDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// HTTP records the count and latency of every request
+func HTTP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RateLimited records a request rejected by the named rate limit
+func RateLimited(limit string) {
+	rateLimited.WithLabelValues(limit).Inc()
+}
+
+// Movement records a deposit, withdrawal or transfer attempt and its amount
+func Movement(kind, currency, outcome string, amount float64) {
+	movements.WithLabelValues(kind, currency, outcome).Inc()
+	if amount > 0 {
+		movementVolume.WithLabelValues(kind, currency, outcome).Add(amount)
+	}
+}
+
+// InsufficientBalance records an operation rejected for insufficient balance
+func InsufficientBalance(operation string) {
+	insufficientBalance.WithLabelValues(operation).Inc()
+}
+
+// LockWait records the time an operation waited for wallet row locks
+func LockWait(operation string, wait time.Duration) {
+	lockWait.WithLabelValues(operation).Observe(wait.Seconds())
+}
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strconv"
+	"strings"
+
+	"wallet/config"
+	"wallet/service"
+	"wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers carrying the caller's identity. X-User-ID names the user; anyone can
+// send it, so it is only trusted together with X-Gateway-Token, sent by the API
+// gateway after authenticating the user, with the API key of the client that
+// registered the user, or with the admin token. X-Admin-Token grants admin
+// access; X-API-Key identifies a partner's API client.
+const (
+	HeaderUserID       = "X-User-ID"
+	HeaderAdminToken   = "X-Admin-Token"
+	HeaderAPIKey       = "X-API-Key"
+	HeaderGatewayToken = "X-Gateway-Token"
+)
+
+const (
+	ctxUserID            = "auth_user_id"
+	ctxUserAuthenticated = "auth_user_authenticated"
+	ctxIsAdmin           = "auth_is_admin"
+	ctxClientID          = "auth_client_id"
+)
+
+// Identity reads the caller's identity from the request headers into the context
+func Identity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if userID, err := strconv.Atoi(c.GetHeader(HeaderUserID)); err == nil && userID > 0 {
+			c.Set(ctxUserID, userID)
+		}
+
+		conf := config.GetConf().Auth
+		if tokenMatches(c.GetHeader(HeaderAdminToken), conf.AdminToken) {
+			c.Set(ctxIsAdmin, true)
+		}
+
+		// An API key that does not match a client is rejected rather than ignored
+		if key := c.GetHeader(HeaderAPIKey); key != "" {
+			client, err := service.NewApiClientService().Authenticate(key)
+			if err != nil {
+				if err.Error() == "invalid api key" {
+					utils.Unauthorized(c, "Invalid API key")
+				} else {
+					utils.InternalError(c, "Failed to authenticate API key")
+				}
+				c.Abort()
+				return
+			}
+			c.Set(ctxClientID, client.ID)
+		}
+
+		if userID, ok := CurrentUserID(c); ok {
+			authenticated := IsAdmin(c) || tokenMatches(c.GetHeader(HeaderGatewayToken), conf.GatewayToken)
+			if clientID, ok := CurrentClientID(c); ok && !authenticated {
+				registered, err := service.NewApiClientService().RegisteredUser(clientID, userID)
+				if err != nil {
+					utils.InternalError(c, "Failed to authenticate API key")
+					c.Abort()
+					return
+				}
+				authenticated = registered
+			}
+			if authenticated {
+				c.Set(ctxUserAuthenticated, true)
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// tokenMatches compares a token from a request with the configured one in constant
+// time; an empty configured token matches nothing
+func tokenMatches(token, configured string) bool {
+	return configured != "" && token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(configured)) == 1
+}
+
+// RequireAuthenticated rejects requests that carry neither a valid admin token,
+// a valid API key nor an authenticated user; X-User-ID alone is not enough
+func RequireAuthenticated() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, isClient := CurrentClientID(c)
+		_, isUser := AuthenticatedUserID(c)
+		if !isClient && !isUser && !IsAdmin(c) {
+			utils.Unauthorized(c, "Authentication required")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAuthenticatedUser rejects requests without an authenticated user, see AuthenticatedUserID
+func RequireAuthenticatedUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := AuthenticatedUserID(c); !ok {
+			utils.Unauthorized(c, "Authenticated user required")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Deprecated marks the responses of a route kept for compatibility with the
+// Deprecation header and links to the route replacing it; :name segments of
+// successor are filled in from the request's path parameters
+func Deprecated(successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		segments := strings.Split(successor, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, ":") {
+				segments[i] = c.Param(segment[1:])
+			}
+		}
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+strings.Join(segments, "/")+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
+
+// RequireAdmin rejects requests without a valid admin token
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsAdmin(c) {
+			utils.Forbidden(c, "Admin access required")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireClient rejects requests without a valid API key
+func RequireClient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := CurrentClientID(c); !ok {
+			utils.Unauthorized(c, "API key required")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentUserID returns the authenticated user's ID
+func CurrentUserID(c *gin.Context) (int, bool) {
+	userID, ok := c.Get(ctxUserID)
+	if !ok {
+		return 0, false
+	}
+	return userID.(int), true
+}
+
+// AuthenticatedUserID returns the user's ID when X-User-ID was authenticated by
+// the gateway token, the API key of the client that registered the user, or the
+// admin token
+func AuthenticatedUserID(c *gin.Context) (int, bool) {
+	if !c.GetBool(ctxUserAuthenticated) {
+		return 0, false
+	}
+	return CurrentUserID(c)
+}
+
+// IsAdmin reports whether the request carries a valid admin token
+func IsAdmin(c *gin.Context) bool {
+	return c.GetBool(ctxIsAdmin)
+}
+
+// CurrentClientID returns the API client identified by the request's API key
+func CurrentClientID(c *gin.Context) (uint, bool) {
+	clientID, ok := c.Get(ctxClientID)
+	if !ok {
+		return 0, false
+	}
+	return clientID.(uint), true
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"wallet/config"
+	"wallet/metrics"
+	"wallet/ratelimit"
+	"wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rate limits, configured under rate_limit
+const (
+	// LimitRead applies to every API request
+	LimitRead = "read"
+	// LimitMoney applies in addition to requests that move money
+	LimitMoney = "money"
+)
+
+// Rate limit response headers
+const (
+	HeaderRateLimitLimit     = "X-RateLimit-Limit"
+	HeaderRateLimitRemaining = "X-RateLimit-Remaining"
+	HeaderRateLimitReset     = "X-RateLimit-Reset"
+)
+
+// RateLimit limits callers to the named limit with token buckets in
+// ratelimit.DefaultStore. Every request is charged to its client IP and, when
+// present, to its API client and authenticated user, each with a bucket of its
+// own, so it must run after Identity. An unauthenticated X-User-ID is ignored,
+// so nobody can use up another user's bucket, and sending a different one on
+// each request does not get around the IP bucket. Every response carries the X-RateLimit-*
+// headers of the bucket closest to its limit; a request over any limit is
+// answered 429 with Retry-After. A bucket whose store fails is not enforced.
+func RateLimit(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conf := config.GetConf().RateLimit
+		if !conf.Enabled {
+			c.Next()
+			return
+		}
+		rule := conf.Read
+		if name == LimitMoney {
+			rule = conf.Money
+		}
+		limit := ratelimit.Limit{Rate: rule.Rate, Burst: rule.Burst}
+
+		var closest *ratelimit.Result
+		for _, key := range rateLimitKeys(c) {
+			result, err := ratelimit.DefaultStore().Take(c.Request.Context(), name+":"+key, limit)
+			if err != nil {
+				config.LoggerFromContext(c.Request.Context()).Error("rate limit store failed, bucket not enforced",
+					"limit", name, "key", key, "error", err.Error())
+				continue
+			}
+			if closest == nil || !result.Allowed || result.Remaining < closest.Remaining {
+				closest = &result
+			}
+			// Buckets after a full one are not charged for a rejected request
+			if !result.Allowed {
+				break
+			}
+		}
+		if closest == nil {
+			c.Next()
+			return
+		}
+
+		c.Header(HeaderRateLimitLimit, strconv.Itoa(closest.Limit))
+		c.Header(HeaderRateLimitRemaining, strconv.Itoa(closest.Remaining))
+		c.Header(HeaderRateLimitReset, strconv.Itoa(ceilSeconds(closest.ResetAfter)))
+		if !closest.Allowed {
+			metrics.RateLimited(name)
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(closest.RetryAfter)))
+			utils.TooManyRequests(c, "Rate limit exceeded")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimitKeys returns the buckets a request is charged to: its client IP first,
+// then its API client and authenticated user when present
+func rateLimitKeys(c *gin.Context) []string {
+	keys := []string{"ip:" + c.ClientIP()}
+	if clientID, ok := CurrentClientID(c); ok {
+		keys = append(keys, "client:"+strconv.FormatUint(uint64(clientID), 10))
+	}
+	if userID, ok := AuthenticatedUserID(c); ok {
+		keys = append(keys, "user:"+strconv.Itoa(userID))
+	}
+	return keys
+}
+
+// ceilSeconds rounds d up to whole seconds, as the headers carry
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"wallet/health"
+	"wallet/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RejectWhileStarting answers 503 until startup completes, so requests reaching
+// the server before the database and jobs are initialised are turned away
+// instead of failing. Requests are still served while draining on shutdown.
+func RejectWhileStarting() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if health.CurrentState() == health.Starting {
+			c.Header("Retry-After", "5")
+			utils.ServiceUnavailable(c, "Service is starting")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
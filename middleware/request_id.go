@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wallet/config"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderRequestID carries the request ID. A caller may send one to correlate
+// its own logs; the response always carries the ID the request was logged with.
+const HeaderRequestID = "X-Request-ID"
+
+const ctxRequestID = "request_id"
+
+// maxRequestIDLength bounds caller supplied request IDs
+const maxRequestIDLength = 128
+
+// RequestID assigns every request an ID, taken from X-Request-ID when the caller
+// sends a usable one, and echoes it in the response. It stores a logger tagged
+// with the ID in the request context, for handlers and services to log through,
+// and writes one access log line when the request completes.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(HeaderRequestID)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Set(ctxRequestID, requestID)
+		c.Header(HeaderRequestID, requestID)
+
+		ctx := c.Request.Context()
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", requestID))
+		logger := config.GetLogger().With("request_id", requestID)
+		c.Request = c.Request.WithContext(config.ContextWithLogger(ctx, logger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		fields := []interface{}{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip", c.ClientIP(),
+		}
+		if userID, ok := CurrentUserID(c); ok {
+			fields = append(fields, "user_id", userID)
+		}
+		if clientID, ok := CurrentClientID(c); ok {
+			fields = append(fields, "client_id", clientID)
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, "errors", c.Errors.String())
+		}
+
+		access := logger.WithContext(ctx)
+		switch {
+		case status >= http.StatusInternalServerError:
+			access.Error("request completed", fields...)
+		case status >= http.StatusBadRequest:
+			access.Warn("request completed", fields...)
+		default:
+			access.Info("request completed", fields...)
+		}
+	}
+}
+
+// CurrentRequestID returns the ID assigned to the request
+func CurrentRequestID(c *gin.Context) string {
+	return c.GetString(ctxRequestID)
+}
+
+// validRequestID accepts IDs that are safe to log and echo: short, printable,
+// without separators that could forge log fields or headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// ApiClient is a partner integrating through the API with its own key
+type ApiClients struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
+	KeyHash   string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"` // SHA-256 of the API key
+	KeyPrefix string    `gorm:"type:varchar(12);not null" json:"key_prefix"`    // first characters of the key, to tell keys apart
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ApiClients) TableName() string {
+	return "api_clients"
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// BalanceSnapshot records a wallet's main balance at a point in time so historical
+// balances can be derived from the nearest snapshot instead of the full history
+type BalanceSnapshots struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	WalletID  uint      `gorm:"not null;uniqueIndex:idx_wallet_snapshot_time" json:"wallet_id"`
+	UserID    int       `gorm:"not null;index:idx_snapshot_user_currency_time,priority:1" json:"user_id"`
+	Currency  string    `gorm:"type:varchar(3);not null;index:idx_snapshot_user_currency_time,priority:2" json:"currency"`
+	TakenAt   time.Time `gorm:"not null;uniqueIndex:idx_wallet_snapshot_time;index:idx_snapshot_user_currency_time,priority:3" json:"taken_at"` // covers transactions created at or before this time
+	Balance   float64   `gorm:"type:decimal(12,2);not null" json:"balance"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (BalanceSnapshots) TableName() string {
+	return "balance_snapshots"
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ChainHeadID is the ID of the single chain head row
+const ChainHeadID = 1
+
+// ChainHead holds the sequence number and hash of the latest transaction in the
+// global hash chain. Only the chain appender locks it, so writing transactions
+// never waits on it.
+type ChainHead struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Seq       uint64    `gorm:"not null;default:0" json:"seq"`
+	Hash      string    `gorm:"type:varchar(64);not null;default:''" json:"hash"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ChainHead) TableName() string {
+	return "chain_head"
+}
+
+// canonicalTransaction is the hashed content of a transaction. Field order is fixed
+// by the struct, amounts are rendered at their stored precision and DeletedAt is left
+// out so soft deletes do not break the chain.
+type canonicalTransaction struct {
+	Seq            uint64 `json:"seq"`
+	Type           string `json:"type"`
+	FromUserID     int    `json:"from_user_id"`
+	ToUserID       int    `json:"to_user_id"`
+	PocketID       uint   `json:"pocket_id"`
+	SharedWalletID uint   `json:"shared_wallet_id"`
+	Amount         string `json:"amount"`
+	Currency       string `json:"currency"`
+	ExchangeID     uint   `json:"exchange_id"`
+	Rate           string `json:"rate"`
+	Description    string `json:"description"`
+	Status         string `json:"status"`
+	CreatedAt      int64  `json:"created_at"` // unix milliseconds
+	PrevHash       string `json:"prev_hash"`
+}
+
+// ComputeHash returns the SHA-256 of the transaction's canonical content and PrevHash
+func (t *Transaction) ComputeHash() string {
+	content, _ := json.Marshal(canonicalTransaction{
+		Seq:            t.Seq,
+		Type:           t.Type,
+		FromUserID:     t.FromUserID,
+		ToUserID:       t.ToUserID,
+		PocketID:       t.PocketID,
+		SharedWalletID: t.SharedWalletID,
+		Amount:         strconv.FormatFloat(t.Amount, 'f', 2, 64),
+		Currency:       t.Currency,
+		ExchangeID:     t.ExchangeID,
+		Rate:           strconv.FormatFloat(t.Rate, 'f', 8, 64),
+		Description:    t.Description,
+		Status:         t.Status,
+		CreatedAt:      t.CreatedAt.UnixMilli(),
+		PrevHash:       t.PrevHash,
+	})
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// BeforeCreate rounds values to the precision the database stores, so the
+// transaction as returned to the caller and published in events matches the row
+// the chain later hashes. Transactions are written with Seq 0 and appended to
+// the chain after they commit, see service.ChainServiceImpl.Append.
+func (t *Transaction) BeforeCreate(tx *gorm.DB) error {
+	t.Amount = math.Round(t.Amount*100) / 100
+	t.Rate, _ = strconv.ParseFloat(strconv.FormatFloat(t.Rate, 'f', 8, 64), 64)
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+	t.CreatedAt = t.CreatedAt.Truncate(time.Millisecond)
+	if t.Status == "" {
+		t.Status = "completed"
+	}
+	return nil
+}
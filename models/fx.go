@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// FxQuote is an exchange rate offered to a user until it expires
+type FxQuotes struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       int       `gorm:"not null;index" json:"user_id"`
+	FromCurrency string    `gorm:"type:varchar(3);not null" json:"from_currency"`
+	ToCurrency   string    `gorm:"type:varchar(3);not null" json:"to_currency"`
+	MidRate      float64   `gorm:"type:decimal(18,8);not null" json:"mid_rate"`
+	Spread       float64   `gorm:"type:decimal(10,6);not null" json:"spread"`
+	Rate         float64   `gorm:"type:decimal(18,8);not null" json:"rate"`       // mid rate less the spread
+	Status       string    `gorm:"type:varchar(20);default:'open'" json:"status"` // open, used
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (FxQuotes) TableName() string {
+	return "fx_quotes"
+}
+
+// FxExchange records a conversion between two of a user's currency wallets
+type FxExchanges struct {
+	ID                  uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID              int       `gorm:"not null;index" json:"user_id"`
+	QuoteID             uint      `gorm:"not null;uniqueIndex" json:"quote_id"`
+	FromCurrency        string    `gorm:"type:varchar(3);not null" json:"from_currency"`
+	ToCurrency          string    `gorm:"type:varchar(3);not null" json:"to_currency"`
+	FromAmount          float64   `gorm:"type:decimal(12,2);not null" json:"from_amount"`
+	ToAmount            float64   `gorm:"type:decimal(12,2);not null" json:"to_amount"`
+	Rate                float64   `gorm:"type:decimal(18,8);not null" json:"rate"`
+	DebitTransactionID  uint      `json:"debit_transaction_id"`
+	CreditTransactionID uint      `json:"credit_transaction_id"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+func (FxExchanges) TableName() string {
+	return "fx_exchanges"
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// InterestAccrual records one day of interest earned on a wallet's positive balance
+type InterestAccruals struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	WalletID    uint      `gorm:"not null;uniqueIndex:idx_wallet_accrual_date" json:"wallet_id"`
+	UserID      int       `gorm:"not null;index" json:"user_id"`
+	AccrualDate time.Time `gorm:"type:date;not null;uniqueIndex:idx_wallet_accrual_date" json:"accrual_date"`
+	Balance     float64   `gorm:"type:decimal(12,2);not null" json:"balance"` // end-of-day balance the accrual is based on
+	AnnualRate  float64   `gorm:"type:decimal(10,6);not null" json:"annual_rate"`
+	Amount      float64   `gorm:"type:decimal(16,6);not null" json:"amount"` // kept below cents until paid out
+	PayoutID    uint      `gorm:"index" json:"payout_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (InterestAccruals) TableName() string {
+	return "interest_accruals"
+}
+
+// InterestPayout records the monthly payout of accrued interest into a wallet
+type InterestPayouts struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	WalletID      uint      `gorm:"not null;uniqueIndex:idx_wallet_payout_period" json:"wallet_id"`
+	UserID        int       `gorm:"not null;index" json:"user_id"`
+	Period        string    `gorm:"type:varchar(7);not null;uniqueIndex:idx_wallet_payout_period" json:"period"` // YYYY-MM
+	Amount        float64   `gorm:"type:decimal(12,2);not null" json:"amount"`
+	Remainder     float64   `gorm:"type:decimal(16,6);not null;default:0" json:"remainder"` // accrued interest left over after rounding to cents, carried into the next payout
+	TransactionID uint      `json:"transaction_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (InterestPayouts) TableName() string {
+	return "interest_payouts"
+}
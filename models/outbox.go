@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxEvent is a domain event written in the same database transaction as the
+// change it describes, waiting to be published by the relay
+type OutboxEvents struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	EventID       string     `gorm:"type:varchar(36);not null;uniqueIndex" json:"event_id"`
+	Type          string     `gorm:"type:varchar(50);not null;index" json:"type"`
+	OrderingKeys  string     `gorm:"type:varchar(255)" json:"ordering_keys"` // comma separated, e.g. wallet:3,wallet:7
+	Payload       string     `gorm:"type:text;not null" json:"payload"`
+	Status        string     `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"` // pending, published
+	Attempts      int        `gorm:"default:0" json:"attempts"`
+	LastError     string     `gorm:"type:varchar(255)" json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	PublishedAt   *time.Time `json:"published_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (OutboxEvents) TableName() string {
+	return "outbox_events"
+}
+
+// OutboxRelayLock is a single row leased by the relay while it publishes a batch,
+// so only one relay publishes at a time and per-key order is kept. A lease rather
+// than a row lock, so no database lock is held while sinks are called.
+type OutboxRelayLock struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Owner       string     `gorm:"type:varchar(32)" json:"owner"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"` // the lease runs out then unless renewed
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (OutboxRelayLock) TableName() string {
+	return "outbox_relay_lock"
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// OverdraftCharge records the interest charged on a wallet's negative balance for one day
+type OverdraftCharges struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	WalletID      uint      `gorm:"not null;uniqueIndex:idx_wallet_charge_date" json:"wallet_id"`
+	UserID        int       `gorm:"not null;index" json:"user_id"`
+	ChargeDate    time.Time `gorm:"type:date;not null;uniqueIndex:idx_wallet_charge_date" json:"charge_date"`
+	Balance       float64   `gorm:"type:decimal(12,2);not null" json:"balance"` // end-of-day balance the charge is based on
+	Rate          float64   `gorm:"type:decimal(10,6);not null" json:"rate"`
+	Amount        float64   `gorm:"type:decimal(12,2);not null" json:"amount"`
+	TransactionID uint      `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (OverdraftCharges) TableName() string {
+	return "overdraft_charges"
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Pocket is a named sub-balance set aside under a user's wallet
+type Pockets struct {
+	ID         uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	WalletID   uint           `gorm:"not null;uniqueIndex:idx_wallet_pocket_name" json:"wallet_id"`
+	UserID     int            `gorm:"not null;index" json:"user_id"`
+	Name       string         `gorm:"type:varchar(100);not null;uniqueIndex:idx_wallet_pocket_name" json:"name"`
+	Balance    float64        `gorm:"type:decimal(12,2);default:0" json:"balance"`
+	GoalAmount float64        `gorm:"type:decimal(12,2);default:0" json:"goal_amount,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (Pockets) TableName() string {
+	return "pockets"
+}
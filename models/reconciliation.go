@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// ReconciliationRun records one comparison of stored balances against the transaction history
+type ReconciliationRuns struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Trigger        string     `gorm:"type:varchar(20);not null" json:"trigger"` // command, schedule, api
+	Freeze         bool       `json:"freeze"`                                   // whether affected wallets were frozen
+	WalletsChecked int        `json:"wallets_checked"`
+	Discrepancies  int        `json:"discrepancies"`
+	WalletsFrozen  int        `json:"wallets_frozen"`
+	Status         string     `gorm:"type:varchar(20);not null" json:"status"` // ok, discrepancies
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+}
+
+func (ReconciliationRuns) TableName() string {
+	return "reconciliation_runs"
+}
+
+// ReconciliationDiscrepancy is one balance or invariant that did not match its history
+type ReconciliationDiscrepancies struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	RunID      uint      `gorm:"not null;index" json:"run_id"`
+	Kind       string    `gorm:"type:varchar(30);not null" json:"kind"` // wallet, pocket, shared_wallet, overdraft_limit, invariant
+	WalletID   uint      `json:"wallet_id,omitempty"`                   // wallet, pocket or shared wallet ID depending on kind
+	UserID     int       `json:"user_id,omitempty"`
+	Currency   string    `gorm:"type:varchar(3)" json:"currency"`
+	Stored     float64   `gorm:"type:decimal(14,2)" json:"stored"`
+	Computed   float64   `gorm:"type:decimal(14,2)" json:"computed"`
+	Difference float64   `gorm:"type:decimal(14,2)" json:"difference"` // stored - computed
+	Detail     string    `gorm:"type:text" json:"detail"`
+	Frozen     bool      `json:"frozen"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (ReconciliationDiscrepancies) TableName() string {
+	return "reconciliation_discrepancies"
+}
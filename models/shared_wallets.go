@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SharedWallet is a wallet held jointly by several members
+type SharedWallets struct {
+	ID                uint                  `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name              string                `gorm:"type:varchar(100);not null" json:"name"`
+	Balance           float64               `gorm:"type:decimal(12,2);default:0" json:"balance"`
+	Currency          string                `gorm:"type:varchar(3);not null;default:'USD'" json:"currency"`
+	ApprovalThreshold float64               `gorm:"type:decimal(12,2);default:0" json:"approval_threshold"` // spends above this need approvals
+	RequiredApprovals int                   `gorm:"default:0" json:"required_approvals"`                    // 0 disables the approval rule
+	CreatedAt         time.Time             `json:"created_at"`
+	UpdatedAt         time.Time             `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt        `gorm:"index" json:"-"`
+	Members           []SharedWalletMembers `gorm:"foreignKey:SharedWalletID" json:"members,omitempty"`
+}
+
+func (SharedWallets) TableName() string {
+	return "shared_wallets"
+}
+
+// SharedWalletMember links a user to a shared wallet with a role
+type SharedWalletMembers struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	SharedWalletID uint      `gorm:"not null;uniqueIndex:idx_shared_wallet_member" json:"shared_wallet_id"`
+	UserID         int       `gorm:"not null;uniqueIndex:idx_shared_wallet_member;index" json:"user_id"`
+	Role           string    `gorm:"type:varchar(20);not null" json:"role"`              // owner, spender, viewer
+	SpendingLimit  float64   `gorm:"type:decimal(12,2);default:0" json:"spending_limit"` // per operation, 0 means unlimited
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (SharedWalletMembers) TableName() string {
+	return "shared_wallet_members"
+}
+
+// PendingOperation is a shared wallet withdrawal or transfer waiting for approvals
+type PendingOperations struct {
+	ID             uint                 `gorm:"primaryKey;autoIncrement" json:"id"`
+	SharedWalletID uint                 `gorm:"not null;index" json:"shared_wallet_id"`
+	Type           string               `gorm:"type:varchar(20);not null" json:"type"` // withdraw, transfer
+	RequestedBy    int                  `gorm:"not null" json:"requested_by"`
+	ToUserID       int                  `json:"to_user_id,omitempty"`
+	Amount         float64              `gorm:"type:decimal(12,2);not null" json:"amount"`
+	Description    string               `gorm:"type:text" json:"description,omitempty"`
+	Status         string               `gorm:"type:varchar(20);default:'pending'" json:"status"` // pending, executed, rejected, failed
+	TransactionID  uint                 `json:"transaction_id,omitempty"`
+	CreatedAt      time.Time            `json:"created_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+	Approvals      []OperationApprovals `gorm:"foreignKey:OperationID" json:"approvals,omitempty"`
+}
+
+func (PendingOperations) TableName() string {
+	return "pending_operations"
+}
+
+// OperationApproval records one member's approval of a pending operation
+type OperationApprovals struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	OperationID uint      `gorm:"not null;uniqueIndex:idx_operation_approver" json:"operation_id"`
+	UserID      int       `gorm:"not null;uniqueIndex:idx_operation_approver" json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (OperationApprovals) TableName() string {
+	return "operation_approvals"
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// StatementJob is a statement generated in the background and served through a download link
+type StatementJobs struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Token      string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"token"` // identifies the download link
+	UserID     int        `gorm:"not null;index" json:"user_id"`
+	Currency   string     `gorm:"type:varchar(3);not null" json:"currency"`
+	PeriodFrom time.Time  `gorm:"not null" json:"period_from"`
+	PeriodTo   time.Time  `gorm:"not null" json:"period_to"`
+	Format     string     `gorm:"type:varchar(10);not null" json:"format"`          // csv, json, pdf
+	Status     string     `gorm:"type:varchar(20);default:'pending'" json:"status"` // pending, processing, ready, failed, expired
+	FilePath   string     `gorm:"type:varchar(255)" json:"-"`
+	Error      string     `gorm:"type:varchar(255)" json:"error,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // download link expiry, set once ready
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (StatementJobs) TableName() string {
+	return "statement_jobs"
+}
@@ -8,17 +8,46 @@ import (
 
 // Transaction
 type Transaction struct {
-	ID          uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	Type        string         `gorm:"type:varchar(20);not null" json:"type"` // deposit, withdraw, transfer
-	FromUserID  int            `json:"from_user_id,omitempty"`
-	ToUserID    int            `json:"to_user_id,omitempty"`
-	Amount      float64        `gorm:"type:decimal(12,2);not null" json:"amount"`
-	Description string         `gorm:"type:text" json:"description,omitempty"`
-	Status      string         `gorm:"type:varchar(20);default:'completed'" json:"status"`
-	CreatedAt   time.Time      `json:"created_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Type           string         `gorm:"type:varchar(20);not null" json:"type"` // deposit, withdraw, transfer, pocket_in, pocket_out, shared_deposit, shared_withdraw, shared_transfer, overdraft_interest, interest, exchange
+	FromUserID     int            `gorm:"index;index:idx_transaction_from_history,priority:1" json:"from_user_id,omitempty"`
+	ToUserID       int            `gorm:"index;index:idx_transaction_to_history,priority:1" json:"to_user_id,omitempty"`
+	PocketID       uint           `gorm:"index" json:"pocket_id,omitempty"`        // set for moves between main balance and a pocket
+	SharedWalletID uint           `gorm:"index" json:"shared_wallet_id,omitempty"` // set for shared wallet movements
+	Amount         float64        `gorm:"type:decimal(12,2);not null" json:"amount"`
+	Currency       string         `gorm:"type:varchar(3);not null;default:'USD';index:idx_transaction_from_history,priority:2;index:idx_transaction_to_history,priority:2" json:"currency"`
+	ExchangeID     uint           `gorm:"index" json:"exchange_id,omitempty"`                 // links the two legs of a currency exchange
+	Rate           float64        `gorm:"type:decimal(18,8);default:0" json:"rate,omitempty"` // exchange rate applied, from -> to
+	Description    string         `gorm:"type:text" json:"description,omitempty"`
+	Status         string         `gorm:"type:varchar(20);default:'completed'" json:"status"`
+	CreatedAt      time.Time      `gorm:"index;index:idx_transaction_from_history,priority:3;index:idx_transaction_to_history,priority:3" json:"created_at"`
+	Seq            uint64         `gorm:"index" json:"seq,omitempty"`                  // position in the hash chain, 0 for rows written before it
+	PrevHash       string         `gorm:"type:varchar(64)" json:"prev_hash,omitempty"` // hash of the previous transaction in the chain
+	Hash           string         `gorm:"type:varchar(64)" json:"hash,omitempty"`      // SHA-256 of the canonical content and PrevHash
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (Transaction) TableName() string {
 	return "transaction"
 }
+
+// AfterCreate records the initial status in the transaction's state history
+func (t *Transaction) AfterCreate(tx *gorm.DB) error {
+	return tx.Create(&TransactionStatusHistory{
+		TransactionID: t.ID,
+		Status:        t.Status,
+	}).Error
+}
+
+// TransactionStatusHistory records each status a transaction has been in
+type TransactionStatusHistory struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	TransactionID uint      `gorm:"not null;index" json:"transaction_id"`
+	Status        string    `gorm:"type:varchar(20);not null" json:"status"`
+	Note          string    `gorm:"type:varchar(255)" json:"note,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (TransactionStatusHistory) TableName() string {
+	return "transaction_status_history"
+}
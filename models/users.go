@@ -11,6 +11,7 @@ type Users struct {
 	ID        int            `gorm:"primaryKey;autoIncrement" json:"id"`
 	Username  string         `gorm:"type:varchar(100);not null" json:"username"`
 	Email     string         `gorm:"type:varchar(255);not null;uniqueIndex" json:"email"`
+	ClientID  uint           `gorm:"index" json:"client_id,omitempty"` // API client that registered the user
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
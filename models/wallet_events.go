@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+)
+
+// WalletEvent is one entry of a wallet's append-only event stream. Rows are only
+// ever inserted; the wallet's balance, limit and frozen flag can be rebuilt by
+// applying its events in version order.
+type WalletEvents struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	WalletID      uint      `gorm:"not null;uniqueIndex:idx_wallet_event_version" json:"wallet_id"`
+	Version       uint      `gorm:"not null;uniqueIndex:idx_wallet_event_version" json:"version"` // 1 for the opening event
+	Type          string    `gorm:"type:varchar(30);not null" json:"type"`
+	TransactionID uint      `gorm:"index" json:"transaction_id,omitempty"`
+	Data          string    `gorm:"type:text;not null" json:"data"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (WalletEvents) TableName() string {
+	return "wallet_events"
+}
+
+// WalletSnapshot is a wallet's state at a version, so loading the aggregate only
+// applies the events after it
+type WalletSnapshots struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	WalletID       uint      `gorm:"not null;uniqueIndex:idx_wallet_snapshot_version" json:"wallet_id"`
+	Version        uint      `gorm:"not null;uniqueIndex:idx_wallet_snapshot_version" json:"version"`
+	UserID         int       `gorm:"not null" json:"user_id"`
+	Currency       string    `gorm:"type:varchar(3);not null" json:"currency"`
+	Balance        float64   `gorm:"type:decimal(12,2)" json:"balance"`
+	OverdraftLimit float64   `gorm:"type:decimal(12,2)" json:"overdraft_limit"`
+	Frozen         bool      `json:"frozen"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (WalletSnapshots) TableName() string {
+	return "wallet_snapshots"
+}
+
+// WalletEntry is the read model of one balance movement as seen by the wallet's
+// owner, projected from the event stream
+type WalletEntries struct {
+	ID              uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID          int       `gorm:"not null;index:idx_wallet_entry_user" json:"user_id"`
+	WalletID        uint      `gorm:"not null;uniqueIndex:idx_wallet_entry_version" json:"wallet_id"`
+	Version         uint      `gorm:"not null;uniqueIndex:idx_wallet_entry_version" json:"version"`
+	TransactionID   uint      `gorm:"index" json:"transaction_id"`
+	TransactionType string    `gorm:"type:varchar(20);not null" json:"transaction_type"`
+	Currency        string    `gorm:"type:varchar(3);not null" json:"currency"`
+	Amount          float64   `gorm:"type:decimal(12,2)" json:"amount"` // positive credits, negative debits
+	BalanceAfter    float64   `gorm:"type:decimal(12,2)" json:"balance_after"`
+	CreatedAt       time.Time `gorm:"index:idx_wallet_entry_user" json:"created_at"`
+}
+
+func (WalletEntries) TableName() string {
+	return "wallet_entries"
+}
@@ -6,15 +6,19 @@ import (
 	"gorm.io/gorm"
 )
 
-// Wallet
+// Wallet holds a user's balance in one currency
 type Wallets struct {
-	ID        uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID    int            `gorm:"not null;uniqueIndex" json:"user_id"`
-	Balance   float64        `gorm:"type:decimal(12,2);default:0" json:"balance"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	User      Users          `gorm:"foreignKey:UserID;references:ID" json:"user,omitempty"`
+	ID             uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID         int            `gorm:"not null;uniqueIndex:idx_wallet_user_currency" json:"user_id"`
+	Currency       string         `gorm:"type:varchar(3);not null;default:'USD';uniqueIndex:idx_wallet_user_currency" json:"currency"`
+	Balance        float64        `gorm:"type:decimal(12,2);default:0" json:"balance"`
+	OverdraftLimit float64        `gorm:"type:decimal(12,2);default:0" json:"overdraft_limit"` // balance may go down to -OverdraftLimit
+	Frozen         bool           `gorm:"default:false" json:"frozen"`                         // set by reconciliation; blocks customer movements
+	Version        uint           `gorm:"default:0" json:"version"`                            // version of the last event in the wallet's event stream
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+	User           Users          `gorm:"foreignKey:UserID;references:ID" json:"user,omitempty"`
 }
 
 func (Wallets) TableName() string {
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+)
+
+// WebhookSubscription is an API client's callback URL for events concerning its users
+type WebhookSubscriptions struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ClientID   uint      `gorm:"not null;index" json:"client_id"`
+	URL        string    `gorm:"type:varchar(500);not null" json:"url"`
+	Secret     string    `gorm:"type:varchar(64);not null" json:"-"`              // HMAC-SHA256 signing key
+	EventTypes string    `gorm:"type:varchar(500)" json:"event_types"`            // comma separated, empty for all
+	Status     string    `gorm:"type:varchar(20);default:'active'" json:"status"` // active, disabled, dead_letter
+	LastError  string    `gorm:"type:varchar(255)" json:"last_error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (WebhookSubscriptions) TableName() string {
+	return "webhook_subscriptions"
+}
+
+// WebhookDelivery is one event queued for one subscription
+type WebhookDeliveries struct {
+	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	SubscriptionID uint       `gorm:"not null;uniqueIndex:idx_webhook_delivery_event" json:"subscription_id"`
+	EventID        string     `gorm:"type:varchar(36);not null;uniqueIndex:idx_webhook_delivery_event" json:"event_id"`
+	EventType      string     `gorm:"type:varchar(50);not null" json:"event_type"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"`
+	Status         string     `gorm:"type:varchar(20);default:'pending';index" json:"status"` // pending, succeeded, dead
+	Attempts       int        `gorm:"default:0" json:"attempts"`
+	ResponseStatus int        `json:"response_status,omitempty"` // HTTP status of the last attempt
+	LastError      string     `gorm:"type:varchar(255)" json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (WebhookDeliveries) TableName() string {
+	return "webhook_deliveries"
+}
+
+// WebhookAttempt logs one HTTP request made for a delivery
+type WebhookAttempts struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	DeliveryID     uint      `gorm:"not null;index" json:"delivery_id"`
+	Attempt        int       `json:"attempt"`
+	ResponseStatus int       `json:"response_status,omitempty"`
+	Error          string    `gorm:"type:varchar(255)" json:"error,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+func (WebhookAttempts) TableName() string {
+	return "webhook_attempts"
+}
@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket: it holds up to Burst tokens and refills Rate tokens per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed    bool
+	Limit      int           // bucket capacity
+	Remaining  int           // whole tokens left after this request
+	RetryAfter time.Duration // until the next token is available, when not allowed
+	ResetAfter time.Duration // until the bucket is full again
+}
+
+// Store keeps the token buckets. The in-process MemoryStore limits each instance
+// separately; a store shared by all instances (e.g. Redis) can replace it to
+// enforce one limit across them.
+type Store interface {
+	// Take takes one token from the bucket at key, created full on first use.
+	// The limit is passed on every call so changed limits apply to existing buckets.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// sweepInterval is how often MemoryStore drops buckets that have refilled
+const sweepInterval = time.Minute
+
+// bucket is the state of one token bucket
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	fullAt  time.Time // from then on the bucket is full and need not be kept
+}
+
+// MemoryStore keeps token buckets in process memory
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates an empty in-process store
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithClock(time.Now)
+}
+
+// NewMemoryStoreWithClock creates an in-process store reading the time from now, for tests
+func NewMemoryStoreWithClock(now func() time.Time) *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}, lastSweep: now(), now: now}
+}
+
+// Take refills the bucket for the time since its last use and takes one token if there is one
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	burst := float64(limit.Burst)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, updated: now}
+		s.buckets[key] = b
+	} else if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed.Seconds()*limit.Rate)
+		b.updated = now
+	}
+	// A lowered burst applies at once
+	b.tokens = math.Min(burst, b.tokens)
+
+	result := Result{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = refillTime(1-b.tokens, limit.Rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = refillTime(burst-b.tokens, limit.Rate)
+	b.fullAt = now.Add(result.ResetAfter)
+	return result, nil
+}
+
+// Len returns the number of buckets kept
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buckets)
+}
+
+// sweep drops the buckets that are full again, at most once per sweepInterval;
+// a dropped bucket is recreated full, so dropping it changes nothing
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// refillTime returns how long refilling tokens takes at rate tokens per second
+func refillTime(tokens, rate float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	if rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(math.Ceil(tokens / rate * float64(time.Second)))
+}
+
+var (
+	defaultStore Store = NewMemoryStore()
+	storeMu      sync.RWMutex
+)
+
+// DefaultStore returns the store used by the rate limit middleware
+func DefaultStore() Store {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	return defaultStore
+}
+
+// SetDefaultStore replaces the store used by the rate limit middleware
+func SetDefaultStore(store Store) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	defaultStore = store
+}
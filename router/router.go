@@ -3,14 +3,39 @@ package router
 import (
 	"net/http"
 
+	"wallet/config"
 	"wallet/controller"
+	"wallet/metrics"
+	"wallet/middleware"
+	"wallet/tracing"
 
 	"github.com/gin-gonic/gin"
 )
 
+// TrustProxies lets r take the client IP from X-Forwarded-For only on requests
+// from http.trusted_proxies, so callers cannot choose the IP they are logged and
+// rate limited under. gin trusts every proxy unless told otherwise.
+func TrustProxies(r *gin.Engine) {
+	if err := r.SetTrustedProxies(config.GetConf().Http.TrustedProxies); err != nil {
+		// gin keeps trusting every proxy when the list does not parse
+		config.GetLogger().Error("invalid trusted proxies, trusting none", "error", err.Error())
+		_ = r.SetTrustedProxies(nil)
+	}
+}
+
 // SetupRouter set router
 func SetupRouter() *gin.Engine {
-	r := gin.Default()
+	// gin's own request logger is replaced by the access log of middleware.RequestID
+	r := gin.New()
+	TrustProxies(r)
+	r.Use(gin.Recovery(), metrics.HTTP())
+
+	// probes and metrics, registered before the middleware below so they answer during startup too
+	r.GET("/livez", controller.Livez)
+	r.GET("/readyz", controller.Readyz)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	r.Use(tracing.Middleware(), middleware.RequestID(), middleware.RejectWhileStarting(), middleware.Identity())
 
 	// health check
 	r.GET("/health", func(c *gin.Context) {
@@ -20,8 +45,10 @@ func SetupRouter() *gin.Engine {
 		})
 	})
 
-	// API router group
-	api := r.Group("/api/v1")
+	// API router group, every request counted against the caller's read limit;
+	// requests that move money are also counted against the money limit
+	api := r.Group("/api/v1", middleware.RateLimit(middleware.LimitRead))
+	money := middleware.RateLimit(middleware.LimitMoney)
 	{
 		// users
 		users := api.Group("/users")
@@ -29,22 +56,114 @@ func SetupRouter() *gin.Engine {
 			users.POST("", controller.RegisterUser)
 			users.GET("", controller.GetAllUsers)
 			users.GET("/:id", controller.GetUser)
+			users.GET("/:id/transactions", controller.GetUserTransactions)
 		}
 
 		// wallets
 		wallets := api.Group("/wallets")
 		{
+			wallets.GET("/:user_id", controller.GetWallets)
 			wallets.GET("/:user_id/balance", controller.GetBalance)
-			wallets.POST("/:user_id/deposit", controller.Deposit)
-			wallets.POST("/:user_id/withdraw", controller.Withdraw)
-			wallets.POST("/transfer", controller.Transfer)
+			wallets.GET("/:user_id/balance/as-of", controller.GetBalanceAsOf)
+			wallets.GET("/:user_id/entries", controller.GetWalletEntries)
+			wallets.POST("/:user_id/deposit", money, controller.Deposit)
+			wallets.POST("/:user_id/withdraw", money, controller.Withdraw)
+			wallets.POST("/transfer", money, controller.Transfer)
+			wallets.PUT("/:user_id/overdraft", middleware.RequireAdmin(), controller.SetOverdraftLimit)
+			wallets.PUT("/:user_id/freeze", middleware.RequireAdmin(), controller.SetWalletFrozen)
+			wallets.GET("/:user_id/interest", controller.GetInterestHistory)
+			wallets.GET("/:user_id/statement", controller.GetStatement)
+
+			// pockets
+			wallets.GET("/:user_id/pockets", controller.GetPockets)
+			wallets.POST("/:user_id/pockets", controller.CreatePocket)
+			wallets.POST("/:user_id/pockets/:pocket_id/deposit", money, controller.DepositToPocket)
+			wallets.POST("/:user_id/pockets/:pocket_id/withdraw", money, controller.WithdrawFromPocket)
+		}
+
+		// shared wallets, acting as the authenticated user
+		sharedWallets := api.Group("/shared-wallets", middleware.RequireAuthenticatedUser())
+		{
+			sharedWallets.POST("", controller.CreateSharedWallet)
+			sharedWallets.GET("", controller.GetUserSharedWallets)
+			sharedWallets.GET("/:id", controller.GetSharedWallet)
+			sharedWallets.PUT("/:id/members", controller.SetSharedWalletMember)
+			sharedWallets.PUT("/:id/rule", controller.SetSharedWalletRule)
+			sharedWallets.POST("/:id/deposit", money, controller.DepositToSharedWallet)
+			sharedWallets.POST("/:id/withdraw", money, controller.WithdrawFromSharedWallet)
+			sharedWallets.POST("/:id/transfer", money, controller.TransferFromSharedWallet)
+			sharedWallets.GET("/:id/operations", controller.GetSharedWalletOperations)
+			sharedWallets.POST("/:id/operations/:op_id/approve", money, controller.ApproveSharedWalletOperation)
+			sharedWallets.POST("/:id/operations/:op_id/reject", controller.RejectSharedWalletOperation)
+		}
+
+		// currency exchange
+		exchange := api.Group("/fx")
+		{
+			exchange.POST("/quotes", controller.CreateFxQuote)
+			exchange.POST("/exchange", money, controller.ExchangeCurrency)
+		}
+
+		// interest runs
+		interest := api.Group("/interest", middleware.RequireAdmin())
+		{
+			interest.POST("/accrue", controller.RunInterestAccrual)
+			interest.POST("/payout", controller.RunInterestPayout)
 		}
 
-		// transactions
-		transactions := api.Group("/transactions")
+		// ledger reconciliation
+		reconciliation := api.Group("/reconciliation", middleware.RequireAdmin())
 		{
-			transactions.GET("/:user_id", controller.GetUserTransactions)
+			reconciliation.POST("/runs", controller.RunReconciliation)
+			reconciliation.GET("/runs", controller.GetReconciliationRuns)
+			reconciliation.GET("/runs/:id", controller.GetReconciliationRun)
+			reconciliation.GET("/chain", controller.VerifyTransactionChain)
 		}
+
+		// runtime administration
+		admin := api.Group("/admin", middleware.RequireAdmin())
+		{
+			admin.GET("/log-level", controller.GetLogLevel)
+			admin.PUT("/log-level", controller.SetLogLevel)
+			admin.GET("/config", controller.GetEffectiveConfig)
+			admin.POST("/config/reload", controller.ReloadConfig)
+		}
+
+		// statements generated in the background
+		statements := api.Group("/statements")
+		{
+			statements.GET("/:token", controller.GetStatementJob)
+			statements.GET("/:token/download", controller.DownloadStatement)
+		}
+
+		// partner API clients
+		clients := api.Group("/clients", middleware.RequireAdmin())
+		{
+			clients.POST("", controller.CreateApiClient)
+			clients.GET("", controller.GetApiClients)
+		}
+
+		// webhooks of the API client identified by X-API-Key
+		webhooks := api.Group("/webhooks", middleware.RequireClient())
+		{
+			webhooks.POST("/subscriptions", controller.CreateWebhookSubscription)
+			webhooks.GET("/subscriptions", controller.GetWebhookSubscriptions)
+			webhooks.GET("/subscriptions/:id", controller.GetWebhookSubscription)
+			webhooks.PUT("/subscriptions/:id", controller.UpdateWebhookSubscription)
+			webhooks.DELETE("/subscriptions/:id", controller.DeleteWebhookSubscription)
+			webhooks.GET("/subscriptions/:id/deliveries", controller.GetWebhookDeliveries)
+			webhooks.POST("/subscriptions/:id/replay", controller.ReplayWebhookEvent)
+			webhooks.GET("/deliveries/:id", controller.GetWebhookDelivery)
+		}
+
+		// live balance and transaction updates of the authenticated user (Server-Sent Events)
+		api.GET("/stream", middleware.RequireAuthenticatedUser(), controller.StreamUpdates)
+
+		// transaction detail, for admins, the authenticated parties and the API client of the parties
+		api.GET("/transactions/:id", middleware.RequireAuthenticated(), controller.GetTransaction)
+
+		// transaction detail under its interim path, replaced by /transactions/:id
+		api.GET("/transaction-details/:id", middleware.Deprecated("/api/v1/transactions/:id"), middleware.RequireAuthenticated(), controller.GetTransaction)
 	}
 
 	return r
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"wallet/config"
+	"wallet/models"
+)
+
+// apiKeyPrefix marks wallet API keys so leaked keys are easy to recognise
+const apiKeyPrefix = "wk_"
+
+// ApiClientServiceImpl implements API client service interfaces
+type ApiClientServiceImpl struct{}
+
+// NewApiClientService creates API client service instance
+func NewApiClientService() *ApiClientServiceImpl {
+	return &ApiClientServiceImpl{}
+}
+
+// CreateClient registers an API client and returns its key. Only a hash of the
+// key is stored, so the key cannot be shown again.
+func (s *ApiClientServiceImpl) CreateClient(name string) (*models.ApiClients, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("name required")
+	}
+
+	secret, err := newToken()
+	if err != nil {
+		return nil, "", err
+	}
+	key := apiKeyPrefix + secret
+
+	client := &models.ApiClients{
+		Name:      name,
+		KeyHash:   hashAPIKey(key),
+		KeyPrefix: key[:len(apiKeyPrefix)+6],
+	}
+	if err := config.GetDB().Create(client).Error; err != nil {
+		return nil, "", err
+	}
+
+	return client, key, nil
+}
+
+// GetClients lists all API clients
+func (s *ApiClientServiceImpl) GetClients() ([]models.ApiClients, error) {
+	var clients []models.ApiClients
+	if err := config.GetDB().Order("id ASC").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// Authenticate returns the client owning key
+func (s *ApiClientServiceImpl) Authenticate(key string) (*models.ApiClients, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, errors.New("invalid api key")
+	}
+
+	var client models.ApiClients
+	result := config.GetDB().Where("key_hash = ?", hashAPIKey(key)).Limit(1).Find(&client)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("invalid api key")
+	}
+	return &client, nil
+}
+
+// RegisteredUser reports whether the client registered userID, and so may act for that user
+func (s *ApiClientServiceImpl) RegisteredUser(clientID uint, userID int) (bool, error) {
+	var count int64
+	if err := config.GetDB().Model(&models.Users{}).Where("id = ? AND client_id = ?", userID, clientID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// hashAPIKey returns the hex SHA-256 of key. Keys are long random strings, so an
+// unsalted hash is enough to keep them out of the database.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
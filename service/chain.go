@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"wallet/config"
+	"wallet/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChainServiceImpl implements transaction hash chain interfaces
+type ChainServiceImpl struct{}
+
+// NewChainService creates chain service instance
+func NewChainService() *ChainServiceImpl {
+	return &ChainServiceImpl{}
+}
+
+// ChainReport is the outcome of walking the transaction hash chain
+type ChainReport struct {
+	OK          bool        `json:"ok"`
+	Checked     int         `json:"checked"`      // chained transactions verified
+	SoftDeleted int         `json:"soft_deleted"` // of which soft deleted; they stay in the chain
+	Unchained   int64       `json:"unchained"`    // committed transactions not yet appended to the chain
+	HeadSeq     uint64      `json:"head_seq"`
+	BrokenLink  *BrokenLink `json:"broken_link,omitempty"`
+}
+
+// BrokenLink describes the first point where the chain does not verify
+type BrokenLink struct {
+	Seq           uint64 `json:"seq"`
+	TransactionID uint   `json:"transaction_id,omitempty"`
+	Reason        string `json:"reason"`
+}
+
+// chainBatchSize is the number of transactions loaded per query while verifying
+const chainBatchSize = 1000
+
+// Verify walks the chain in sequence order, including soft deleted transactions,
+// and reports the first link that is missing, out of order or whose hash does not
+// match its content
+func (s *ChainServiceImpl) Verify() (*ChainReport, error) {
+	// Read the head and the rows in one snapshot so appends during the walk are ignored
+	db := config.GetDB().Begin()
+	defer db.Rollback()
+
+	report := &ChainReport{}
+	head, err := chainHead(db)
+	if err != nil {
+		return nil, err
+	}
+	report.HeadSeq = head.Seq
+
+	if err := db.Unscoped().Model(&models.Transaction{}).Where("seq = 0").Count(&report.Unchained).Error; err != nil {
+		return nil, err
+	}
+
+	var prev *models.Transaction
+	var lastSeq uint64
+	for {
+		var batch []models.Transaction
+		if result := db.Unscoped().Where("seq > ?", lastSeq).Order("seq ASC").Limit(chainBatchSize).Find(&batch); result.Error != nil {
+			return nil, result.Error
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for i := range batch {
+			transaction := &batch[i]
+			if broken := checkLink(prev, transaction); broken != nil {
+				report.BrokenLink = broken
+				return report, nil
+			}
+			report.Checked++
+			if transaction.DeletedAt.Valid {
+				report.SoftDeleted++
+			}
+			prev = transaction
+		}
+		lastSeq = batch[len(batch)-1].Seq
+	}
+
+	// The head must point at the last link, otherwise rows were removed from the end
+	switch {
+	case prev == nil && head.Seq != 0:
+		report.BrokenLink = &BrokenLink{Seq: 1, Reason: "chain head points at missing transactions"}
+	case prev != nil && (prev.Seq != head.Seq || prev.Hash != head.Hash):
+		report.BrokenLink = &BrokenLink{Seq: prev.Seq + 1, Reason: fmt.Sprintf("chain head is at seq %d but the last transaction is seq %d", head.Seq, prev.Seq)}
+	default:
+		report.OK = true
+	}
+
+	return report, nil
+}
+
+// Append adds committed transactions that are not in the chain yet to its end,
+// in batches of chain.batch_size, and returns how many it appended.
+//
+// Transactions are written with Seq 0 and chained here, after they commit, rather
+// than while they are written: locking the chain head inside every money movement
+// would serialize all of them behind one row. Only appenders lock the head now, so
+// two instances appending wait for each other but writers never do. A transaction
+// gets its place in the chain in the order it is picked up, which can differ from
+// its ID when an earlier transaction commits later.
+func (s *ChainServiceImpl) Append(ctx context.Context) (int, error) {
+	batchSize := config.GetConf().Chain.BatchSize
+	appended := 0
+	for ctx.Err() == nil {
+		n, err := appendChainBatch(config.GetDB().WithContext(ctx), batchSize)
+		appended += n
+		if err != nil || n < batchSize {
+			return appended, err
+		}
+	}
+	return appended, nil
+}
+
+// appendChainBatch chains up to batchSize unchained transactions in ID order in
+// one database transaction
+func appendChainBatch(db *gorm.DB, batchSize int) (int, error) {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var head models.ChainHead
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", models.ChainHeadID).First(&head); result.Error != nil {
+		tx.Rollback()
+		return 0, result.Error
+	}
+
+	// The IDs are read without locking, which would also lock the gap new
+	// transactions are inserted into; the rows themselves are then locked by ID so
+	// the content hashed is the latest
+	var ids []uint
+	if result := tx.Unscoped().Model(&models.Transaction{}).Where("seq = 0").
+		Order("id ASC").Limit(batchSize).Pluck("id", &ids); result.Error != nil {
+		tx.Rollback()
+		return 0, result.Error
+	}
+	if len(ids) == 0 {
+		tx.Rollback()
+		return 0, nil
+	}
+	var batch []models.Transaction
+	if result := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ? AND seq = 0", ids).Order("id ASC").Find(&batch); result.Error != nil {
+		tx.Rollback()
+		return 0, result.Error
+	}
+
+	for i := range batch {
+		transaction := &batch[i]
+		transaction.Seq = head.Seq + 1
+		transaction.PrevHash = head.Hash
+		transaction.Hash = transaction.ComputeHash()
+		if result := tx.Unscoped().Model(transaction).UpdateColumns(map[string]interface{}{
+			"seq":       transaction.Seq,
+			"prev_hash": transaction.PrevHash,
+			"hash":      transaction.Hash,
+		}); result.Error != nil {
+			tx.Rollback()
+			return 0, result.Error
+		}
+		head.Seq, head.Hash = transaction.Seq, transaction.Hash
+	}
+
+	if result := tx.Model(&head).Updates(map[string]interface{}{"seq": head.Seq, "hash": head.Hash}); result.Error != nil {
+		tx.Rollback()
+		return 0, result.Error
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return len(batch), nil
+}
+
+// checkLink verifies transaction against its predecessor in the chain, prev being
+// nil for the first link
+func checkLink(prev, transaction *models.Transaction) *BrokenLink {
+	expectedSeq, expectedPrevHash := uint64(1), ""
+	if prev != nil {
+		expectedSeq, expectedPrevHash = prev.Seq+1, prev.Hash
+	}
+
+	switch {
+	case transaction.Seq != expectedSeq:
+		return &BrokenLink{Seq: expectedSeq, Reason: fmt.Sprintf("transaction seq %d is missing", expectedSeq)}
+	case transaction.PrevHash != expectedPrevHash:
+		return &BrokenLink{Seq: transaction.Seq, TransactionID: transaction.ID, Reason: "previous hash does not match the preceding transaction"}
+	case transaction.ComputeHash() != transaction.Hash:
+		return &BrokenLink{Seq: transaction.Seq, TransactionID: transaction.ID, Reason: "hash does not match the transaction content"}
+	}
+
+	return nil
+}
+
+// chainHead reads the current chain head through db
+func chainHead(db *gorm.DB) (*models.ChainHead, error) {
+	var head models.ChainHead
+	if result := db.Where("id = ?", models.ChainHeadID).First(&head); result.Error != nil {
+		return nil, result.Error
+	}
+	return &head, nil
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"wallet/config"
+	"wallet/events"
+	"wallet/eventstore"
+	"wallet/fx"
+	"wallet/metrics"
+	"wallet/models"
+	"wallet/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm/clause"
+)
+
+// FxServiceImpl implements currency exchange service interfaces
+type FxServiceImpl struct {
+	ctx context.Context
+}
+
+// NewFxService creates currency exchange service instance
+func NewFxService() *FxServiceImpl {
+	return &FxServiceImpl{}
+}
+
+// WithContext returns a currency exchange service whose calls are traced as part of the
+// request or job in ctx
+func (s *FxServiceImpl) WithContext(ctx context.Context) *FxServiceImpl {
+	return &FxServiceImpl{ctx: ctx}
+}
+
+// ExchangeResult describes an executed exchange and the resulting balances
+type ExchangeResult struct {
+	Exchange    *models.FxExchanges `json:"exchange"`
+	FromBalance float64             `json:"from_balance"`
+	ToBalance   float64             `json:"to_balance"`
+}
+
+// CreateQuote prices a conversion for the user at the current rate less the
+// configured spread. The quote can be executed once before it expires.
+func (s *FxServiceImpl) CreateQuote(userID int, fromCurrency, toCurrency string) (quote *models.FxQuotes, err error) {
+	fromCurrency = normalizeCurrency(fromCurrency)
+	toCurrency = normalizeCurrency(toCurrency)
+	ctx, span := tracing.Start(s.ctx, "FxService.CreateQuote", attribute.Int("user_id", userID), attribute.String("from_currency", fromCurrency), attribute.String("to_currency", toCurrency))
+	defer func() { tracing.End(span, err) }()
+
+	if fromCurrency == toCurrency {
+		return nil, errors.New("same currency")
+	}
+	if !isSupportedCurrency(fromCurrency) || !isSupportedCurrency(toCurrency) {
+		return nil, errors.New("unsupported currency")
+	}
+
+	db := config.GetDB().WithContext(ctx)
+	var count int64
+	if err := db.Model(&models.Wallets{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errors.New("wallet not found")
+	}
+
+	provider, err := fx.DefaultProvider()
+	if err != nil {
+		return nil, err
+	}
+	midRate, err := provider.Rate(fromCurrency, toCurrency)
+	if err != nil {
+		if errors.Is(err, fx.ErrRateUnavailable) {
+			return nil, errors.New("rate unavailable")
+		}
+		return nil, err
+	}
+
+	conf := config.GetConf().FX
+	quote = &models.FxQuotes{
+		UserID:       userID,
+		FromCurrency: fromCurrency,
+		ToCurrency:   toCurrency,
+		MidRate:      midRate,
+		Spread:       conf.Spread,
+		Rate:         midRate * (1 - conf.Spread),
+		Status:       "open",
+		ExpiresAt:    time.Now().Add(conf.QuoteTTL),
+	}
+	if err := db.Create(quote).Error; err != nil {
+		return nil, err
+	}
+
+	return quote, nil
+}
+
+// Exchange executes a quote: it debits amount from the user's wallet in the
+// quote's source currency and credits the converted amount to the target
+// currency wallet in one transaction, recording both legs linked by the exchange.
+func (s *FxServiceImpl) Exchange(userID int, quoteID uint, amount float64) (result *ExchangeResult, err error) {
+	ctx, span := tracing.Start(s.ctx, "FxService.Exchange", attribute.Int("user_id", userID), attribute.Int64("quote_id", int64(quoteID)), attribute.Float64("amount", amount))
+	defer func() { tracing.End(span, err) }()
+
+	tx := config.GetDB().WithContext(ctx).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var quote models.FxQuotes
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND user_id = ?", quoteID, userID).First(&quote); result.Error != nil {
+		tx.Rollback()
+		return nil, errors.New("quote not found")
+	}
+	if quote.Status != "open" {
+		tx.Rollback()
+		return nil, errors.New("quote already used")
+	}
+	if time.Now().After(quote.ExpiresAt) {
+		tx.Rollback()
+		return nil, errors.New("quote expired")
+	}
+
+	toAmount := roundAmount(amount * quote.Rate)
+	if toAmount <= 0 {
+		tx.Rollback()
+		return nil, errors.New("amount too small")
+	}
+
+	fromWallet, toWallet, err := lockWalletPair(tx, userID, quote.FromCurrency, userID, quote.ToCurrency)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, errSourceWalletNotFound) {
+			return nil, errors.New("wallet not found")
+		}
+		return nil, err
+	}
+	if err := checkNotFrozen(fromWallet); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := checkNotFrozen(toWallet); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if fromWallet.Balance < amount {
+		tx.Rollback()
+		metrics.InsufficientBalance("exchange")
+		return nil, errors.New("insufficient balance")
+	}
+
+	fromWallet.Balance -= amount
+	toWallet.Balance += toAmount
+
+	if err := tx.Save(fromWallet).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Save(toWallet).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	exchange := &models.FxExchanges{
+		UserID:       userID,
+		QuoteID:      quote.ID,
+		FromCurrency: quote.FromCurrency,
+		ToCurrency:   quote.ToCurrency,
+		FromAmount:   amount,
+		ToAmount:     toAmount,
+		Rate:         quote.Rate,
+	}
+	if err := tx.Create(exchange).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Exchange %.2f %s to %.2f %s at %.8f", amount, quote.FromCurrency, toAmount, quote.ToCurrency, quote.Rate)
+	debit := models.Transaction{
+		Type:        "exchange",
+		FromUserID:  userID,
+		Amount:      amount,
+		Currency:    quote.FromCurrency,
+		ExchangeID:  exchange.ID,
+		Rate:        quote.Rate,
+		Description: description,
+		Status:      "completed",
+	}
+	if err := tx.Create(&debit).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	credit := models.Transaction{
+		Type:        "exchange",
+		ToUserID:    userID,
+		Amount:      toAmount,
+		Currency:    quote.ToCurrency,
+		ExchangeID:  exchange.ID,
+		Rate:        quote.Rate,
+		Description: description,
+		Status:      "completed",
+	}
+	if err := tx.Create(&credit).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	exchange.DebitTransactionID = debit.ID
+	exchange.CreditTransactionID = credit.ID
+	if err := tx.Save(exchange).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	quote.Status = "used"
+	if err := tx.Save(&quote).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := eventstore.Append(tx, fromWallet, eventstore.Debited(fromWallet, &debit)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := eventstore.Append(tx, toWallet, eventstore.Credited(toWallet, &credit)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := events.Record(tx, events.CurrencyExchanged, []string{events.WalletKey(fromWallet.ID), events.WalletKey(toWallet.ID)}, events.CurrencyExchangedData{
+		ExchangeID:          exchange.ID,
+		DebitTransactionID:  debit.ID,
+		CreditTransactionID: credit.ID,
+		UserID:              userID,
+		FromWalletID:        fromWallet.ID,
+		ToWalletID:          toWallet.ID,
+		FromCurrency:        quote.FromCurrency,
+		ToCurrency:          quote.ToCurrency,
+		FromAmount:          amount,
+		ToAmount:            toAmount,
+		Rate:                quote.Rate,
+		FromBalance:         fromWallet.Balance,
+		ToBalance:           toWallet.Balance,
+	}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	config.LoggerFromContext(ctx).Info("exchange completed", "user_id", userID, "exchange_id", exchange.ID,
+		"from_currency", quote.FromCurrency, "to_currency", quote.ToCurrency, "from_amount", amount, "to_amount", toAmount, "rate", quote.Rate)
+	return &ExchangeResult{
+		Exchange:    exchange,
+		FromBalance: fromWallet.Balance,
+		ToBalance:   toWallet.Balance,
+	}, nil
+}
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"wallet/config"
+	"wallet/events"
+	"wallet/eventstore"
+	"wallet/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// InterestServiceImpl implements interest service interfaces
+type InterestServiceImpl struct{}
+
+// NewInterestService creates interest service instance
+func NewInterestService() *InterestServiceImpl {
+	return &InterestServiceImpl{}
+}
+
+// InterestHistory lists a wallet's interest accruals and payouts
+type InterestHistory struct {
+	Accruals []models.InterestAccruals `json:"accruals"`
+	Payouts  []models.InterestPayouts  `json:"payouts"`
+}
+
+// annualRateFor returns the rate of the highest tier the balance reaches, using
+// the currency's own tiers when configured
+func annualRateFor(balance float64, currency string, conf config.InterestConf) float64 {
+	tiers := conf.Tiers
+	if currencyTiers, ok := conf.Currencies[currency]; ok {
+		tiers = currencyTiers
+	}
+
+	rate := 0.0
+	best := -1.0
+	for _, tier := range tiers {
+		if balance >= tier.MinBalance && tier.MinBalance > best {
+			best = tier.MinBalance
+			rate = tier.AnnualRate
+		}
+	}
+	return rate
+}
+
+// Accrue records one day of interest for every wallet with a positive end-of-day
+// balance. Wallets already accrued for that day are skipped, so repeating a run
+// is safe. It returns the number of new accruals.
+func (s *InterestServiceImpl) Accrue(day time.Time) (int, error) {
+	conf := config.GetConf()
+	if !conf.Interest.Enabled {
+		return 0, nil
+	}
+
+	accrualDate := startOfDay(day)
+	endOfDay := accrualDate.AddDate(0, 0, 1)
+
+	var walletIDs []uint
+	if err := config.GetDB().Model(&models.Wallets{}).Where("user_id <> ?", conf.Wallet.HouseUserID).
+		Order("id ASC").Pluck("id", &walletIDs).Error; err != nil {
+		return 0, err
+	}
+
+	accrued := 0
+	for _, walletID := range walletIDs {
+		ok, err := s.accrueWallet(walletID, accrualDate, endOfDay, conf.Interest)
+		if err != nil {
+			return accrued, err
+		}
+		if ok {
+			accrued++
+		}
+	}
+
+	return accrued, nil
+}
+
+// accrueWallet records one wallet's interest for the day ending at endOfDay, reporting
+// whether an accrual was made. The wallet row and the movements after the day are read
+// in one transaction, so a movement committing in between cannot skew the balance.
+func (s *InterestServiceImpl) accrueWallet(walletID uint, accrualDate, endOfDay time.Time, conf config.InterestConf) (bool, error) {
+	tx := config.GetDB().Begin()
+	defer tx.Rollback()
+
+	var wallet models.Wallets
+	if result := tx.Where("id = ?", walletID).First(&wallet); result.Error != nil {
+		return false, result.Error
+	}
+
+	var count int64
+	if err := tx.Model(&models.InterestAccruals{}).Where("wallet_id = ? AND accrual_date = ?", wallet.ID, accrualDate).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	eodBalance, err := balanceAt(tx, wallet.UserID, wallet.Currency, wallet.Balance, endOfDay)
+	if err != nil {
+		return false, err
+	}
+	if eodBalance <= 0 {
+		return false, nil
+	}
+
+	rate := annualRateFor(eodBalance, wallet.Currency, conf)
+	if rate <= 0 {
+		return false, nil
+	}
+
+	accrual := models.InterestAccruals{
+		WalletID:    wallet.ID,
+		UserID:      wallet.UserID,
+		AccrualDate: accrualDate,
+		Balance:     eodBalance,
+		AnnualRate:  rate,
+		Amount:      eodBalance * rate / 365,
+	}
+	if err := tx.Create(&accrual).Error; err != nil {
+		return false, err
+	}
+	return true, tx.Commit().Error
+}
+
+// Payout pays every wallet its unpaid interest accrued up to the end of the given
+// month, funded by the house wallet, or by no wallet when no house user is
+// configured. Each wallet is paid at most once per month; amounts below one cent
+// are left unpaid until a later payout, and what rounding a payout to cents leaves
+// over is recorded on it and carried into the next one. A wallet that cannot be
+// paid, e.g. while the house wallet is short of funds, is logged and skipped so
+// the others are still paid; the next run retries it. It returns the number of
+// wallets paid.
+func (s *InterestServiceImpl) Payout(month time.Time) (int, error) {
+	conf := config.GetConf()
+	if !conf.Interest.Enabled {
+		return 0, nil
+	}
+
+	monthStart := startOfDay(month).AddDate(0, 0, 1-month.In(time.Local).Day())
+	nextMonth := monthStart.AddDate(0, 1, 0)
+	period := monthStart.Format("2006-01")
+
+	var walletIDs []uint
+	if err := config.GetDB().Model(&models.InterestAccruals{}).
+		Where("payout_id = 0 AND accrual_date < ?", nextMonth).
+		Distinct("wallet_id").Pluck("wallet_id", &walletIDs).Error; err != nil {
+		return 0, err
+	}
+
+	paid := 0
+	for _, walletID := range walletIDs {
+		ok, err := s.payoutWallet(walletID, period, nextMonth, conf.Wallet.HouseUserID)
+		if err != nil {
+			config.GetLogger().Warn("interest payout skipped", "wallet_id", walletID, "period", period, "error", err.Error())
+			continue
+		}
+		if ok {
+			paid++
+		}
+	}
+
+	return paid, nil
+}
+
+// payoutWallet pays one wallet's unpaid accruals, reporting whether a payout was made
+func (s *InterestServiceImpl) payoutWallet(walletID uint, period string, before time.Time, houseUserID int) (bool, error) {
+	tx := config.GetDB().Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var count int64
+	if err := tx.Model(&models.InterestPayouts{}).Where("wallet_id = ? AND period = ?", walletID, period).Count(&count).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	var accruals []models.InterestAccruals
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("wallet_id = ? AND payout_id = 0 AND accrual_date < ?", walletID, before).
+		Find(&accruals); result.Error != nil {
+		tx.Rollback()
+		return false, result.Error
+	}
+
+	// The part of the previous payout lost to rounding to cents is carried into this one
+	var previous models.InterestPayouts
+	if result := tx.Where("wallet_id = ?", walletID).Order("id DESC").Limit(1).Find(&previous); result.Error != nil {
+		tx.Rollback()
+		return false, result.Error
+	}
+
+	total := previous.Remainder
+	for _, accrual := range accruals {
+		total += accrual.Amount
+	}
+	amount := roundAmount(total)
+	if amount < 0.01 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	var owner models.Wallets
+	if result := tx.Select("user_id", "currency").Where("id = ?", walletID).First(&owner); result.Error != nil {
+		tx.Rollback()
+		return false, errors.New("wallet not found")
+	}
+	// The house does not pay itself; Accrue skips its wallets
+	if owner.UserID == houseUserID {
+		tx.Rollback()
+		return false, nil
+	}
+
+	// Without a house user the interest is paid in without a paying wallet, as
+	// overdraft interest is then charged without a receiving one
+	var wallet, houseWallet *models.Wallets
+	var err error
+	if houseUserID == 0 {
+		wallet, err = lockOrCreateWallet(tx, owner.UserID, owner.Currency)
+	} else {
+		// Locked in the same order as transfers, so a payout and a transfer
+		// between the house and the same user cannot deadlock
+		houseWallet, wallet, err = lockWalletPair(tx, houseUserID, owner.Currency, owner.UserID, owner.Currency)
+	}
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, errSourceWalletNotFound) {
+			return false, errors.New("house wallet not found")
+		}
+		return false, err
+	}
+
+	keys := []string{events.WalletKey(wallet.ID)}
+	if houseWallet != nil {
+		if houseWallet.Balance+houseWallet.OverdraftLimit < amount {
+			tx.Rollback()
+			return false, errors.New("insufficient house balance")
+		}
+		houseWallet.Balance -= amount
+		if err := tx.Save(houseWallet).Error; err != nil {
+			tx.Rollback()
+			return false, err
+		}
+		keys = append(keys, events.WalletKey(houseWallet.ID))
+	}
+
+	wallet.Balance += amount
+	if err := tx.Save(wallet).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	transaction := models.Transaction{
+		Type:        "interest",
+		FromUserID:  houseUserID,
+		ToUserID:    wallet.UserID,
+		Amount:      amount,
+		Currency:    wallet.Currency,
+		Description: "Interest for " + period,
+		Status:      "completed",
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if houseWallet != nil {
+		if err := eventstore.Append(tx, houseWallet, eventstore.Debited(houseWallet, &transaction)); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	if err := eventstore.Append(tx, wallet, eventstore.Credited(wallet, &transaction)); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := events.Record(tx, events.InterestPaid, keys, events.BalanceChangedData{
+		TransactionID: transaction.ID,
+		UserID:        wallet.UserID,
+		WalletID:      wallet.ID,
+		Currency:      wallet.Currency,
+		Amount:        amount,
+		Balance:       wallet.Balance,
+		Description:   transaction.Description,
+	}); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	payout := models.InterestPayouts{
+		WalletID:      walletID,
+		UserID:        wallet.UserID,
+		Period:        period,
+		Amount:        amount,
+		Remainder:     total - amount,
+		TransactionID: transaction.ID,
+	}
+	if err := tx.Create(&payout).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	ids := make([]uint, 0, len(accruals))
+	for _, accrual := range accruals {
+		ids = append(ids, accrual.ID)
+	}
+	if err := tx.Model(&models.InterestAccruals{}).Where("id IN ?", ids).Update("payout_id", payout.ID).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetInterestHistory retrieves the most recent accruals and payouts of a user's wallet in currency
+func (s *InterestServiceImpl) GetInterestHistory(userID int, currency string, limit int) (*InterestHistory, error) {
+	var wallet models.Wallets
+	if result := config.GetDB().Where("user_id = ? AND currency = ?", userID, normalizeCurrency(currency)).First(&wallet); result.Error != nil {
+		return nil, errors.New("wallet not found")
+	}
+
+	history := &InterestHistory{}
+	if result := config.GetDB().Where("wallet_id = ?", wallet.ID).Order("accrual_date DESC").Limit(limit).Find(&history.Accruals); result.Error != nil {
+		return nil, result.Error
+	}
+	if result := config.GetDB().Where("wallet_id = ?", wallet.ID).Order("period DESC").Limit(limit).Find(&history.Payouts); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return history, nil
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"wallet/config"
+	"wallet/eventstore"
+	"wallet/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// normalizeCurrency upper-cases a currency code, defaulting to the configured currency
+func normalizeCurrency(currency string) string {
+	if currency == "" {
+		return config.GetConf().Wallet.DefaultCurrency
+	}
+	return strings.ToUpper(currency)
+}
+
+// defaultCurrency returns the currency of the wallet created at registration
+func defaultCurrency() string {
+	return config.GetConf().Wallet.DefaultCurrency
+}
+
+// isSupportedCurrency reports whether wallets may be held in currency
+func isSupportedCurrency(currency string) bool {
+	return slices.Contains(config.GetConf().Wallet.Currencies, currency)
+}
+
+// lockOrCreateWallet locks a user's wallet in currency, creating it when the user
+// has none yet. The user must already have a wallet in the default currency.
+func lockOrCreateWallet(tx *gorm.DB, userID int, currency string) (*models.Wallets, error) {
+	var wallet models.Wallets
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND currency = ?", userID, currency).First(&wallet)
+	if result.Error == nil {
+		return &wallet, nil
+	}
+	if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, result.Error
+	}
+
+	var count int64
+	if err := tx.Model(&models.Wallets{}).Where("user_id = ? AND currency = ?", userID, defaultCurrency()).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errors.New("wallet not found")
+	}
+
+	wallet = models.Wallets{UserID: userID, Currency: currency}
+	if err := tx.Create(&wallet).Error; err != nil {
+		// A concurrent movement may have opened the wallet first (idx_wallet_user_currency);
+		// the locking read waits for it to commit and returns its row
+		var existing models.Wallets
+		if tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND currency = ?", userID, currency).First(&existing).Error == nil {
+			return &existing, nil
+		}
+		return nil, err
+	}
+	if err := eventstore.Append(tx, &wallet, eventstore.Opened(&wallet)); err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
+
+// errSourceWalletNotFound is returned by lockWalletPair when the wallet funds move out of does not exist
+var errSourceWalletNotFound = errors.New("source wallet not found")
+
+// lockWalletPair locks the wallet funds move out of and the wallet they move into,
+// opening the latter as lockOrCreateWallet does. The two are locked in one fixed
+// order, ascending user ID and then wallet ID, so movements running in opposite
+// directions between the same wallets wait for each other instead of deadlocking.
+// A wallet still to be opened sorts last, as it will get the highest ID.
+func lockWalletPair(tx *gorm.DB, fromUserID int, fromCurrency string, toUserID int, toCurrency string) (from, to *models.Wallets, err error) {
+	fromFirst := fromUserID < toUserID
+	if fromUserID == toUserID {
+		fromID, err := walletIDOf(tx, fromUserID, fromCurrency)
+		if err != nil {
+			return nil, nil, err
+		}
+		toID, err := walletIDOf(tx, toUserID, toCurrency)
+		if err != nil {
+			return nil, nil, err
+		}
+		fromFirst = toID == 0 || (fromID != 0 && fromID < toID)
+	}
+
+	lockFrom := func() error {
+		var wallet models.Wallets
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND currency = ?", fromUserID, fromCurrency).First(&wallet)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return errSourceWalletNotFound
+		}
+		from = &wallet
+		return result.Error
+	}
+	lockTo := func() (err error) {
+		to, err = lockOrCreateWallet(tx, toUserID, toCurrency)
+		return err
+	}
+
+	if fromFirst {
+		if err := lockFrom(); err != nil {
+			return nil, nil, err
+		}
+		if err := lockTo(); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if err := lockTo(); err != nil {
+			return nil, nil, err
+		}
+		if err := lockFrom(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return from, to, nil
+}
+
+// walletIDOf returns the ID of a user's wallet in currency without locking it, 0 when there is none
+func walletIDOf(tx *gorm.DB, userID int, currency string) (uint, error) {
+	var ids []uint
+	if err := tx.Model(&models.Wallets{}).Where("user_id = ? AND currency = ?", userID, currency).Limit(1).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return ids[0], nil
+}
+
+// checkNotFrozen rejects movements on wallets frozen after a failed reconciliation
+func checkNotFrozen(wallet *models.Wallets) error {
+	if wallet.Frozen {
+		return errors.New("wallet frozen")
+	}
+	return nil
+}
+
+// roundAmount rounds a money amount to cents
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// startOfDay returns midnight of t's day in local time
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.In(time.Local).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+// balanceAt derives a user's main balance in currency at the given time by
+// reverting every movement recorded after it from the current balance
+func balanceAt(db *gorm.DB, userID int, currency string, current float64, at time.Time) (float64, error) {
+	var credits, debits float64
+	if err := db.Model(&models.Transaction{}).
+		Where("to_user_id = ? AND currency = ? AND created_at > ?", userID, currency, at).
+		Select("COALESCE(SUM(amount), 0)").Scan(&credits).Error; err != nil {
+		return 0, err
+	}
+	if err := db.Model(&models.Transaction{}).
+		Where("from_user_id = ? AND currency = ? AND created_at > ?", userID, currency, at).
+		Select("COALESCE(SUM(amount), 0)").Scan(&debits).Error; err != nil {
+		return 0, err
+	}
+
+	return roundAmount(current - credits + debits), nil
+}
+
+// netMovement sums the credits less the debits of a user's main balance in currency
+// for transactions created in (after, upTo]
+func netMovement(db *gorm.DB, userID int, currency string, after, upTo time.Time) (float64, error) {
+	var credits, debits float64
+	if err := db.Model(&models.Transaction{}).
+		Where("to_user_id = ? AND currency = ? AND created_at > ? AND created_at <= ?", userID, currency, after, upTo).
+		Select("COALESCE(SUM(amount), 0)").Scan(&credits).Error; err != nil {
+		return 0, err
+	}
+	if err := db.Model(&models.Transaction{}).
+		Where("from_user_id = ? AND currency = ? AND created_at > ? AND created_at <= ?", userID, currency, after, upTo).
+		Select("COALESCE(SUM(amount), 0)").Scan(&debits).Error; err != nil {
+		return 0, err
+	}
+
+	return credits - debits, nil
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"wallet/config"
+	"wallet/events"
+	"wallet/eventstore"
+	"wallet/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// OverdraftServiceImpl implements overdraft service interfaces
+type OverdraftServiceImpl struct{}
+
+// NewOverdraftService creates overdraft service instance
+func NewOverdraftService() *OverdraftServiceImpl {
+	return &OverdraftServiceImpl{}
+}
+
+// SetOverdraftLimit sets the credit line of a user's wallet. Lowering it below the
+// credit already used is allowed; further spending is then rejected until repaid.
+func (s *OverdraftServiceImpl) SetOverdraftLimit(userID int, currency string, limit float64) (*models.Wallets, error) {
+	tx := config.GetDB().Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var wallet models.Wallets
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND currency = ?", userID, normalizeCurrency(currency)).First(&wallet); result.Error != nil {
+		tx.Rollback()
+		return nil, errors.New("wallet not found")
+	}
+
+	wallet.OverdraftLimit = limit
+	if err := tx.Model(&wallet).Update("overdraft_limit", limit).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := eventstore.Append(tx, &wallet, eventstore.LimitSet(limit)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
+
+// ChargeInterest charges the configured daily rate on every wallet whose balance was
+// negative at the end of day. Each wallet is charged at most once per day, so
+// repeating a run for the same day is a no-op. It returns the number of wallets charged.
+func (s *OverdraftServiceImpl) ChargeInterest(day time.Time) (int, error) {
+	rate := config.GetConf().Wallet.OverdraftDailyRate
+	if rate <= 0 {
+		return 0, nil
+	}
+
+	chargeDate := startOfDay(day)
+	endOfDay := chargeDate.AddDate(0, 0, 1)
+
+	var wallets []models.Wallets
+	if result := config.GetDB().Where("balance < 0 OR overdraft_limit > 0").Find(&wallets); result.Error != nil {
+		return 0, result.Error
+	}
+
+	charged := 0
+	for _, wallet := range wallets {
+		ok, err := s.chargeWallet(wallet.ID, chargeDate, endOfDay, rate)
+		if err != nil {
+			return charged, err
+		}
+		if ok {
+			charged++
+		}
+	}
+
+	return charged, nil
+}
+
+// chargeWallet charges one wallet for chargeDate, reporting whether a charge was made
+func (s *OverdraftServiceImpl) chargeWallet(walletID uint, chargeDate, endOfDay time.Time, rate float64) (bool, error) {
+	// Read outside the transaction, so its snapshot is taken only after the locks below
+	var owner models.Wallets
+	if result := config.GetDB().Select("user_id", "currency").Where("id = ?", walletID).First(&owner); result.Error != nil {
+		return false, errors.New("wallet not found")
+	}
+
+	tx := config.GetDB().Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// The charged wallet and the house wallet are locked in the same order as
+	// transfers and interest payouts, so none of them can deadlock another
+	houseUserID := config.GetConf().Wallet.HouseUserID
+	var wallet, houseWallet *models.Wallets
+	var err error
+	if houseUserID != 0 && houseUserID != owner.UserID {
+		wallet, houseWallet, err = lockWalletPair(tx, owner.UserID, owner.Currency, houseUserID, owner.Currency)
+		if err != nil {
+			tx.Rollback()
+			if errors.Is(err, errSourceWalletNotFound) {
+				return false, errors.New("wallet not found")
+			}
+			return false, errors.New("house wallet not found")
+		}
+	} else {
+		wallet = &models.Wallets{}
+		if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", walletID).First(wallet); result.Error != nil {
+			tx.Rollback()
+			return false, errors.New("wallet not found")
+		}
+	}
+
+	var count int64
+	if err := tx.Model(&models.OverdraftCharges{}).Where("wallet_id = ? AND charge_date = ?", wallet.ID, chargeDate).Count(&count).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	userID := wallet.UserID
+	eodBalance, err := balanceAt(tx, userID, wallet.Currency, wallet.Balance, endOfDay)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	amount := roundAmount(-eodBalance * rate)
+	if amount <= 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	transaction := models.Transaction{
+		Type:        "overdraft_interest",
+		FromUserID:  userID,
+		Amount:      amount,
+		Currency:    wallet.Currency,
+		Description: "Overdraft interest for " + chargeDate.Format("2006-01-02"),
+		Status:      "completed",
+	}
+
+	keys := []string{events.WalletKey(wallet.ID)}
+	if houseWallet != nil {
+		houseWallet.Balance += amount
+		if err := tx.Save(houseWallet).Error; err != nil {
+			tx.Rollback()
+			return false, err
+		}
+		transaction.ToUserID = houseUserID
+		keys = append(keys, events.WalletKey(houseWallet.ID))
+	}
+
+	wallet.Balance -= amount
+	if err := tx.Save(wallet).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Create(&transaction).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := eventstore.Append(tx, wallet, eventstore.Debited(wallet, &transaction)); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if houseWallet != nil {
+		if err := eventstore.Append(tx, houseWallet, eventstore.Credited(houseWallet, &transaction)); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	if err := events.Record(tx, events.OverdraftInterestCharged, keys, events.BalanceChangedData{
+		TransactionID: transaction.ID,
+		UserID:        userID,
+		WalletID:      wallet.ID,
+		Currency:      wallet.Currency,
+		Amount:        amount,
+		Balance:       wallet.Balance,
+		Description:   transaction.Description,
+	}); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	charge := models.OverdraftCharges{
+		WalletID:      wallet.ID,
+		UserID:        userID,
+		ChargeDate:    chargeDate,
+		Balance:       eodBalance,
+		Rate:          rate,
+		Amount:        amount,
+		TransactionID: transaction.ID,
+	}
+	if err := tx.Create(&charge).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
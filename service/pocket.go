@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"wallet/config"
+	"wallet/events"
+	"wallet/eventstore"
+	"wallet/metrics"
+	"wallet/models"
+	"wallet/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm/clause"
+)
+
+// PocketServiceImpl implements pocket service interfaces
+type PocketServiceImpl struct {
+	ctx context.Context
+}
+
+// NewPocketService creates pocket service instance
+func NewPocketService() *PocketServiceImpl {
+	return &PocketServiceImpl{}
+}
+
+// WithContext returns a pocket service whose calls are traced as part of the
+// request or job in ctx
+func (s *PocketServiceImpl) WithContext(ctx context.Context) *PocketServiceImpl {
+	return &PocketServiceImpl{ctx: ctx}
+}
+
+// CreatePocket creates a named pocket under the user's default currency wallet
+func (s *PocketServiceImpl) CreatePocket(userID int, name string, goalAmount float64) (*models.Pockets, error) {
+	var wallet models.Wallets
+	if result := config.GetDB().Where("user_id = ? AND currency = ?", userID, defaultCurrency()).First(&wallet); result.Error != nil {
+		return nil, errors.New("wallet not found")
+	}
+
+	var count int64
+	if err := config.GetDB().Model(&models.Pockets{}).Where("wallet_id = ? AND name = ?", wallet.ID, name).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, errors.New("pocket already exists")
+	}
+
+	pocket := &models.Pockets{
+		WalletID:   wallet.ID,
+		UserID:     userID,
+		Name:       name,
+		GoalAmount: goalAmount,
+	}
+	if err := config.GetDB().Create(pocket).Error; err != nil {
+		return nil, err
+	}
+
+	return pocket, nil
+}
+
+// GetPockets retrieves all pockets of the user's wallet
+func (s *PocketServiceImpl) GetPockets(userID int) ([]models.Pockets, error) {
+	var pockets []models.Pockets
+	if result := config.GetDB().Where("user_id = ?", userID).Order("id ASC").Find(&pockets); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return pockets, nil
+}
+
+// MoveToPocket moves funds from the main balance into a pocket
+func (s *PocketServiceImpl) MoveToPocket(userID int, pocketID uint, amount float64, description string) (mainBalance, pocketBalance float64, err error) {
+	ctx, span := tracing.Start(s.ctx, "PocketService.MoveToPocket", attribute.Int("user_id", userID), attribute.Int64("pocket_id", int64(pocketID)), attribute.Float64("amount", amount))
+	defer func() { tracing.End(span, err) }()
+
+	return s.move(ctx, userID, pocketID, amount, description, true)
+}
+
+// MoveFromPocket moves funds from a pocket back into the main balance
+func (s *PocketServiceImpl) MoveFromPocket(userID int, pocketID uint, amount float64, description string) (mainBalance, pocketBalance float64, err error) {
+	ctx, span := tracing.Start(s.ctx, "PocketService.MoveFromPocket", attribute.Int("user_id", userID), attribute.Int64("pocket_id", int64(pocketID)), attribute.Float64("amount", amount))
+	defer func() { tracing.End(span, err) }()
+
+	return s.move(ctx, userID, pocketID, amount, description, false)
+}
+
+// move shifts amount between the main balance and a pocket and records the move,
+// returning the new main and pocket balances
+func (s *PocketServiceImpl) move(ctx context.Context, userID int, pocketID uint, amount float64, description string, toPocket bool) (float64, float64, error) {
+	tx := config.GetDB().WithContext(ctx).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var wallet models.Wallets
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND currency = ?", userID, defaultCurrency()).First(&wallet); result.Error != nil {
+		tx.Rollback()
+		return 0, 0, errors.New("wallet not found")
+	}
+	if err := checkNotFrozen(&wallet); err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	var pocket models.Pockets
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND wallet_id = ?", pocketID, wallet.ID).First(&pocket); result.Error != nil {
+		tx.Rollback()
+		return 0, 0, errors.New("pocket not found")
+	}
+
+	transaction := models.Transaction{
+		Amount:      amount,
+		Currency:    wallet.Currency,
+		PocketID:    pocket.ID,
+		Description: description,
+		Status:      "completed",
+	}
+
+	if toPocket {
+		if wallet.Balance < amount {
+			tx.Rollback()
+			metrics.InsufficientBalance("pocket_in")
+			return 0, 0, errors.New("insufficient balance")
+		}
+		wallet.Balance -= amount
+		pocket.Balance += amount
+		transaction.Type = "pocket_in"
+		transaction.FromUserID = userID
+	} else {
+		if pocket.Balance < amount {
+			tx.Rollback()
+			metrics.InsufficientBalance("pocket_out")
+			return 0, 0, errors.New("insufficient pocket balance")
+		}
+		pocket.Balance -= amount
+		wallet.Balance += amount
+		transaction.Type = "pocket_out"
+		transaction.ToUserID = userID
+	}
+
+	if err := tx.Save(&wallet).Error; err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	if err := tx.Save(&pocket).Error; err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	if err := tx.Create(&transaction).Error; err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	change := eventstore.Credited(&wallet, &transaction)
+	if toPocket {
+		change = eventstore.Debited(&wallet, &transaction)
+	}
+	if err := eventstore.Append(tx, &wallet, change); err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	direction := "out"
+	if toPocket {
+		direction = "in"
+	}
+	if err := events.Record(tx, events.PocketMoved, []string{events.WalletKey(wallet.ID)}, events.PocketMovedData{
+		TransactionID: transaction.ID,
+		UserID:        userID,
+		WalletID:      wallet.ID,
+		PocketID:      pocket.ID,
+		Currency:      wallet.Currency,
+		Direction:     direction,
+		Amount:        amount,
+		Balance:       wallet.Balance,
+		PocketBalance: pocket.Balance,
+	}); err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, 0, err
+	}
+
+	config.LoggerFromContext(ctx).Info("pocket move completed", "user_id", userID, "pocket_id", pocket.ID,
+		"transaction_id", transaction.ID, "direction", direction, "amount", amount, "pocket_balance", pocket.Balance)
+	return wallet.Balance, pocket.Balance, nil
+}
@@ -0,0 +1,443 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"wallet/config"
+	"wallet/eventstore"
+	"wallet/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReconciliationServiceImpl implements ledger reconciliation interfaces
+type ReconciliationServiceImpl struct{}
+
+// NewReconciliationService creates reconciliation service instance
+func NewReconciliationService() *ReconciliationServiceImpl {
+	return &ReconciliationServiceImpl{}
+}
+
+// ReconciliationReport is the outcome of a reconciliation run
+type ReconciliationReport struct {
+	Run           models.ReconciliationRuns            `json:"run"`
+	Discrepancies []models.ReconciliationDiscrepancies `json:"discrepancies"`
+	Invariants    []InvariantCheck                     `json:"invariants,omitempty"`
+}
+
+// InvariantCheck compares the money held in a currency with the net of the flows
+// that bring money into or take it out of the system
+type InvariantCheck struct {
+	Currency string  `json:"currency"`
+	Held     float64 `json:"held"`     // wallets, pockets and shared wallets
+	Expected float64 `json:"expected"` // deposits and exchanges in less withdrawals, exchanges out and unpaid fees
+	OK       bool    `json:"ok"`
+}
+
+// ledgerTotal is the sum of amounts for one group of transactions
+type ledgerTotal struct {
+	ID       int
+	Type     string
+	Currency string
+	Inbound  bool
+	Total    float64
+}
+
+// balanceKey identifies a user's main balance in one currency
+type balanceKey struct {
+	UserID   int
+	Currency string
+}
+
+// reconcileTolerance is the smallest difference reported; smaller ones are rounding noise
+const reconcileTolerance = 0.005
+
+// Run recomputes every wallet, pocket and shared wallet balance from the transaction
+// history, compares it with the stored balance and checks the per-currency money
+// invariant. With freeze set, wallets with discrepancies are frozen.
+func (s *ReconciliationServiceImpl) Run(trigger string, freeze bool) (*ReconciliationReport, error) {
+	report := &ReconciliationReport{
+		Run: models.ReconciliationRuns{
+			Trigger:   trigger,
+			Freeze:    freeze,
+			StartedAt: time.Now(),
+		},
+		Discrepancies: []models.ReconciliationDiscrepancies{},
+	}
+
+	// Read balances and history in one transaction so they come from the same
+	// snapshot and concurrent movements do not show up as discrepancies
+	tx := config.GetDB().Begin()
+	err := s.compare(tx, report)
+	tx.Rollback()
+	if err != nil {
+		return nil, err
+	}
+
+	report.Run.Discrepancies = len(report.Discrepancies)
+	report.Run.Status = "ok"
+	if report.Run.Discrepancies > 0 {
+		report.Run.Status = "discrepancies"
+	}
+
+	if freeze {
+		frozen, err := s.freezeAffected(report.Discrepancies)
+		if err != nil {
+			return nil, err
+		}
+		report.Run.WalletsFrozen = frozen
+	}
+
+	finishedAt := time.Now()
+	report.Run.FinishedAt = &finishedAt
+	if err := config.GetDB().Create(&report.Run).Error; err != nil {
+		return nil, err
+	}
+	for i := range report.Discrepancies {
+		report.Discrepancies[i].RunID = report.Run.ID
+	}
+	if len(report.Discrepancies) > 0 {
+		if err := config.GetDB().CreateInBatches(report.Discrepancies, 100).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// compare fills report with the discrepancies and invariant checks found through db
+func (s *ReconciliationServiceImpl) compare(db *gorm.DB, report *ReconciliationReport) error {
+	held := make(map[string]float64)
+
+	// Main balances: credits paid to the user less debits paid by the user
+	var credits, debits []ledgerTotal
+	if err := db.Model(&models.Transaction{}).Select("to_user_id AS id, currency, SUM(amount) AS total").
+		Where("to_user_id <> 0").Group("to_user_id, currency").Scan(&credits).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&models.Transaction{}).Select("from_user_id AS id, currency, SUM(amount) AS total").
+		Where("from_user_id <> 0").Group("from_user_id, currency").Scan(&debits).Error; err != nil {
+		return err
+	}
+	computed := make(map[balanceKey]float64)
+	for _, credit := range credits {
+		computed[balanceKey{credit.ID, credit.Currency}] += credit.Total
+	}
+	for _, debit := range debits {
+		computed[balanceKey{debit.ID, debit.Currency}] -= debit.Total
+	}
+
+	var wallets []models.Wallets
+	if result := db.Order("id ASC").Find(&wallets); result.Error != nil {
+		return result.Error
+	}
+	report.Run.WalletsChecked = len(wallets)
+
+	walletCurrency := make(map[uint]string, len(wallets))
+	for _, wallet := range wallets {
+		walletCurrency[wallet.ID] = wallet.Currency
+		held[wallet.Currency] += wallet.Balance
+
+		key := balanceKey{wallet.UserID, wallet.Currency}
+		expected := roundAmount(computed[key])
+		delete(computed, key)
+		if math.Abs(wallet.Balance-expected) >= reconcileTolerance {
+			report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancies{
+				Kind:       "wallet",
+				WalletID:   wallet.ID,
+				UserID:     wallet.UserID,
+				Currency:   wallet.Currency,
+				Stored:     wallet.Balance,
+				Computed:   expected,
+				Difference: roundAmount(wallet.Balance - expected),
+				Detail:     "stored balance does not match the transaction history",
+			})
+		}
+		if wallet.Balance < -wallet.OverdraftLimit-reconcileTolerance {
+			report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancies{
+				Kind:       "overdraft_limit",
+				WalletID:   wallet.ID,
+				UserID:     wallet.UserID,
+				Currency:   wallet.Currency,
+				Stored:     wallet.Balance,
+				Computed:   -wallet.OverdraftLimit,
+				Difference: roundAmount(wallet.Balance + wallet.OverdraftLimit),
+				Detail:     "balance is below the overdraft limit",
+			})
+		}
+	}
+
+	// History left over belongs to users without a wallet in that currency
+	for key, total := range computed {
+		if math.Abs(total) >= reconcileTolerance {
+			report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancies{
+				Kind:       "wallet",
+				UserID:     key.UserID,
+				Currency:   key.Currency,
+				Computed:   roundAmount(total),
+				Difference: roundAmount(-total),
+				Detail:     "transactions reference a wallet that does not exist",
+			})
+		}
+	}
+
+	if err := s.comparePockets(db, report, walletCurrency, held); err != nil {
+		return err
+	}
+	if err := s.compareSharedWallets(db, report, held); err != nil {
+		return err
+	}
+
+	return s.checkInvariants(db, report, held)
+}
+
+// comparePockets checks each pocket against its pocket_in less pocket_out moves
+func (s *ReconciliationServiceImpl) comparePockets(db *gorm.DB, report *ReconciliationReport, walletCurrency map[uint]string, held map[string]float64) error {
+	var totals []ledgerTotal
+	if err := db.Model(&models.Transaction{}).Select("pocket_id AS id, type, SUM(amount) AS total").
+		Where("pocket_id <> 0").Group("pocket_id, type").Scan(&totals).Error; err != nil {
+		return err
+	}
+	computed := make(map[uint]float64)
+	for _, total := range totals {
+		switch total.Type {
+		case "pocket_in":
+			computed[uint(total.ID)] += total.Total
+		case "pocket_out":
+			computed[uint(total.ID)] -= total.Total
+		}
+	}
+
+	var pockets []models.Pockets
+	if result := db.Order("id ASC").Find(&pockets); result.Error != nil {
+		return result.Error
+	}
+	for _, pocket := range pockets {
+		currency := walletCurrency[pocket.WalletID]
+		held[currency] += pocket.Balance
+
+		expected := roundAmount(computed[pocket.ID])
+		if math.Abs(pocket.Balance-expected) >= reconcileTolerance {
+			report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancies{
+				Kind:       "pocket",
+				WalletID:   pocket.ID,
+				UserID:     pocket.UserID,
+				Currency:   currency,
+				Stored:     pocket.Balance,
+				Computed:   expected,
+				Difference: roundAmount(pocket.Balance - expected),
+				Detail:     fmt.Sprintf("pocket %q of wallet %d does not match its moves", pocket.Name, pocket.WalletID),
+			})
+		}
+	}
+
+	return nil
+}
+
+// compareSharedWallets checks each shared wallet against its deposits less its spends
+func (s *ReconciliationServiceImpl) compareSharedWallets(db *gorm.DB, report *ReconciliationReport, held map[string]float64) error {
+	var totals []ledgerTotal
+	if err := db.Model(&models.Transaction{}).Select("shared_wallet_id AS id, type, SUM(amount) AS total").
+		Where("shared_wallet_id <> 0").Group("shared_wallet_id, type").Scan(&totals).Error; err != nil {
+		return err
+	}
+	computed := make(map[uint]float64)
+	for _, total := range totals {
+		switch total.Type {
+		case "shared_deposit":
+			computed[uint(total.ID)] += total.Total
+		case "shared_withdraw", "shared_transfer":
+			computed[uint(total.ID)] -= total.Total
+		}
+	}
+
+	var sharedWallets []models.SharedWallets
+	if result := db.Order("id ASC").Find(&sharedWallets); result.Error != nil {
+		return result.Error
+	}
+	for _, sharedWallet := range sharedWallets {
+		held[sharedWallet.Currency] += sharedWallet.Balance
+
+		expected := roundAmount(computed[sharedWallet.ID])
+		if math.Abs(sharedWallet.Balance-expected) >= reconcileTolerance {
+			report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancies{
+				Kind:       "shared_wallet",
+				WalletID:   sharedWallet.ID,
+				Currency:   sharedWallet.Currency,
+				Stored:     sharedWallet.Balance,
+				Computed:   expected,
+				Difference: roundAmount(sharedWallet.Balance - expected),
+				Detail:     fmt.Sprintf("shared wallet %q does not match its movements", sharedWallet.Name),
+			})
+		}
+	}
+
+	return nil
+}
+
+// checkInvariants checks, per currency, that the money held equals deposits less
+// withdrawals, adjusted for exchanges and for interest not paid by or to a house wallet
+func (s *ReconciliationServiceImpl) checkInvariants(db *gorm.DB, report *ReconciliationReport, held map[string]float64) error {
+	var totals []ledgerTotal
+	if err := db.Model(&models.Transaction{}).Select("type, currency, to_user_id <> 0 AS inbound, SUM(amount) AS total").
+		Where("type IN ?", []string{"deposit", "withdraw", "shared_withdraw", "exchange", "overdraft_interest"}).
+		Group("type, currency, to_user_id <> 0").Scan(&totals).Error; err != nil {
+		return err
+	}
+	// Interest paid while no house user was configured came from no wallet
+	var unfunded []ledgerTotal
+	if err := db.Model(&models.Transaction{}).Select("type, currency, SUM(amount) AS total").
+		Where("type = ? AND from_user_id = 0", "interest").
+		Group("type, currency").Scan(&unfunded).Error; err != nil {
+		return err
+	}
+
+	expected := make(map[string]float64)
+	for _, total := range totals {
+		switch {
+		case total.Type == "deposit", total.Type == "exchange" && total.Inbound:
+			expected[total.Currency] += total.Total
+		case total.Type == "withdraw", total.Type == "shared_withdraw", total.Type == "exchange" && !total.Inbound:
+			expected[total.Currency] -= total.Total
+		case total.Type == "overdraft_interest" && !total.Inbound:
+			expected[total.Currency] -= total.Total
+		}
+	}
+	for _, total := range unfunded {
+		expected[total.Currency] += total.Total
+	}
+
+	currencies := make([]string, 0, len(held))
+	for currency := range held {
+		currencies = append(currencies, currency)
+	}
+	for currency := range expected {
+		if _, ok := held[currency]; !ok {
+			currencies = append(currencies, currency)
+		}
+	}
+	sort.Strings(currencies)
+
+	for _, currency := range currencies {
+		check := InvariantCheck{
+			Currency: currency,
+			Held:     roundAmount(held[currency]),
+			Expected: roundAmount(expected[currency]),
+		}
+		check.OK = math.Abs(check.Held-check.Expected) < reconcileTolerance
+		report.Invariants = append(report.Invariants, check)
+
+		if !check.OK {
+			report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancies{
+				Kind:       "invariant",
+				Currency:   currency,
+				Stored:     check.Held,
+				Computed:   check.Expected,
+				Difference: roundAmount(check.Held - check.Expected),
+				Detail:     "money held does not equal the net of deposits, withdrawals and exchanges",
+			})
+		}
+	}
+
+	return nil
+}
+
+// freezeAffected freezes the wallets behind wallet, overdraft and pocket
+// discrepancies, returning how many were newly frozen
+func (s *ReconciliationServiceImpl) freezeAffected(discrepancies []models.ReconciliationDiscrepancies) (int, error) {
+	frozen := 0
+	for i := range discrepancies {
+		discrepancy := &discrepancies[i]
+
+		var walletID uint
+		switch discrepancy.Kind {
+		case "wallet", "overdraft_limit":
+			walletID = discrepancy.WalletID
+		case "pocket":
+			var pocket models.Pockets
+			if result := config.GetDB().Where("id = ?", discrepancy.WalletID).Limit(1).Find(&pocket); result.Error != nil {
+				return frozen, result.Error
+			}
+			walletID = pocket.WalletID
+		}
+		if walletID == 0 {
+			continue
+		}
+
+		newly, err := s.freezeWallet(walletID)
+		if err != nil {
+			return frozen, err
+		}
+		if newly {
+			frozen++
+		}
+		discrepancy.Frozen = true
+	}
+
+	return frozen, nil
+}
+
+// freezeWallet freezes one wallet, reporting whether it was not frozen already
+func (s *ReconciliationServiceImpl) freezeWallet(walletID uint) (bool, error) {
+	tx := config.GetDB().Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var wallet models.Wallets
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", walletID).First(&wallet); result.Error != nil {
+		tx.Rollback()
+		return false, nil
+	}
+	if wallet.Frozen {
+		tx.Rollback()
+		return false, nil
+	}
+
+	wallet.Frozen = true
+	if err := tx.Model(&wallet).Update("frozen", true).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := eventstore.Append(tx, &wallet, eventstore.Frozen(true)); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// GetRuns retrieves the most recent reconciliation runs
+func (s *ReconciliationServiceImpl) GetRuns(limit int) ([]models.ReconciliationRuns, error) {
+	var runs []models.ReconciliationRuns
+	if result := config.GetDB().Order("id DESC").Limit(limit).Find(&runs); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return runs, nil
+}
+
+// GetRun retrieves a reconciliation run with its discrepancies
+func (s *ReconciliationServiceImpl) GetRun(id uint) (*ReconciliationReport, error) {
+	report := &ReconciliationReport{}
+	if result := config.GetDB().Where("id = ?", id).First(&report.Run); result.Error != nil {
+		return nil, errors.New("reconciliation run not found")
+	}
+	if result := config.GetDB().Where("run_id = ?", id).Order("id ASC").Find(&report.Discrepancies); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return report, nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"errors"
+	"math"
+
+	"wallet/config"
+	"wallet/eventstore"
+	"wallet/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// ReplayServiceImpl implements wallet event stream replay interfaces
+type ReplayServiceImpl struct{}
+
+// NewReplayService creates replay service instance
+func NewReplayService() *ReplayServiceImpl {
+	return &ReplayServiceImpl{}
+}
+
+// ReplayOptions selects what Rebuild replays
+type ReplayOptions struct {
+	WalletID    uint // 0 replays every wallet
+	FromScratch bool // ignore snapshots and rewrite wallet_entries from the whole stream
+	DryRun      bool // only report mismatches
+}
+
+// ReplayReport is the outcome of replaying wallet event streams
+type ReplayReport struct {
+	Wallets    int              `json:"wallets"`
+	Events     int              `json:"events"` // events applied
+	Fixed      int              `json:"fixed"`  // wallets whose stored state was corrected
+	Mismatches []ReplayMismatch `json:"mismatches"`
+	Failures   []ReplayFailure  `json:"failures"`
+}
+
+// ReplayMismatch is a stored wallet field that differs from its replayed value
+type ReplayMismatch struct {
+	WalletID uint        `json:"wallet_id"`
+	UserID   int         `json:"user_id"`
+	Currency string      `json:"currency"`
+	Field    string      `json:"field"`
+	Stored   interface{} `json:"stored"`
+	Replayed interface{} `json:"replayed"`
+}
+
+// ReplayFailure is a wallet whose stream could not be replayed
+type ReplayFailure struct {
+	WalletID uint   `json:"wallet_id"`
+	Error    string `json:"error"`
+}
+
+// Rebuild replays wallet event streams and writes the result back to the Wallets
+// read model: balance, overdraft limit and frozen flag. With FromScratch each
+// stream is replayed from its first event and the wallet's entries are rewritten,
+// otherwise replay starts from the latest snapshot. A fresh snapshot is saved
+// after every replay.
+func (s *ReplayServiceImpl) Rebuild(opts ReplayOptions) (*ReplayReport, error) {
+	query := config.GetDB().Model(&models.Wallets{}).Order("id ASC")
+	if opts.WalletID != 0 {
+		query = query.Where("id = ?", opts.WalletID)
+	}
+	var ids []uint
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	if opts.WalletID != 0 && len(ids) == 0 {
+		return nil, errors.New("wallet not found")
+	}
+
+	report := &ReplayReport{Mismatches: []ReplayMismatch{}, Failures: []ReplayFailure{}}
+	for _, id := range ids {
+		mismatches, applied, err := s.rebuildWallet(id, opts)
+		report.Wallets++
+		report.Events += applied
+		if err != nil {
+			report.Failures = append(report.Failures, ReplayFailure{WalletID: id, Error: err.Error()})
+			continue
+		}
+		report.Mismatches = append(report.Mismatches, mismatches...)
+		if len(mismatches) > 0 && !opts.DryRun {
+			report.Fixed++
+		}
+	}
+
+	return report, nil
+}
+
+// rebuildWallet replays one wallet under its row lock, so no movement is appended
+// meanwhile, and returns the mismatches found and the number of events applied
+func (s *ReplayServiceImpl) rebuildWallet(walletID uint, opts ReplayOptions) ([]ReplayMismatch, int, error) {
+	tx := config.GetDB().Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var wallet models.Wallets
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", walletID).First(&wallet); result.Error != nil {
+		tx.Rollback()
+		return nil, 0, errors.New("wallet not found")
+	}
+
+	var aggregate *eventstore.Wallet
+	var applied int
+	var err error
+	if opts.FromScratch && !opts.DryRun {
+		aggregate, applied, err = eventstore.Rebuild(tx, walletID)
+	} else {
+		aggregate, applied, err = eventstore.Load(tx, walletID, !opts.FromScratch)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, applied, err
+	}
+
+	mismatches := compareWallet(&wallet, aggregate)
+	if opts.DryRun {
+		tx.Rollback()
+		return mismatches, applied, nil
+	}
+
+	if len(mismatches) > 0 {
+		if err := tx.Model(&wallet).UpdateColumns(map[string]interface{}{
+			"balance":         aggregate.Balance,
+			"overdraft_limit": aggregate.OverdraftLimit,
+			"frozen":          aggregate.Frozen,
+			"version":         aggregate.Version,
+		}).Error; err != nil {
+			tx.Rollback()
+			return nil, applied, err
+		}
+	}
+
+	if err := eventstore.SaveSnapshot(tx, aggregate); err != nil {
+		tx.Rollback()
+		return nil, applied, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, applied, err
+	}
+
+	return mismatches, applied, nil
+}
+
+// compareWallet lists the fields of the stored wallet that differ from the replayed aggregate
+func compareWallet(wallet *models.Wallets, aggregate *eventstore.Wallet) []ReplayMismatch {
+	var mismatches []ReplayMismatch
+	add := func(field string, stored, replayed interface{}) {
+		mismatches = append(mismatches, ReplayMismatch{
+			WalletID: wallet.ID,
+			UserID:   wallet.UserID,
+			Currency: wallet.Currency,
+			Field:    field,
+			Stored:   stored,
+			Replayed: replayed,
+		})
+	}
+
+	if math.Abs(wallet.Balance-aggregate.Balance) >= 0.005 {
+		add("balance", wallet.Balance, aggregate.Balance)
+	}
+	if math.Abs(wallet.OverdraftLimit-aggregate.OverdraftLimit) >= 0.005 {
+		add("overdraft_limit", wallet.OverdraftLimit, aggregate.OverdraftLimit)
+	}
+	if wallet.Frozen != aggregate.Frozen {
+		add("frozen", wallet.Frozen, aggregate.Frozen)
+	}
+	if wallet.Version != aggregate.Version {
+		add("version", wallet.Version, aggregate.Version)
+	}
+
+	return mismatches
+}
@@ -0,0 +1,679 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"wallet/config"
+	"wallet/events"
+	"wallet/eventstore"
+	"wallet/metrics"
+	"wallet/models"
+	"wallet/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SharedWalletServiceImpl implements shared wallet service interfaces
+type SharedWalletServiceImpl struct {
+	ctx context.Context
+}
+
+// NewSharedWalletService creates shared wallet service instance
+func NewSharedWalletService() *SharedWalletServiceImpl {
+	return &SharedWalletServiceImpl{}
+}
+
+// WithContext returns a shared wallet service whose calls are traced as part of the
+// request or job in ctx
+func (s *SharedWalletServiceImpl) WithContext(ctx context.Context) *SharedWalletServiceImpl {
+	return &SharedWalletServiceImpl{ctx: ctx}
+}
+
+// CreateSharedWallet creates a shared wallet owned by ownerID
+func (s *SharedWalletServiceImpl) CreateSharedWallet(ownerID int, name string, approvalThreshold float64, requiredApprovals int) (*models.SharedWallets, error) {
+	var owner models.Users
+	if result := config.GetDB().Where("id = ?", ownerID).First(&owner); result.Error != nil {
+		return nil, errors.New("user not found")
+	}
+
+	tx := config.GetDB().Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	wallet := &models.SharedWallets{
+		Name:              name,
+		Currency:          defaultCurrency(),
+		ApprovalThreshold: approvalThreshold,
+		RequiredApprovals: requiredApprovals,
+	}
+	if err := tx.Create(wallet).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	member := models.SharedWalletMembers{
+		SharedWalletID: wallet.ID,
+		UserID:         ownerID,
+		Role:           "owner",
+	}
+	if err := tx.Create(&member).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	wallet.Members = []models.SharedWalletMembers{member}
+	return wallet, nil
+}
+
+// GetSharedWallet retrieves a shared wallet with its members, visible to any member
+func (s *SharedWalletServiceImpl) GetSharedWallet(walletID uint, userID int) (*models.SharedWallets, error) {
+	if _, err := s.getMember(config.GetDB(), walletID, userID); err != nil {
+		return nil, err
+	}
+
+	var wallet models.SharedWallets
+	if result := config.GetDB().Preload("Members").Where("id = ?", walletID).First(&wallet); result.Error != nil {
+		return nil, errors.New("shared wallet not found")
+	}
+
+	return &wallet, nil
+}
+
+// GetUserSharedWallets retrieves the shared wallets a user is a member of
+func (s *SharedWalletServiceImpl) GetUserSharedWallets(userID int) ([]models.SharedWallets, error) {
+	var wallets []models.SharedWallets
+	result := config.GetDB().Preload("Members").
+		Where("id IN (?)", config.GetDB().Model(&models.SharedWalletMembers{}).Select("shared_wallet_id").Where("user_id = ?", userID)).
+		Order("id ASC").Find(&wallets)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return wallets, nil
+}
+
+// SetMember adds a member or updates an existing member's role and spending limit; owners only.
+// The shared wallet row is locked first so concurrent changes cannot demote the last
+// owner, or leave fewer approvers than the approval rule needs.
+func (s *SharedWalletServiceImpl) SetMember(walletID uint, actorID, memberID int, role string, spendingLimit float64) (*models.SharedWalletMembers, error) {
+	var user models.Users
+	if result := config.GetDB().Where("id = ?", memberID).First(&user); result.Error != nil {
+		return nil, errors.New("user not found")
+	}
+
+	tx := config.GetDB().Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var wallet models.SharedWallets
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", walletID).First(&wallet); result.Error != nil {
+		tx.Rollback()
+		return nil, errors.New("shared wallet not found")
+	}
+
+	actor, err := s.getMember(tx, walletID, actorID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if actor.Role != "owner" {
+		tx.Rollback()
+		return nil, errors.New("permission denied")
+	}
+
+	var member models.SharedWalletMembers
+	result := tx.Where("shared_wallet_id = ? AND user_id = ?", walletID, memberID).First(&member)
+	if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		tx.Rollback()
+		return nil, result.Error
+	}
+
+	if member.ID != 0 && member.Role == "owner" && role != "owner" {
+		var owners int64
+		if err := tx.Model(&models.SharedWalletMembers{}).Where("shared_wallet_id = ? AND role = ?", walletID, "owner").Count(&owners).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if owners <= 1 {
+			tx.Rollback()
+			return nil, errors.New("last owner cannot be demoted")
+		}
+	}
+	if member.ID != 0 && member.Role != "viewer" && role == "viewer" {
+		approvers, err := eligibleApprovers(tx, walletID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if approvers-1 < wallet.RequiredApprovals {
+			tx.Rollback()
+			return nil, errors.New("not enough approvers")
+		}
+	}
+
+	member.SharedWalletID = walletID
+	member.UserID = memberID
+	member.Role = role
+	member.SpendingLimit = spendingLimit
+	if err := tx.Save(&member).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+// SetApprovalRule updates the approval threshold and number of required approvals; owners only.
+// The rule may not require more approvals than there are eligible approvers.
+func (s *SharedWalletServiceImpl) SetApprovalRule(walletID uint, actorID int, approvalThreshold float64, requiredApprovals int) (*models.SharedWallets, error) {
+	tx := config.GetDB().Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var wallet models.SharedWallets
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", walletID).First(&wallet); result.Error != nil {
+		tx.Rollback()
+		return nil, errors.New("shared wallet not found")
+	}
+
+	actor, err := s.getMember(tx, walletID, actorID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if actor.Role != "owner" {
+		tx.Rollback()
+		return nil, errors.New("permission denied")
+	}
+
+	approvers, err := eligibleApprovers(tx, walletID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if requiredApprovals > approvers {
+		tx.Rollback()
+		return nil, errors.New("not enough approvers")
+	}
+
+	wallet.ApprovalThreshold = approvalThreshold
+	wallet.RequiredApprovals = requiredApprovals
+	if err := tx.Save(&wallet).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
+
+// eligibleApprovers returns how many members can approve any one operation: owners
+// and spenders may approve, but not the operation they requested themselves
+func eligibleApprovers(tx *gorm.DB, walletID uint) (int, error) {
+	var approvers int64
+	if err := tx.Model(&models.SharedWalletMembers{}).Where("shared_wallet_id = ? AND role <> ?", walletID, "viewer").Count(&approvers).Error; err != nil {
+		return 0, err
+	}
+	return int(approvers) - 1, nil
+}
+
+// Deposit moves funds from a member's main balance into the shared wallet
+func (s *SharedWalletServiceImpl) Deposit(walletID uint, userID int, amount float64, description string) (balance float64, err error) {
+	ctx, span := tracing.Start(s.ctx, "SharedWalletService.Deposit", attribute.Int64("shared_wallet_id", int64(walletID)), attribute.Int("user_id", userID), attribute.Float64("amount", amount))
+	defer func() { tracing.End(span, err) }()
+
+	tx := config.GetDB().WithContext(ctx).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	member, err := s.getMember(tx, walletID, userID)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if member.Role == "viewer" {
+		tx.Rollback()
+		return 0, errors.New("permission denied")
+	}
+
+	var sharedWallet models.SharedWallets
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", walletID).First(&sharedWallet); result.Error != nil {
+		tx.Rollback()
+		return 0, errors.New("shared wallet not found")
+	}
+
+	var wallet models.Wallets
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND currency = ?", userID, sharedWallet.Currency).First(&wallet); result.Error != nil {
+		tx.Rollback()
+		return 0, errors.New("wallet not found")
+	}
+	if err := checkNotFrozen(&wallet); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if wallet.Balance < amount {
+		tx.Rollback()
+		metrics.InsufficientBalance("shared_wallet_deposit")
+		return 0, errors.New("insufficient balance")
+	}
+
+	wallet.Balance -= amount
+	sharedWallet.Balance += amount
+
+	if err := tx.Save(&wallet).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Save(&sharedWallet).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	transaction := models.Transaction{
+		Type:           "shared_deposit",
+		FromUserID:     userID,
+		SharedWalletID: walletID,
+		Amount:         amount,
+		Currency:       sharedWallet.Currency,
+		Description:    description,
+		Status:         "completed",
+	}
+
+	if err := tx.Create(&transaction).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := eventstore.Append(tx, &wallet, eventstore.Debited(&wallet, &transaction)); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := events.Record(tx, events.SharedWalletDeposited, []string{events.WalletKey(wallet.ID), events.SharedWalletKey(walletID)}, events.SharedWalletMovedData{
+		TransactionID:  transaction.ID,
+		SharedWalletID: walletID,
+		Type:           "deposit",
+		UserID:         userID,
+		WalletID:       wallet.ID,
+		Currency:       sharedWallet.Currency,
+		Amount:         amount,
+		Balance:        wallet.Balance,
+		SharedBalance:  sharedWallet.Balance,
+	}); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+
+	config.LoggerFromContext(ctx).Info("shared wallet deposit completed", "shared_wallet_id", walletID, "user_id", userID,
+		"amount", amount, "balance", sharedWallet.Balance)
+	return sharedWallet.Balance, nil
+}
+
+// RequestWithdraw withdraws from the shared wallet, or queues the withdrawal when it needs approvals
+func (s *SharedWalletServiceImpl) RequestWithdraw(walletID uint, userID int, amount float64, description string) (op *models.PendingOperations, err error) {
+	ctx, span := tracing.Start(s.ctx, "SharedWalletService.RequestWithdraw", attribute.Int64("shared_wallet_id", int64(walletID)), attribute.Int("user_id", userID), attribute.Float64("amount", amount))
+	defer func() { tracing.End(span, err) }()
+
+	return s.requestSpend(ctx, walletID, userID, "withdraw", 0, amount, description)
+}
+
+// RequestTransfer transfers from the shared wallet to a user, or queues the transfer when it needs approvals
+func (s *SharedWalletServiceImpl) RequestTransfer(walletID uint, userID, toUserID int, amount float64, description string) (op *models.PendingOperations, err error) {
+	ctx, span := tracing.Start(s.ctx, "SharedWalletService.RequestTransfer", attribute.Int64("shared_wallet_id", int64(walletID)), attribute.Int("user_id", userID), attribute.Int("to_user_id", toUserID), attribute.Float64("amount", amount))
+	defer func() { tracing.End(span, err) }()
+
+	return s.requestSpend(ctx, walletID, userID, "transfer", toUserID, amount, description)
+}
+
+// requestSpend validates a spend against the member's role and limit, then either
+// executes it right away or leaves it pending when the approval rule applies
+func (s *SharedWalletServiceImpl) requestSpend(ctx context.Context, walletID uint, userID int, opType string, toUserID int, amount float64, description string) (*models.PendingOperations, error) {
+	tx := config.GetDB().WithContext(ctx).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	member, err := s.getMember(tx, walletID, userID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if member.Role == "viewer" {
+		tx.Rollback()
+		return nil, errors.New("permission denied")
+	}
+	if member.SpendingLimit > 0 && amount > member.SpendingLimit {
+		tx.Rollback()
+		return nil, errors.New("spending limit exceeded")
+	}
+
+	var sharedWallet models.SharedWallets
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", walletID).First(&sharedWallet); result.Error != nil {
+		tx.Rollback()
+		return nil, errors.New("shared wallet not found")
+	}
+
+	if sharedWallet.Balance < amount {
+		tx.Rollback()
+		metrics.InsufficientBalance("shared_wallet_" + opType)
+		return nil, errors.New("insufficient balance")
+	}
+
+	if opType == "transfer" {
+		var count int64
+		if err := tx.Model(&models.Wallets{}).Where("user_id = ?", toUserID).Count(&count).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if count == 0 {
+			tx.Rollback()
+			return nil, errors.New("recipient wallet not found")
+		}
+	}
+
+	op := &models.PendingOperations{
+		SharedWalletID: walletID,
+		Type:           opType,
+		RequestedBy:    userID,
+		ToUserID:       toUserID,
+		Amount:         amount,
+		Description:    description,
+		Status:         "pending",
+	}
+
+	// Created before it executes, so the transaction and events refer to its ID
+	if err := tx.Create(op).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	needsApproval := sharedWallet.RequiredApprovals > 0 && amount > sharedWallet.ApprovalThreshold
+	if !needsApproval {
+		if err := s.execute(tx, &sharedWallet, op); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := tx.Save(op).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	config.LoggerFromContext(ctx).Info("shared wallet "+opType+" requested", "shared_wallet_id", walletID, "user_id", userID,
+		"operation_id", op.ID, "amount", amount, "status", op.Status)
+	return op, nil
+}
+
+// GetOperations retrieves a shared wallet's operations, optionally filtered by status
+func (s *SharedWalletServiceImpl) GetOperations(walletID uint, userID int, status string) ([]models.PendingOperations, error) {
+	if _, err := s.getMember(config.GetDB(), walletID, userID); err != nil {
+		return nil, err
+	}
+
+	query := config.GetDB().Preload("Approvals").Where("shared_wallet_id = ?", walletID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var ops []models.PendingOperations
+	if result := query.Order("id DESC").Find(&ops); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return ops, nil
+}
+
+// ApproveOperation records a member's approval and executes the operation once enough approvals are in.
+// The requester cannot approve their own operation.
+func (s *SharedWalletServiceImpl) ApproveOperation(walletID, opID uint, userID int) (approved *models.PendingOperations, err error) {
+	ctx, span := tracing.Start(s.ctx, "SharedWalletService.ApproveOperation", attribute.Int64("shared_wallet_id", int64(walletID)), attribute.Int64("operation_id", int64(opID)), attribute.Int("user_id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	tx := config.GetDB().WithContext(ctx).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	member, err := s.getMember(tx, walletID, userID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if member.Role == "viewer" {
+		tx.Rollback()
+		return nil, errors.New("permission denied")
+	}
+
+	var sharedWallet models.SharedWallets
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", walletID).First(&sharedWallet); result.Error != nil {
+		tx.Rollback()
+		return nil, errors.New("shared wallet not found")
+	}
+
+	op, err := s.lockPendingOperation(tx, walletID, opID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if op.RequestedBy == userID {
+		tx.Rollback()
+		return nil, errors.New("cannot approve own operation")
+	}
+
+	var count int64
+	if err := tx.Model(&models.OperationApprovals{}).Where("operation_id = ? AND user_id = ?", op.ID, userID).Count(&count).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if count > 0 {
+		tx.Rollback()
+		return nil, errors.New("already approved")
+	}
+
+	if err := tx.Create(&models.OperationApprovals{OperationID: op.ID, UserID: userID}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Model(&models.OperationApprovals{}).Where("operation_id = ?", op.ID).Count(&count).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if int(count) >= sharedWallet.RequiredApprovals {
+		if err := s.execute(tx, &sharedWallet, op); err != nil {
+			// The approval stands, but the operation can no longer go through
+			if err.Error() != "insufficient balance" && err.Error() != "recipient wallet not found" {
+				tx.Rollback()
+				return nil, err
+			}
+			op.Status = "failed"
+		}
+	}
+
+	if err := tx.Save(op).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	config.LoggerFromContext(ctx).Info("shared wallet operation approved", "shared_wallet_id", walletID, "user_id", userID,
+		"operation_id", op.ID, "status", op.Status)
+	return op, nil
+}
+
+// RejectOperation cancels a pending operation; owners and the requester only
+func (s *SharedWalletServiceImpl) RejectOperation(walletID, opID uint, userID int) (*models.PendingOperations, error) {
+	tx := config.GetDB().Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	member, err := s.getMember(tx, walletID, userID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	op, err := s.lockPendingOperation(tx, walletID, opID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if member.Role != "owner" && op.RequestedBy != userID {
+		tx.Rollback()
+		return nil, errors.New("permission denied")
+	}
+
+	op.Status = "rejected"
+	if err := tx.Save(op).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// getMember loads a user's membership of a shared wallet
+func (s *SharedWalletServiceImpl) getMember(db *gorm.DB, walletID uint, userID int) (*models.SharedWalletMembers, error) {
+	var member models.SharedWalletMembers
+	if result := db.Where("shared_wallet_id = ? AND user_id = ?", walletID, userID).First(&member); result.Error != nil {
+		return nil, errors.New("not a member")
+	}
+
+	return &member, nil
+}
+
+// lockPendingOperation loads a pending operation of the shared wallet for update
+func (s *SharedWalletServiceImpl) lockPendingOperation(tx *gorm.DB, walletID, opID uint) (*models.PendingOperations, error) {
+	var op models.PendingOperations
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND shared_wallet_id = ?", opID, walletID).First(&op); result.Error != nil {
+		return nil, errors.New("operation not found")
+	}
+	if op.Status != "pending" {
+		return nil, errors.New("operation not pending")
+	}
+
+	return &op, nil
+}
+
+// execute applies a withdrawal or transfer to the locked shared wallet and records it
+func (s *SharedWalletServiceImpl) execute(tx *gorm.DB, sharedWallet *models.SharedWallets, op *models.PendingOperations) error {
+	if sharedWallet.Balance < op.Amount {
+		metrics.InsufficientBalance("shared_wallet_" + op.Type)
+		return errors.New("insufficient balance")
+	}
+
+	transaction := models.Transaction{
+		Type:           "shared_" + op.Type,
+		SharedWalletID: sharedWallet.ID,
+		Amount:         op.Amount,
+		Currency:       sharedWallet.Currency,
+		Description:    op.Description,
+		Status:         "completed",
+	}
+
+	data := events.SharedWalletMovedData{
+		SharedWalletID: sharedWallet.ID,
+		OperationID:    op.ID,
+		Type:           op.Type,
+		Currency:       sharedWallet.Currency,
+		Amount:         op.Amount,
+	}
+	keys := []string{events.SharedWalletKey(sharedWallet.ID)}
+
+	var toWallet *models.Wallets
+	if op.Type == "transfer" {
+		var err error
+		toWallet, err = lockOrCreateWallet(tx, op.ToUserID, sharedWallet.Currency)
+		if err != nil {
+			if err.Error() == "wallet not found" {
+				return errors.New("recipient wallet not found")
+			}
+			return err
+		}
+		toWallet.Balance += op.Amount
+		if err := tx.Save(toWallet).Error; err != nil {
+			return err
+		}
+		transaction.ToUserID = op.ToUserID
+		data.UserID = op.ToUserID
+		data.WalletID = toWallet.ID
+		data.Balance = toWallet.Balance
+		keys = append(keys, events.WalletKey(toWallet.ID))
+	}
+
+	sharedWallet.Balance -= op.Amount
+	if err := tx.Save(sharedWallet).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Create(&transaction).Error; err != nil {
+		return err
+	}
+
+	if toWallet != nil {
+		if err := eventstore.Append(tx, toWallet, eventstore.Credited(toWallet, &transaction)); err != nil {
+			return err
+		}
+	}
+
+	data.TransactionID = transaction.ID
+	data.SharedBalance = sharedWallet.Balance
+	if err := events.Record(tx, events.SharedWalletSpent, keys, data); err != nil {
+		return err
+	}
+
+	op.Status = "executed"
+	op.TransactionID = transaction.ID
+	return nil
+}
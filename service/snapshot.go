@@ -0,0 +1,162 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"wallet/config"
+	"wallet/models"
+
+	"gorm.io/gorm"
+)
+
+// SnapshotServiceImpl implements balance snapshot and historical balance interfaces
+type SnapshotServiceImpl struct{}
+
+// NewSnapshotService creates snapshot service instance
+func NewSnapshotService() *SnapshotServiceImpl {
+	return &SnapshotServiceImpl{}
+}
+
+// HistoricalBalance is a wallet's main balance at a point in time
+type HistoricalBalance struct {
+	UserID     int        `json:"user_id"`
+	Currency   string     `json:"currency"`
+	At         time.Time  `json:"at"`
+	Balance    float64    `json:"balance"`
+	SnapshotAt *time.Time `json:"snapshot_at,omitempty"` // snapshot the balance was derived from
+}
+
+// BalanceAt returns a user's main balance in currency including every transaction
+// created at or before at. It starts from the nearest snapshot so only the rows
+// between the snapshot and at are summed.
+func (s *SnapshotServiceImpl) BalanceAt(userID int, currency string, at time.Time) (*HistoricalBalance, error) {
+	currency = normalizeCurrency(currency)
+
+	// Read the balance and the movements after it in one snapshot, as Generate does
+	tx := config.GetDB().Begin()
+	defer tx.Rollback()
+
+	var wallet models.Wallets
+	if result := tx.Where("user_id = ? AND currency = ?", userID, currency).First(&wallet); result.Error != nil {
+		return nil, errors.New("wallet not found")
+	}
+
+	balance, snapshot, err := balanceFromSnapshots(tx, &wallet, at)
+	if err != nil {
+		return nil, err
+	}
+
+	historical := &HistoricalBalance{
+		UserID:   userID,
+		Currency: currency,
+		At:       at,
+		Balance:  balance,
+	}
+	if snapshot != nil {
+		historical.SnapshotAt = &snapshot.TakenAt
+	}
+
+	return historical, nil
+}
+
+// TakeSnapshots records every wallet's balance at the given time. Wallets that
+// already have a snapshot at that time are skipped, so repeating a run is safe.
+// It returns the number of snapshots taken.
+func (s *SnapshotServiceImpl) TakeSnapshots(at time.Time) (int, error) {
+	var walletIDs []uint
+	if err := config.GetDB().Model(&models.Wallets{}).Order("id ASC").Pluck("id", &walletIDs).Error; err != nil {
+		return 0, err
+	}
+
+	taken := 0
+	for _, walletID := range walletIDs {
+		ok, err := s.snapshotWallet(walletID, at)
+		if err != nil {
+			return taken, err
+		}
+		if ok {
+			taken++
+		}
+	}
+
+	return taken, nil
+}
+
+// snapshotWallet records one wallet's balance at at, reporting whether a snapshot was
+// taken. The wallet row and the movements the balance is derived with are read in one
+// transaction, so a movement committing in between cannot leave the snapshot, and
+// every balance later rolled forward from it, off by its amount.
+func (s *SnapshotServiceImpl) snapshotWallet(walletID uint, at time.Time) (bool, error) {
+	tx := config.GetDB().Begin()
+	defer tx.Rollback()
+
+	var wallet models.Wallets
+	if result := tx.Where("id = ?", walletID).First(&wallet); result.Error != nil {
+		return false, result.Error
+	}
+
+	var count int64
+	if err := tx.Model(&models.BalanceSnapshots{}).Where("wallet_id = ? AND taken_at = ?", wallet.ID, at).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	balance, _, err := balanceFromSnapshots(tx, &wallet, at)
+	if err != nil {
+		return false, err
+	}
+
+	snapshot := models.BalanceSnapshots{
+		WalletID: wallet.ID,
+		UserID:   wallet.UserID,
+		Currency: wallet.Currency,
+		TakenAt:  at,
+		Balance:  balance,
+	}
+	if err := tx.Create(&snapshot).Error; err != nil {
+		return false, err
+	}
+	return true, tx.Commit().Error
+}
+
+// balanceFromSnapshots derives wallet's balance at at by rolling the latest snapshot
+// before it forward or, failing that, the earliest snapshot after it backward. Without
+// any snapshot it reverts every later movement from the current balance.
+func balanceFromSnapshots(db *gorm.DB, wallet *models.Wallets, at time.Time) (float64, *models.BalanceSnapshots, error) {
+	var before models.BalanceSnapshots
+	result := db.Where("user_id = ? AND currency = ? AND taken_at <= ?", wallet.UserID, wallet.Currency, at).
+		Order("taken_at DESC").Limit(1).Find(&before)
+	if result.Error != nil {
+		return 0, nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		net, err := netMovement(db, wallet.UserID, wallet.Currency, before.TakenAt, at)
+		if err != nil {
+			return 0, nil, err
+		}
+		return roundAmount(before.Balance + net), &before, nil
+	}
+
+	var after models.BalanceSnapshots
+	result = db.Where("user_id = ? AND currency = ? AND taken_at > ?", wallet.UserID, wallet.Currency, at).
+		Order("taken_at ASC").Limit(1).Find(&after)
+	if result.Error != nil {
+		return 0, nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		net, err := netMovement(db, wallet.UserID, wallet.Currency, at, after.TakenAt)
+		if err != nil {
+			return 0, nil, err
+		}
+		return roundAmount(after.Balance - net), &after, nil
+	}
+
+	balance, err := balanceAt(db, wallet.UserID, wallet.Currency, wallet.Balance, at)
+	if err != nil {
+		return 0, nil, err
+	}
+	return balance, nil, nil
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wallet/config"
+	"wallet/models"
+	"wallet/statement"
+)
+
+// statementStaleAfter is how long a statement may stay processing before it is requeued
+const statementStaleAfter = 15 * time.Minute
+
+// StatementServiceImpl implements statement service interfaces
+type StatementServiceImpl struct {
+	ctx context.Context
+}
+
+// NewStatementService creates statement service instance
+func NewStatementService() *StatementServiceImpl {
+	return &StatementServiceImpl{}
+}
+
+// WithContext returns a statement service that logs as part of the request in ctx
+func (s *StatementServiceImpl) WithContext(ctx context.Context) *StatementServiceImpl {
+	return &StatementServiceImpl{ctx: ctx}
+}
+
+// Generate builds the statement of a user's wallet in currency for [from, to)
+func (s *StatementServiceImpl) Generate(userID int, currency string, from, to time.Time) (*statement.Statement, error) {
+	currency = normalizeCurrency(currency)
+	if !from.Before(to) {
+		return nil, errors.New("invalid period")
+	}
+
+	// Read the balance and the rows after it in one snapshot so concurrent
+	// movements cannot skew the opening balance. Statements are read from a
+	// replica when one is configured.
+	tx := config.GetReadDB().Begin()
+	defer tx.Rollback()
+
+	var wallet models.Wallets
+	if result := tx.Where("user_id = ? AND currency = ?", userID, currency).First(&wallet); result.Error != nil {
+		return nil, errors.New("wallet not found")
+	}
+
+	// balanceAt reverts rows strictly after its time, so step back to include from itself
+	opening, err := balanceAt(tx, userID, currency, wallet.Balance, from.Add(-time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []models.Transaction
+	if result := tx.Where("(from_user_id = ? OR to_user_id = ?) AND currency = ?", userID, userID, currency).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Order("created_at ASC").Order("id ASC").
+		Find(&transactions); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return statement.New(userID, currency, from, to, opening, transactions), nil
+}
+
+// Request returns the statement directly when the period is small. Periods with more
+// transactions than the configured threshold are queued for the statements worker
+// instead; the returned job then identifies the download link.
+func (s *StatementServiceImpl) Request(userID int, currency string, from, to time.Time, format string) (*statement.Statement, *models.StatementJobs, error) {
+	currency = normalizeCurrency(currency)
+	if format != statement.FormatCSV && format != statement.FormatJSON && format != statement.FormatPDF {
+		return nil, nil, statement.ErrUnsupportedFormat
+	}
+	if !from.Before(to) {
+		return nil, nil, errors.New("invalid period")
+	}
+
+	var count int64
+	if err := config.GetReadDB().Model(&models.Transaction{}).
+		Where("(from_user_id = ? OR to_user_id = ?) AND currency = ?", userID, userID, currency).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Count(&count).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if count <= config.GetConf().Statement.AsyncThreshold {
+		st, err := s.Generate(userID, currency, from, to)
+		return st, nil, err
+	}
+
+	var wallet models.Wallets
+	if result := config.GetReadDB().Where("user_id = ? AND currency = ?", userID, currency).First(&wallet); result.Error != nil {
+		return nil, nil, errors.New("wallet not found")
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	job := &models.StatementJobs{
+		Token:      token,
+		UserID:     userID,
+		Currency:   currency,
+		PeriodFrom: from,
+		PeriodTo:   to,
+		Format:     format,
+		Status:     "pending",
+	}
+	if err := config.GetDB().Create(job).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return nil, job, nil
+}
+
+// GetJob retrieves a background statement by its download token
+func (s *StatementServiceImpl) GetJob(token string) (*models.StatementJobs, error) {
+	var job models.StatementJobs
+	if result := config.GetDB().Where("token = ?", token).First(&job); result.Error != nil {
+		return nil, errors.New("statement not found")
+	}
+
+	return &job, nil
+}
+
+// OpenDownload returns the file of a ready background statement
+func (s *StatementServiceImpl) OpenDownload(token string) (*models.StatementJobs, error) {
+	job, err := s.GetJob(token)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case job.Status == "expired" || job.Status == "ready" && job.ExpiresAt != nil && time.Now().After(*job.ExpiresAt):
+		return nil, errors.New("statement expired")
+	case job.Status != "ready":
+		return nil, errors.New("statement not ready")
+	}
+
+	return job, nil
+}
+
+// ProcessPending generates queued statements, including those left over by a
+// restart, and removes the files of expired download links. It stops between
+// statements once ctx is done, leaving the rest pending for the next run.
+func (s *StatementServiceImpl) ProcessPending(ctx context.Context) error {
+	// Requeue jobs whose generation was interrupted, e.g. by a restart
+	if err := config.GetDB().Model(&models.StatementJobs{}).
+		Where("status = ? AND updated_at < ?", "processing", time.Now().Add(-statementStaleAfter)).
+		Update("status", "pending").Error; err != nil {
+		return err
+	}
+
+	var ids []uint
+	if err := config.GetDB().Model(&models.StatementJobs{}).Where("status = ?", "pending").Order("id ASC").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := s.process(id); err != nil {
+			config.LoggerFromContext(s.ctx).Error("statement job failed", "job_id", id, "error", err.Error())
+		}
+	}
+
+	var expired []models.StatementJobs
+	if result := config.GetDB().Where("status = ? AND expires_at < ?", "ready", time.Now()).Find(&expired); result.Error != nil {
+		return result.Error
+	}
+	for _, job := range expired {
+		if err := os.Remove(job.FilePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := config.GetDB().Model(&job).Updates(map[string]interface{}{"status": "expired", "file_path": ""}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// process generates one queued statement. Jobs are claimed by switching them from
+// pending to processing, so each is generated once even with several workers.
+func (s *StatementServiceImpl) process(id uint) error {
+	claim := config.GetDB().Model(&models.StatementJobs{}).Where("id = ? AND status = ?", id, "pending").Update("status", "processing")
+	if claim.Error != nil {
+		return claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return nil
+	}
+
+	var job models.StatementJobs
+	if result := config.GetDB().Where("id = ?", id).First(&job); result.Error != nil {
+		return result.Error
+	}
+
+	path, err := s.writeFile(&job)
+	if err != nil {
+		config.GetDB().Model(&job).Updates(map[string]interface{}{"status": "failed", "error": err.Error()})
+		return err
+	}
+
+	expiresAt := time.Now().Add(config.GetConf().Statement.LinkTTL)
+	return config.GetDB().Model(&job).Updates(map[string]interface{}{
+		"status":     "ready",
+		"file_path":  path,
+		"expires_at": expiresAt,
+	}).Error
+}
+
+// writeFile generates job's statement into the statement directory
+func (s *StatementServiceImpl) writeFile(job *models.StatementJobs) (string, error) {
+	st, err := s.Generate(job.UserID, job.Currency, job.PeriodFrom, job.PeriodTo)
+	if err != nil {
+		return "", err
+	}
+
+	dir := config.GetConf().Statement.Dir
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s.%s", job.Token, job.Format))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err := statement.Write(file, st, job.Format); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	return path, nil
+}
+
+// newToken returns a random, unguessable download token
+func newToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
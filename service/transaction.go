@@ -1,30 +1,369 @@
 package service
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"wallet/config"
 	"wallet/models"
+	"wallet/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
 )
 
 // TransactionServiceImpl implements transaction service interfaces
-type TransactionServiceImpl struct{}
+type TransactionServiceImpl struct {
+	ctx context.Context
+}
 
 // NewTransactionService creates transaction service instance
 func NewTransactionService() *TransactionServiceImpl {
 	return &TransactionServiceImpl{}
 }
 
-// GetUserTransactions retrieves user's transaction history
-func (s *TransactionServiceImpl) GetUserTransactions(userID, page, limit int) ([]models.Transaction, error) {
-	offset := (page - 1) * limit
+// WithContext returns a transaction service whose calls are traced as part of the
+// request or job in ctx
+func (s *TransactionServiceImpl) WithContext(ctx context.Context) *TransactionServiceImpl {
+	return &TransactionServiceImpl{ctx: ctx}
+}
+
+// TransactionFilter narrows a user's transaction history; zero values match everything
+type TransactionFilter struct {
+	Types          []string
+	Status         string
+	Direction      string // in, out
+	CounterpartyID int
+	Currency       string
+	MinAmount      *float64
+	MaxAmount      *float64
+	CreatedFrom    *time.Time // inclusive
+	CreatedTo      *time.Time // exclusive
+}
+
+// PageRequest selects a page either by cursor or, for older clients, by page number
+type PageRequest struct {
+	Cursor string
+	Page   int // offset pagination when > 0 and no cursor is given
+	Limit  int
+}
+
+// TransactionPage is one page of transaction history
+type TransactionPage struct {
+	Items      []models.Transaction `json:"items"`
+	Total      int64                `json:"total"`
+	Limit      int                  `json:"limit"`
+	Page       int                  `json:"page,omitempty"`
+	HasMore    bool                 `json:"has_more"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// MaxPageLimit caps the number of transactions returned per page
+const MaxPageLimit = 100
+
+// GetUserTransactions retrieves user's transaction history, newest first.
+// Cursor pages are keyed on (created_at, id) so rows inserted while a client
+// is paging never shift or repeat entries.
+func (s *TransactionServiceImpl) GetUserTransactions(userID int, filter TransactionFilter, pageReq PageRequest) (page *TransactionPage, err error) {
+	ctx, span := tracing.Start(s.ctx, "TransactionService.GetUserTransactions", attribute.Int("user_id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	if pageReq.Limit < 1 || pageReq.Limit > MaxPageLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", MaxPageLimit)
+	}
+	if pageReq.Page < 0 {
+		return nil, errors.New("page must be positive")
+	}
+
+	// History tolerates replication lag, so it is read from a replica when one is configured
+	query := applyTransactionFilter(config.GetReadDB().WithContext(ctx).Model(&models.Transaction{}), userID, filter)
+
+	page = &TransactionPage{Limit: pageReq.Limit}
+	if err := query.Session(&gorm.Session{}).Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+
+	query = query.Order("created_at DESC").Order("id DESC")
+	switch {
+	case pageReq.Cursor != "":
+		createdAt, id, err := decodeCursor(pageReq.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("created_at < ? OR (created_at = ? AND id < ?)", createdAt, createdAt, id)
+	case pageReq.Page > 0:
+		page.Page = pageReq.Page
+		query = query.Offset((pageReq.Page - 1) * pageReq.Limit)
+	}
+
+	// Fetch one extra row to learn whether another page follows
 	var transactions []models.Transaction
+	if result := query.Limit(pageReq.Limit + 1).Find(&transactions); result.Error != nil {
+		return nil, result.Error
+	}
 
-	result := config.GetDB().Where(
-		"from_user_id = ? OR to_user_id = ?", userID, userID,
-	).Order("created_at DESC").Offset(offset).Limit(limit).Find(&transactions)
+	if len(transactions) > pageReq.Limit {
+		transactions = transactions[:pageReq.Limit]
+		page.HasMore = true
+		last := transactions[len(transactions)-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	page.Items = transactions
 
-	if result.Error != nil {
+	return page, nil
+}
+
+// applyTransactionFilter restricts query to userID's transactions matching filter
+func applyTransactionFilter(query *gorm.DB, userID int, filter TransactionFilter) *gorm.DB {
+	switch filter.Direction {
+	case "in":
+		query = query.Where("to_user_id = ?", userID)
+	case "out":
+		query = query.Where("from_user_id = ?", userID)
+	default:
+		query = query.Where("from_user_id = ? OR to_user_id = ?", userID, userID)
+	}
+
+	if filter.CounterpartyID != 0 {
+		query = query.Where(
+			"(from_user_id = ? AND to_user_id = ?) OR (to_user_id = ? AND from_user_id = ?)",
+			userID, filter.CounterpartyID, userID, filter.CounterpartyID,
+		)
+	}
+	if len(filter.Types) > 0 {
+		query = query.Where("type IN ?", filter.Types)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Currency != "" {
+		query = query.Where("currency = ?", strings.ToUpper(filter.Currency))
+	}
+	if filter.MinAmount != nil {
+		query = query.Where("amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query = query.Where("amount <= ?", *filter.MaxAmount)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+
+	return query
+}
+
+// encodeCursor builds an opaque cursor pointing just past the given row
+func encodeCursor(createdAt time.Time, id uint) string {
+	raw := fmt.Sprintf("%d:%d", createdAt.UnixNano(), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor produced by encodeCursor
+func decodeCursor(cursor string) (time.Time, uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, errors.New("invalid cursor")
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return time.Time{}, 0, errors.New("invalid cursor")
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, errors.New("invalid cursor")
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, 0, errors.New("invalid cursor")
+	}
+
+	return time.Unix(0, nanos), uint(id), nil
+}
+
+// Party identifies one side of a transaction
+type Party struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+// TransactionDetail is a transaction with its state history, related transactions and parties
+type TransactionDetail struct {
+	Transaction   models.Transaction                `json:"transaction"`
+	StatusHistory []models.TransactionStatusHistory `json:"status_history"`
+	Related       []models.Transaction              `json:"related"`
+	FromUser      *Party                            `json:"from_user,omitempty"`
+	ToUser        *Party                            `json:"to_user,omitempty"`
+	Counterparty  *Party                            `json:"counterparty,omitempty"` // the other party as seen by the viewer
+}
+
+// TransactionViewer is the authenticated caller of GetTransactionDetail
+type TransactionViewer struct {
+	IsAdmin  bool
+	ClientID uint // API client authenticated by its key, 0 when none
+	UserID   int  // authenticated user, 0 when none
+}
+
+// GetTransactionDetail retrieves one transaction for viewer. Admins may see any
+// transaction; a user sees those they are a party to or that moved funds of a
+// shared wallet they are a member of; an API client not acting for a user sees
+// those of the users it registered.
+func (s *TransactionServiceImpl) GetTransactionDetail(id uint, viewer TransactionViewer) (*TransactionDetail, error) {
+	db := config.GetDB()
+
+	var transaction models.Transaction
+	if result := db.Where("id = ?", id).First(&transaction); result.Error != nil {
+		return nil, errors.New("transaction not found")
+	}
+
+	allowed, err := canViewTransaction(db, &transaction, viewer)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, errors.New("access denied")
+	}
+
+	detail := &TransactionDetail{Transaction: transaction}
+
+	if detail.StatusHistory, err = transactionStatusHistory(db, &transaction); err != nil {
+		return nil, err
+	}
+	if detail.Related, err = relatedTransactions(db, &transaction); err != nil {
+		return nil, err
+	}
+
+	parties, err := lookupParties(db, transaction.FromUserID, transaction.ToUserID)
+	if err != nil {
+		return nil, err
+	}
+	detail.FromUser = parties[transaction.FromUserID]
+	detail.ToUser = parties[transaction.ToUserID]
+	switch viewer.UserID {
+	case 0:
+	case transaction.FromUserID:
+		detail.Counterparty = detail.ToUser
+	case transaction.ToUserID:
+		detail.Counterparty = detail.FromUser
+	}
+
+	return detail, nil
+}
+
+// canViewTransaction reports whether viewer may see transaction
+func canViewTransaction(db *gorm.DB, transaction *models.Transaction, viewer TransactionViewer) (bool, error) {
+	if viewer.IsAdmin {
+		return true, nil
+	}
+
+	var count int64
+	if viewer.UserID == 0 {
+		if viewer.ClientID == 0 {
+			return false, nil
+		}
+		// The client itself sees the transactions of the users it registered
+		if err := db.Model(&models.Users{}).
+			Where("id IN ? AND client_id = ?", []int{transaction.FromUserID, transaction.ToUserID}, viewer.ClientID).
+			Count(&count).Error; err != nil {
+			return false, err
+		}
+		return count > 0, nil
+	}
+
+	if viewer.UserID == transaction.FromUserID || viewer.UserID == transaction.ToUserID {
+		return true, nil
+	}
+	if transaction.SharedWalletID == 0 {
+		return false, nil
+	}
+
+	if err := db.Model(&models.SharedWalletMembers{}).
+		Where("shared_wallet_id = ? AND user_id = ?", transaction.SharedWalletID, viewer.UserID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// transactionStatusHistory lists the states of transaction, oldest first. Shared wallet
+// spends that went through approval start with the request and its approvals.
+func transactionStatusHistory(db *gorm.DB, transaction *models.Transaction) ([]models.TransactionStatusHistory, error) {
+	history := []models.TransactionStatusHistory{}
+
+	if transaction.SharedWalletID != 0 {
+		var operation models.PendingOperations
+		result := db.Preload("Approvals", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		}).Where("transaction_id = ?", transaction.ID).Limit(1).Find(&operation)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected > 0 {
+			history = append(history, models.TransactionStatusHistory{
+				TransactionID: transaction.ID,
+				Status:        "pending",
+				Note:          fmt.Sprintf("requested by user %d", operation.RequestedBy),
+				CreatedAt:     operation.CreatedAt,
+			})
+			for _, approval := range operation.Approvals {
+				history = append(history, models.TransactionStatusHistory{
+					TransactionID: transaction.ID,
+					Status:        "pending",
+					Note:          fmt.Sprintf("approved by user %d", approval.UserID),
+					CreatedAt:     approval.CreatedAt,
+				})
+			}
+		}
+	}
+
+	var recorded []models.TransactionStatusHistory
+	if result := db.Where("transaction_id = ?", transaction.ID).Order("created_at ASC").Order("id ASC").Find(&recorded); result.Error != nil {
+		return nil, result.Error
+	}
+	// Transactions created before state history was recorded only have their current status
+	if len(recorded) == 0 {
+		recorded = append(recorded, models.TransactionStatusHistory{
+			TransactionID: transaction.ID,
+			Status:        transaction.Status,
+			CreatedAt:     transaction.CreatedAt,
+		})
+	}
+
+	return append(history, recorded...), nil
+}
+
+// relatedTransactions finds the other leg of an exchange; other transactions have none
+func relatedTransactions(db *gorm.DB, transaction *models.Transaction) ([]models.Transaction, error) {
+	related := []models.Transaction{}
+	if transaction.ExchangeID == 0 {
+		return related, nil
+	}
+	if result := db.Where("exchange_id = ? AND id <> ?", transaction.ExchangeID, transaction.ID).Order("id ASC").Find(&related); result.Error != nil {
 		return nil, result.Error
 	}
 
-	return transactions, nil
+	return related, nil
+}
+
+// lookupParties loads the display names of the given users, keyed by ID
+func lookupParties(db *gorm.DB, userIDs ...int) (map[int]*Party, error) {
+	var users []models.Users
+	if result := db.Where("id IN ?", userIDs).Find(&users); result.Error != nil {
+		return nil, result.Error
+	}
+
+	parties := make(map[int]*Party, len(users))
+	for _, user := range users {
+		parties[user.ID] = &Party{ID: user.ID, Username: user.Username}
+	}
+	return parties, nil
 }
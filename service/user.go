@@ -1,32 +1,51 @@
 package service
 
 import (
+	"context"
 	"errors"
 
 	"wallet/config"
+	"wallet/events"
+	"wallet/eventstore"
 	"wallet/models"
+	"wallet/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // UserServiceImpl implements user service interfaces
-type UserServiceImpl struct{}
+type UserServiceImpl struct {
+	ctx context.Context
+}
 
 // NewUserService creates user service instance
 func NewUserService() *UserServiceImpl {
 	return &UserServiceImpl{}
 }
 
-// RegisterUser registers a new user with a wallet
-func (s *UserServiceImpl) RegisterUser(username, email string) (*models.Users, *models.Wallets, error) {
-	tx := config.GetDB().Begin()
+// WithContext returns a user service whose calls are traced as part of the
+// request or job in ctx
+func (s *UserServiceImpl) WithContext(ctx context.Context) *UserServiceImpl {
+	return &UserServiceImpl{ctx: ctx}
+}
+
+// RegisterUser registers a new user with a wallet in the default currency; clientID
+// is the API client registering the user, or 0
+func (s *UserServiceImpl) RegisterUser(username, email string, clientID uint) (user *models.Users, wallet *models.Wallets, err error) {
+	ctx, span := tracing.Start(s.ctx, "UserService.RegisterUser", attribute.Int64("client_id", int64(clientID)))
+	defer func() { tracing.End(span, err) }()
+
+	tx := config.GetDB().WithContext(ctx).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
 		}
 	}()
 
-	user := &models.Users{
+	user = &models.Users{
 		Username: username,
 		Email:    email,
+		ClientID: clientID,
 	}
 
 	if err := tx.Create(user).Error; err != nil {
@@ -35,9 +54,10 @@ func (s *UserServiceImpl) RegisterUser(username, email string) (*models.Users, *
 	}
 
 	// Create wallet
-	wallet := &models.Wallets{
-		UserID:  user.ID, // Use auto-increment ID
-		Balance: 0,
+	wallet = &models.Wallets{
+		UserID:   user.ID, // Use auto-increment ID
+		Currency: defaultCurrency(),
+		Balance:  0,
 	}
 
 	if err := tx.Create(wallet).Error; err != nil {
@@ -45,10 +65,27 @@ func (s *UserServiceImpl) RegisterUser(username, email string) (*models.Users, *
 		return nil, nil, err
 	}
 
+	if err := eventstore.Append(tx, wallet, eventstore.Opened(wallet)); err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	if err := events.Record(tx, events.UserRegistered, []string{events.UserKey(user.ID)}, events.UserRegisteredData{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		WalletID: wallet.ID,
+		Currency: wallet.Currency,
+	}); err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return nil, nil, err
 	}
 
+	config.LoggerFromContext(ctx).Info("user registered", "user_id", user.ID, "wallet_id", wallet.ID, "client_id", clientID)
 	return user, wallet, nil
 }
 
@@ -62,10 +99,10 @@ func (s *UserServiceImpl) GetUserByID(id int) (*models.Users, error) {
 	return &user, nil
 }
 
-// GetAllUsers retrieves all users information
+// GetAllUsers retrieves all users information, from a replica when one is configured
 func (s *UserServiceImpl) GetAllUsers() ([]models.Users, error) {
 	var users []models.Users
-	if result := config.GetDB().Preload("Wallet").Find(&users); result.Error != nil {
+	if result := config.GetReadDB().Preload("Wallet").Find(&users); result.Error != nil {
 		return nil, result.Error
 	}
 
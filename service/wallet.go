@@ -1,33 +1,138 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"wallet/config"
+	"wallet/events"
+	"wallet/eventstore"
+	"wallet/metrics"
 	"wallet/models"
+	"wallet/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm/clause"
 )
 
 // WalletServiceImpl implements wallet service interfaces
-type WalletServiceImpl struct{}
+type WalletServiceImpl struct {
+	ctx context.Context
+}
 
 // NewWalletService creates wallet service instance
 func NewWalletService() *WalletServiceImpl {
 	return &WalletServiceImpl{}
 }
 
-// GetBalance retrieves wallet balance
-func (s *WalletServiceImpl) GetBalance(userID int) (float64, error) {
+// WithContext returns a wallet service whose calls are traced as part of the
+// request or job in ctx
+func (s *WalletServiceImpl) WithContext(ctx context.Context) *WalletServiceImpl {
+	return &WalletServiceImpl{ctx: ctx}
+}
+
+// GetBalance retrieves wallet balance in currency
+func (s *WalletServiceImpl) GetBalance(userID int, currency string) (balance float64, err error) {
+	ctx, span := tracing.Start(s.ctx, "WalletService.GetBalance", attribute.Int("user_id", userID), attribute.String("currency", currency))
+	defer func() { tracing.End(span, err) }()
+
 	var wallet models.Wallets
-	if result := config.GetDB().Where("user_id = ?", userID).First(&wallet); result.Error != nil {
+	if result := config.GetDB().WithContext(ctx).Where("user_id = ? AND currency = ?", userID, normalizeCurrency(currency)).First(&wallet); result.Error != nil {
 		return 0, errors.New("wallet not found")
 	}
 
 	return wallet.Balance, nil
 }
 
-// Deposit adds funds to wallet
-func (s *WalletServiceImpl) Deposit(userID int, amount float64, description string) (float64, error) {
-	tx := config.GetDB().Begin()
+// BalanceSummary describes a wallet's main balance together with its pockets and credit line
+type BalanceSummary struct {
+	Currency        string           `json:"currency"`
+	Balance         float64          `json:"balance"`
+	PocketsBalance  float64          `json:"pockets_balance"`
+	Total           float64          `json:"total"`
+	OverdraftLimit  float64          `json:"overdraft_limit"`
+	CreditUsed      float64          `json:"credit_used"`
+	CreditAvailable float64          `json:"credit_available"`
+	Pockets         []models.Pockets `json:"pockets"`
+}
+
+// GetBalanceSummary retrieves the main balance, the pocket balances and their total in currency
+func (s *WalletServiceImpl) GetBalanceSummary(userID int, currency string) (summary *BalanceSummary, err error) {
+	ctx, span := tracing.Start(s.ctx, "WalletService.GetBalanceSummary", attribute.Int("user_id", userID), attribute.String("currency", currency))
+	defer func() { tracing.End(span, err) }()
+
+	db := config.GetDB().WithContext(ctx)
+	var wallet models.Wallets
+	if result := db.Where("user_id = ? AND currency = ?", userID, normalizeCurrency(currency)).First(&wallet); result.Error != nil {
+		return nil, errors.New("wallet not found")
+	}
+
+	var pockets []models.Pockets
+	if result := db.Where("wallet_id = ?", wallet.ID).Order("id ASC").Find(&pockets); result.Error != nil {
+		return nil, result.Error
+	}
+
+	summary = &BalanceSummary{
+		Currency:       wallet.Currency,
+		Balance:        wallet.Balance,
+		OverdraftLimit: wallet.OverdraftLimit,
+		Pockets:        pockets,
+	}
+	for _, pocket := range pockets {
+		summary.PocketsBalance += pocket.Balance
+	}
+	summary.Total = summary.Balance + summary.PocketsBalance
+	if wallet.Balance < 0 {
+		summary.CreditUsed = -wallet.Balance
+	}
+	if summary.CreditUsed < wallet.OverdraftLimit {
+		summary.CreditAvailable = wallet.OverdraftLimit - summary.CreditUsed
+	}
+
+	return summary, nil
+}
+
+// GetWallets retrieves all currency wallets of a user
+func (s *WalletServiceImpl) GetWallets(userID int) (wallets []models.Wallets, err error) {
+	ctx, span := tracing.Start(s.ctx, "WalletService.GetWallets", attribute.Int("user_id", userID))
+	defer func() { tracing.End(span, err) }()
+
+	if result := config.GetDB().WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&wallets); result.Error != nil {
+		return nil, result.Error
+	}
+	if len(wallets) == 0 {
+		return nil, errors.New("wallet not found")
+	}
+
+	return wallets, nil
+}
+
+// GetEntries retrieves the most recent balance movements of a user's wallet in
+// currency, as projected from its event stream
+func (s *WalletServiceImpl) GetEntries(userID int, currency string, limit int) (entries []models.WalletEntries, err error) {
+	ctx, span := tracing.Start(s.ctx, "WalletService.GetEntries", attribute.Int("user_id", userID), attribute.String("currency", currency))
+	defer func() { tracing.End(span, err) }()
+
+	db := config.GetDB().WithContext(ctx)
+	var wallet models.Wallets
+	if result := db.Where("user_id = ? AND currency = ?", userID, normalizeCurrency(currency)).First(&wallet); result.Error != nil {
+		return nil, errors.New("wallet not found")
+	}
+
+	if result := db.Where("wallet_id = ?", wallet.ID).Order("version DESC").Limit(limit).Find(&entries); result.Error != nil {
+		return nil, result.Error
+	}
+
+	return entries, nil
+}
+
+// SetFrozen freezes or unfreezes a user's wallet in currency
+func (s *WalletServiceImpl) SetFrozen(userID int, currency string, frozen bool) (updated *models.Wallets, err error) {
+	ctx, span := tracing.Start(s.ctx, "WalletService.SetFrozen", attribute.Int("user_id", userID), attribute.String("currency", currency), attribute.Bool("frozen", frozen))
+	defer func() { tracing.End(span, err) }()
+
+	tx := config.GetDB().WithContext(ctx).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -35,13 +140,64 @@ func (s *WalletServiceImpl) Deposit(userID int, amount float64, description stri
 	}()
 
 	var wallet models.Wallets
-	if result := tx.Where("user_id = ?", userID).First(&wallet); result.Error != nil {
+	if result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND currency = ?", userID, normalizeCurrency(currency)).First(&wallet); result.Error != nil {
 		tx.Rollback()
-		return 0, errors.New("wallet not found")
+		return nil, errors.New("wallet not found")
+	}
+
+	wallet.Frozen = frozen
+	if err := tx.Model(&wallet).Update("frozen", frozen).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := eventstore.Append(tx, &wallet, eventstore.Frozen(frozen)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	config.LoggerFromContext(ctx).Info("wallet freeze set", "user_id", userID, "wallet_id", wallet.ID, "frozen", frozen)
+
+	return &wallet, nil
+}
+
+// Deposit adds funds to the user's wallet in currency, opening that wallet if needed
+func (s *WalletServiceImpl) Deposit(userID int, currency string, amount float64, description string) (balance float64, err error) {
+	currency = normalizeCurrency(currency)
+	ctx, span := tracing.Start(s.ctx, "WalletService.Deposit", attribute.Int("user_id", userID), attribute.String("currency", currency), attribute.Float64("amount", amount))
+	defer func() {
+		observeMovement(ctx, "deposit", currency, amount, err)
+		tracing.End(span, err)
+	}()
+	if !isSupportedCurrency(currency) {
+		return 0, errors.New("unsupported currency")
+	}
+
+	tx := config.GetDB().WithContext(ctx).Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	lockStart := time.Now()
+	wallet, err := lockOrCreateWallet(tx, userID, currency)
+	metrics.LockWait("deposit", time.Since(lockStart))
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := checkNotFrozen(wallet); err != nil {
+		tx.Rollback()
+		return 0, err
 	}
 
 	wallet.Balance += amount
-	if err := tx.Save(&wallet).Error; err != nil {
+	if err := tx.Save(wallet).Error; err != nil {
 		tx.Rollback()
 		return 0, err
 	}
@@ -50,6 +206,7 @@ func (s *WalletServiceImpl) Deposit(userID int, amount float64, description stri
 		Type:        "deposit",
 		ToUserID:    userID,
 		Amount:      amount,
+		Currency:    currency,
 		Description: description,
 		Status:      "completed",
 	}
@@ -59,16 +216,44 @@ func (s *WalletServiceImpl) Deposit(userID int, amount float64, description stri
 		return 0, err
 	}
 
+	if err := eventstore.Append(tx, wallet, eventstore.Credited(wallet, &transaction)); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := events.Record(tx, events.Deposited, []string{events.WalletKey(wallet.ID)}, events.BalanceChangedData{
+		TransactionID: transaction.ID,
+		UserID:        userID,
+		WalletID:      wallet.ID,
+		Currency:      currency,
+		Amount:        amount,
+		Balance:       wallet.Balance,
+		Description:   description,
+	}); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return 0, err
 	}
 
+	config.LoggerFromContext(ctx).Info("deposit completed", "user_id", userID, "wallet_id", wallet.ID,
+		"transaction_id", transaction.ID, "currency", currency, "amount", amount, "balance", wallet.Balance)
 	return wallet.Balance, nil
 }
 
-// Withdraw removes funds from wallet
-func (s *WalletServiceImpl) Withdraw(userID int, amount float64, description string) (float64, error) {
-	tx := config.GetDB().Begin()
+// Withdraw removes funds from wallet, drawing only from the main balance
+// plus any overdraft credit line
+func (s *WalletServiceImpl) Withdraw(userID int, currency string, amount float64, description string) (balance float64, err error) {
+	currency = normalizeCurrency(currency)
+	ctx, span := tracing.Start(s.ctx, "WalletService.Withdraw", attribute.Int("user_id", userID), attribute.String("currency", currency), attribute.Float64("amount", amount))
+	defer func() {
+		observeMovement(ctx, "withdraw", currency, amount, err)
+		tracing.End(span, err)
+	}()
+
+	tx := config.GetDB().WithContext(ctx).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -76,12 +261,19 @@ func (s *WalletServiceImpl) Withdraw(userID int, amount float64, description str
 	}()
 
 	var wallet models.Wallets
-	if result := tx.Where("user_id = ?", userID).First(&wallet); result.Error != nil {
+	lockStart := time.Now()
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND currency = ?", userID, currency).First(&wallet)
+	metrics.LockWait("withdraw", time.Since(lockStart))
+	if result.Error != nil {
 		tx.Rollback()
 		return 0, errors.New("wallet not found")
 	}
+	if err := checkNotFrozen(&wallet); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
 
-	if wallet.Balance < amount {
+	if wallet.Balance+wallet.OverdraftLimit < amount {
 		tx.Rollback()
 		return 0, errors.New("insufficient balance")
 	}
@@ -96,6 +288,7 @@ func (s *WalletServiceImpl) Withdraw(userID int, amount float64, description str
 		Type:        "withdraw",
 		FromUserID:  userID,
 		Amount:      amount,
+		Currency:    currency,
 		Description: description,
 		Status:      "completed",
 	}
@@ -105,34 +298,73 @@ func (s *WalletServiceImpl) Withdraw(userID int, amount float64, description str
 		return 0, err
 	}
 
+	if err := eventstore.Append(tx, &wallet, eventstore.Debited(&wallet, &transaction)); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := events.Record(tx, events.Withdrawn, []string{events.WalletKey(wallet.ID)}, events.BalanceChangedData{
+		TransactionID: transaction.ID,
+		UserID:        userID,
+		WalletID:      wallet.ID,
+		Currency:      currency,
+		Amount:        amount,
+		Balance:       wallet.Balance,
+		Description:   description,
+	}); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return 0, err
 	}
 
+	config.LoggerFromContext(ctx).Info("withdraw completed", "user_id", userID, "wallet_id", wallet.ID,
+		"transaction_id", transaction.ID, "currency", currency, "amount", amount, "balance", wallet.Balance)
 	return wallet.Balance, nil
 }
 
-// Transfer moves funds between wallets
-func (s *WalletServiceImpl) Transfer(fromUserID, toUserID int, amount float64, description string) (float64, float64, error) {
-	tx := config.GetDB().Begin()
+// Transfer moves funds between wallets, drawing only from the sender's main balance
+// plus any overdraft credit line
+func (s *WalletServiceImpl) Transfer(fromUserID, toUserID int, currency string, amount float64, description string) (fromBalance, toBalance float64, err error) {
+	currency = normalizeCurrency(currency)
+	ctx, span := tracing.Start(s.ctx, "WalletService.Transfer", attribute.Int("from_user_id", fromUserID), attribute.Int("to_user_id", toUserID), attribute.String("currency", currency), attribute.Float64("amount", amount))
+	defer func() {
+		observeMovement(ctx, "transfer", currency, amount, err)
+		tracing.End(span, err)
+	}()
+
+	tx := config.GetDB().WithContext(ctx).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
 		}
 	}()
 
-	var fromWallet, toWallet models.Wallets
-	if result := tx.Where("user_id = ?", fromUserID).First(&fromWallet); result.Error != nil {
+	lockStart := time.Now()
+	fromWallet, toWallet, err := lockWalletPair(tx, fromUserID, currency, toUserID, currency)
+	metrics.LockWait("transfer", time.Since(lockStart))
+	if err != nil {
 		tx.Rollback()
-		return 0, 0, errors.New("sender wallet not found")
+		switch {
+		case errors.Is(err, errSourceWalletNotFound):
+			return 0, 0, errors.New("sender wallet not found")
+		case err.Error() == "wallet not found":
+			return 0, 0, errors.New("recipient wallet not found")
+		}
+		return 0, 0, err
 	}
-
-	if result := tx.Where("user_id = ?", toUserID).First(&toWallet); result.Error != nil {
+	if err := checkNotFrozen(fromWallet); err != nil {
 		tx.Rollback()
-		return 0, 0, errors.New("recipient wallet not found")
+		return 0, 0, err
+	}
+	if toWallet.Frozen {
+		tx.Rollback()
+		return 0, 0, errors.New("recipient wallet frozen")
 	}
 
-	if fromWallet.Balance < amount {
+	if fromWallet.Balance+fromWallet.OverdraftLimit < amount {
 		tx.Rollback()
 		return 0, 0, errors.New("insufficient balance")
 	}
@@ -140,12 +372,12 @@ func (s *WalletServiceImpl) Transfer(fromUserID, toUserID int, amount float64, d
 	fromWallet.Balance -= amount
 	toWallet.Balance += amount
 
-	if err := tx.Save(&fromWallet).Error; err != nil {
+	if err := tx.Save(fromWallet).Error; err != nil {
 		tx.Rollback()
 		return 0, 0, err
 	}
 
-	if err := tx.Save(&toWallet).Error; err != nil {
+	if err := tx.Save(toWallet).Error; err != nil {
 		tx.Rollback()
 		return 0, 0, err
 	}
@@ -155,6 +387,7 @@ func (s *WalletServiceImpl) Transfer(fromUserID, toUserID int, amount float64, d
 		FromUserID:  fromUserID,
 		ToUserID:    toUserID,
 		Amount:      amount,
+		Currency:    currency,
 		Description: description,
 		Status:      "completed",
 	}
@@ -164,9 +397,65 @@ func (s *WalletServiceImpl) Transfer(fromUserID, toUserID int, amount float64, d
 		return 0, 0, err
 	}
 
+	if err := eventstore.Append(tx, fromWallet, eventstore.Debited(fromWallet, &transaction)); err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	if err := eventstore.Append(tx, toWallet, eventstore.Credited(toWallet, &transaction)); err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	if err := events.Record(tx, events.Transferred, []string{events.WalletKey(fromWallet.ID), events.WalletKey(toWallet.ID)}, events.TransferredData{
+		TransactionID: transaction.ID,
+		FromUserID:    fromUserID,
+		ToUserID:      toUserID,
+		FromWalletID:  fromWallet.ID,
+		ToWalletID:    toWallet.ID,
+		Currency:      currency,
+		Amount:        amount,
+		FromBalance:   fromWallet.Balance,
+		ToBalance:     toWallet.Balance,
+		Description:   description,
+	}); err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return 0, 0, err
 	}
 
+	config.LoggerFromContext(ctx).Info("transfer completed", "from_user_id", fromUserID, "to_user_id", toUserID,
+		"transaction_id", transaction.ID, "currency", currency, "amount", amount)
 	return fromWallet.Balance, toWallet.Balance, nil
 }
+
+// observeMovement records a deposit, withdrawal or transfer attempt in the metrics,
+// with its outcome derived from the error returned to the caller, and logs failures
+func observeMovement(ctx context.Context, kind, currency string, amount float64, err error) {
+	outcome := "success"
+	if err != nil {
+		switch err.Error() {
+		case "insufficient balance":
+			outcome = "insufficient_balance"
+			metrics.InsufficientBalance(kind)
+		case "wallet frozen", "recipient wallet frozen":
+			outcome = "frozen"
+		case "wallet not found", "sender wallet not found", "recipient wallet not found":
+			outcome = "not_found"
+		case "unsupported currency":
+			outcome = "rejected"
+		default:
+			outcome = "error"
+		}
+		config.LoggerFromContext(ctx).Warn(kind+" failed", "currency", currency, "amount", amount, "outcome", outcome, "error", err.Error())
+	}
+
+	// Unknown currencies come from the request; keep them out of the label values
+	if !isSupportedCurrency(currency) {
+		currency = "other"
+	}
+	metrics.Movement(kind, currency, outcome, amount)
+}
@@ -0,0 +1,523 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"wallet/config"
+	"wallet/events"
+	"wallet/models"
+	"wallet/webhook"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WebhookServiceImpl implements webhook service interfaces. It is also the outbox
+// sink that queues a delivery for every subscription interested in an event.
+type WebhookServiceImpl struct {
+	client *http.Client
+}
+
+// NewWebhookService creates webhook service instance
+func NewWebhookService() *WebhookServiceImpl {
+	conf := config.GetConf().Webhook
+	return &WebhookServiceImpl{client: webhook.NewClient(conf.Timeout, conf.AllowPrivateNetworks)}
+}
+
+// WebhookDeliveryDetail is a delivery with its attempt log
+type WebhookDeliveryDetail struct {
+	models.WebhookDeliveries
+	AttemptLog []models.WebhookAttempts `json:"attempt_log"`
+}
+
+// CreateSubscription subscribes clientID's callback URL to eventTypes (all types
+// when empty) and returns the subscription with its signing secret, which is
+// only shown here.
+func (s *WebhookServiceImpl) CreateSubscription(clientID uint, callbackURL string, eventTypes []string) (*models.WebhookSubscriptions, string, error) {
+	if err := validateCallbackURL(callbackURL); err != nil {
+		return nil, "", err
+	}
+	types, err := normalizeEventTypes(eventTypes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := newToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	subscription := &models.WebhookSubscriptions{
+		ClientID:   clientID,
+		URL:        callbackURL,
+		Secret:     secret,
+		EventTypes: types,
+		Status:     "active",
+	}
+	if err := config.GetDB().Create(subscription).Error; err != nil {
+		return nil, "", err
+	}
+
+	return subscription, secret, nil
+}
+
+// GetSubscriptions lists clientID's subscriptions
+func (s *WebhookServiceImpl) GetSubscriptions(clientID uint) ([]models.WebhookSubscriptions, error) {
+	var subscriptions []models.WebhookSubscriptions
+	if err := config.GetDB().Where("client_id = ?", clientID).Order("id ASC").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// GetSubscription retrieves one of clientID's subscriptions
+func (s *WebhookServiceImpl) GetSubscription(clientID, id uint) (*models.WebhookSubscriptions, error) {
+	var subscription models.WebhookSubscriptions
+	if result := config.GetDB().Where("id = ? AND client_id = ?", id, clientID).First(&subscription); result.Error != nil {
+		return nil, errors.New("subscription not found")
+	}
+	return &subscription, nil
+}
+
+// UpdateSubscription changes the URL, event types or state of a subscription.
+// Activating a subscription in dead_letter resumes delivery of its queued events;
+// the dead deliveries themselves are sent again only when replayed.
+func (s *WebhookServiceImpl) UpdateSubscription(clientID, id uint, callbackURL *string, eventTypes []string, active *bool) (*models.WebhookSubscriptions, error) {
+	subscription, err := s.GetSubscription(clientID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if callbackURL != nil {
+		if err := validateCallbackURL(*callbackURL); err != nil {
+			return nil, err
+		}
+		subscription.URL = *callbackURL
+	}
+	if eventTypes != nil {
+		types, err := normalizeEventTypes(eventTypes)
+		if err != nil {
+			return nil, err
+		}
+		subscription.EventTypes = types
+	}
+	if active != nil {
+		if *active {
+			subscription.Status = "active"
+			subscription.LastError = ""
+		} else {
+			subscription.Status = "disabled"
+		}
+	}
+
+	if err := config.GetDB().Save(subscription).Error; err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// DeleteSubscription removes a subscription; its delivery log is kept
+func (s *WebhookServiceImpl) DeleteSubscription(clientID, id uint) error {
+	result := config.GetDB().Where("id = ? AND client_id = ?", id, clientID).Delete(&models.WebhookSubscriptions{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("subscription not found")
+	}
+	return nil
+}
+
+// GetDeliveries lists a subscription's most recent deliveries, optionally only
+// those with status (pending, succeeded, dead)
+func (s *WebhookServiceImpl) GetDeliveries(clientID, subscriptionID uint, status string, limit int) ([]models.WebhookDeliveries, error) {
+	if _, err := s.GetSubscription(clientID, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	query := config.GetDB().Where("subscription_id = ?", subscriptionID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDeliveries
+	if err := query.Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// GetDelivery retrieves a delivery of one of clientID's subscriptions with its attempt log
+func (s *WebhookServiceImpl) GetDelivery(clientID, id uint) (*WebhookDeliveryDetail, error) {
+	var delivery models.WebhookDeliveries
+	if result := config.GetDB().Where("id = ?", id).First(&delivery); result.Error != nil {
+		return nil, errors.New("delivery not found")
+	}
+	if _, err := s.GetSubscription(clientID, delivery.SubscriptionID); err != nil {
+		return nil, errors.New("delivery not found")
+	}
+
+	detail := &WebhookDeliveryDetail{WebhookDeliveries: delivery, AttemptLog: []models.WebhookAttempts{}}
+	if err := config.GetDB().Where("delivery_id = ?", id).Order("id ASC").Find(&detail.AttemptLog).Error; err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// Replay queues eventID for the subscription again, whether it was delivered,
+// dead-lettered or never sent to it. The event must concern one of the client's users.
+func (s *WebhookServiceImpl) Replay(clientID, subscriptionID uint, eventID string) (*models.WebhookDeliveries, error) {
+	if _, err := s.GetSubscription(clientID, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	var row models.OutboxEvents
+	if result := config.GetDB().Where("event_id = ?", eventID).First(&row); result.Error != nil {
+		return nil, errors.New("event not found")
+	}
+	event := events.FromOutbox(&row)
+
+	clientIDs, err := eventClientIDs(config.GetDB(), event)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(clientIDs, clientID) {
+		return nil, errors.New("event not found")
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := models.WebhookDeliveries{
+		SubscriptionID: subscriptionID,
+		EventID:        event.ID,
+		EventType:      event.Type,
+		Payload:        string(payload),
+	}
+	err = config.GetDB().Where("subscription_id = ? AND event_id = ?", subscriptionID, event.ID).
+		Assign(map[string]interface{}{
+			"status":          "pending",
+			"attempts":        0,
+			"last_error":      "",
+			"next_attempt_at": nil,
+			"delivered_at":    nil,
+		}).
+		FirstOrCreate(&delivery).Error
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// Name identifies the sink in relay errors
+func (s *WebhookServiceImpl) Name() string {
+	return "webhooks"
+}
+
+// Publish queues event for every active or dead-lettered subscription of the
+// clients whose users it concerns. Sending happens in Dispatch, so a slow partner
+// never holds up the outbox. Queuing the same event twice is a no-op.
+func (s *WebhookServiceImpl) Publish(ctx context.Context, event events.Event) error {
+	db := config.GetDB().WithContext(ctx)
+
+	clientIDs, err := eventClientIDs(db, event)
+	if err != nil {
+		return err
+	}
+	if len(clientIDs) == 0 {
+		return nil
+	}
+
+	var subscriptions []models.WebhookSubscriptions
+	if err := db.Where("client_id IN ? AND status IN ?", clientIDs, []string{"active", "dead_letter"}).Find(&subscriptions).Error; err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var deliveries []models.WebhookDeliveries
+	for _, subscription := range subscriptions {
+		if !subscribedTo(&subscription, event.Type) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDeliveries{
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        string(payload),
+			Status:         "pending",
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+}
+
+// Dispatch sends due deliveries of active subscriptions and returns how many
+// succeeded. A failed delivery is retried with exponential backoff; once it runs
+// out of attempts it is dead-lettered and its subscription paused.
+func (s *WebhookServiceImpl) Dispatch(ctx context.Context) (int, error) {
+	conf := config.GetConf().Webhook
+	now := time.Now()
+
+	var due []models.WebhookDeliveries
+	err := config.GetDB().WithContext(ctx).
+		Select("webhook_deliveries.*").
+		Joins("JOIN webhook_subscriptions ON webhook_subscriptions.id = webhook_deliveries.subscription_id").
+		Where("webhook_deliveries.status = ? AND webhook_subscriptions.status = ?", "pending", "active").
+		Where("(webhook_deliveries.next_attempt_at IS NULL OR webhook_deliveries.next_attempt_at <= ?)", now).
+		Order("webhook_deliveries.id ASC").
+		Limit(conf.BatchSize).
+		Find(&due).Error
+	if err != nil {
+		return 0, err
+	}
+
+	succeeded := 0
+	for i := range due {
+		if ctx.Err() != nil {
+			break
+		}
+
+		delivery := &due[i]
+		claimed, err := claimDelivery(delivery, now, now.Add(conf.Timeout+time.Minute))
+		if err != nil {
+			return succeeded, err
+		}
+		if !claimed {
+			continue
+		}
+
+		var subscription models.WebhookSubscriptions
+		if result := config.GetDB().Where("id = ?", delivery.SubscriptionID).First(&subscription); result.Error != nil {
+			continue
+		}
+
+		ok, err := s.deliver(ctx, &subscription, delivery)
+		if err != nil {
+			return succeeded, err
+		}
+		if ok {
+			succeeded++
+		}
+	}
+
+	return succeeded, nil
+}
+
+// Run dispatches batches until none is full
+func (s *WebhookServiceImpl) Run(ctx context.Context) error {
+	batchSize := config.GetConf().Webhook.BatchSize
+	for {
+		succeeded, err := s.Dispatch(ctx)
+		if err != nil {
+			return err
+		}
+		if succeeded < batchSize || ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// deliver sends one delivery, logs the attempt and schedules a retry or
+// dead-letters it on failure
+func (s *WebhookServiceImpl) deliver(ctx context.Context, subscription *models.WebhookSubscriptions, delivery *models.WebhookDeliveries) (bool, error) {
+	conf := config.GetConf().Webhook
+
+	start := time.Now()
+	status, sendErr := s.send(ctx, subscription, delivery)
+	attempt := models.WebhookAttempts{
+		DeliveryID:     delivery.ID,
+		Attempt:        delivery.Attempts + 1,
+		ResponseStatus: status,
+		DurationMs:     time.Since(start).Milliseconds(),
+	}
+	if sendErr != nil {
+		attempt.Error = truncateError(sendErr.Error())
+	}
+
+	tx := config.GetDB().Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&attempt).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	updates := map[string]interface{}{
+		"attempts":        attempt.Attempt,
+		"response_status": status,
+		"last_error":      attempt.Error,
+	}
+	switch {
+	case sendErr == nil:
+		now := time.Now()
+		updates["status"] = "succeeded"
+		updates["delivered_at"] = &now
+		updates["next_attempt_at"] = nil
+	case attempt.Attempt >= conf.MaxAttempts:
+		updates["status"] = "dead"
+		updates["next_attempt_at"] = nil
+		// Pause the subscription so its queued events wait for the partner to recover
+		err := tx.Model(&models.WebhookSubscriptions{}).Where("id = ? AND status = ?", subscription.ID, "active").
+			Updates(map[string]interface{}{"status": "dead_letter", "last_error": attempt.Error}).Error
+		if err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	default:
+		updates["next_attempt_at"] = time.Now().Add(webhookBackoff(attempt.Attempt, conf.InitialBackoff, conf.MaxBackoff))
+	}
+
+	if err := tx.Model(delivery).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return false, err
+	}
+
+	return sendErr == nil, nil
+}
+
+// send POSTs the delivery's payload signed with the subscription secret and
+// returns the response status; any non-2xx response is an error
+func (s *WebhookServiceImpl) send(ctx context.Context, subscription *models.WebhookSubscriptions, delivery *models.WebhookDeliveries) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.HeaderSignature, webhook.Sign(subscription.Secret, time.Now(), body))
+	req.Header.Set(webhook.HeaderEventID, delivery.EventID)
+	req.Header.Set(webhook.HeaderEventType, delivery.EventType)
+	req.Header.Set(webhook.HeaderDeliveryID, fmt.Sprintf("%d", delivery.ID))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// claimDelivery leases a due delivery until leaseUntil so that no other
+// dispatcher sends it meanwhile; it reports false if the delivery was taken
+func claimDelivery(delivery *models.WebhookDeliveries, now, leaseUntil time.Time) (bool, error) {
+	result := config.GetDB().Model(&models.WebhookDeliveries{}).
+		Where("id = ? AND status = ?", delivery.ID, "pending").
+		Where("(next_attempt_at IS NULL OR next_attempt_at <= ?)", now).
+		Update("next_attempt_at", leaseUntil)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// eventClientIDs returns the API clients whose users the event concerns
+func eventClientIDs(db *gorm.DB, event events.Event) ([]uint, error) {
+	recipients, err := events.Recipients(db, []events.Event{event})
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients[0]) == 0 {
+		return nil, nil
+	}
+
+	userIDs := make([]int, 0, len(recipients[0]))
+	for userID := range recipients[0] {
+		userIDs = append(userIDs, userID)
+	}
+
+	var clientIDs []uint
+	if err := db.Model(&models.Users{}).Where("id IN ? AND client_id <> 0", userIDs).Distinct().Pluck("client_id", &clientIDs).Error; err != nil {
+		return nil, err
+	}
+	return clientIDs, nil
+}
+
+// subscribedTo reports whether the subscription wants events of eventType
+func subscribedTo(subscription *models.WebhookSubscriptions, eventType string) bool {
+	if subscription.EventTypes == "" {
+		return true
+	}
+	return slices.Contains(strings.Split(subscription.EventTypes, ","), eventType)
+}
+
+// normalizeEventTypes validates eventTypes and joins them for storage
+func normalizeEventTypes(eventTypes []string) (string, error) {
+	var types []string
+	for _, eventType := range eventTypes {
+		eventType = strings.TrimSpace(eventType)
+		if !slices.Contains(events.Types, eventType) {
+			return "", errors.New("unknown event type")
+		}
+		if !slices.Contains(types, eventType) {
+			types = append(types, eventType)
+		}
+	}
+	return strings.Join(types, ","), nil
+}
+
+// validateCallbackURL accepts absolute http and https URLs whose host resolves to
+// public addresses only; the delivery client checks the address again when it connects
+func validateCallbackURL(callbackURL string) error {
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || len(callbackURL) > 500 {
+		return errors.New("invalid url")
+	}
+	if config.GetConf().Webhook.AllowPrivateNetworks {
+		return nil
+	}
+	if err := webhook.CheckHost(context.Background(), u.Hostname()); err != nil {
+		return errors.New("url not allowed")
+	}
+	return nil
+}
+
+// webhookBackoff returns the delay before the retry following attempt number
+// attempts, doubling from initial up to max
+func webhookBackoff(attempts int, initial, max time.Duration) time.Duration {
+	delay := initial
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// truncateError shortens an error message to fit a varchar(255) column
+func truncateError(message string) string {
+	if len(message) > 255 {
+		return message[:255]
+	}
+	return message
+}
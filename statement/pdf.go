@@ -0,0 +1,145 @@
+package statement
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Page layout of PDF statements, in points (A4)
+const (
+	pdfPageWidth    = 595
+	pdfPageHeight   = 842
+	pdfMargin       = 40
+	pdfFontSize     = 7
+	pdfLineHeight   = 10
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin) / pdfLineHeight
+)
+
+// WritePDF renders st as a plain text PDF document set in a monospaced font
+func WritePDF(w io.Writer, st *Statement) error {
+	return writePDF(w, pdfLines(st))
+}
+
+// pdfLines lays out st as fixed-width text lines
+func pdfLines(st *Statement) []string {
+	lines := []string{
+		"ACCOUNT STATEMENT",
+		"",
+		fmt.Sprintf("User:      %d", st.UserID),
+		fmt.Sprintf("Currency:  %s", st.Currency),
+		fmt.Sprintf("Period:    %s - %s", st.From.Format(time.RFC3339), st.To.Format(time.RFC3339)),
+		fmt.Sprintf("Generated: %s", st.GeneratedAt.Format(time.RFC3339)),
+		"",
+		fmt.Sprintf("%-19s %-10s %-18s %-22s %12s %12s %12s", "Date", "ID", "Type", "Description", "Credit", "Debit", "Balance"),
+		strings.Repeat("-", 111),
+		fmt.Sprintf("%-19s %-10s %-18s %-22s %12s %12s %12s", st.From.Format("2006-01-02 15:04:05"), "", "Opening balance", "", "", "", money(st.OpeningBalance)),
+	}
+	for _, line := range st.Lines {
+		lines = append(lines, fmt.Sprintf("%-19s %-10d %-18s %-22s %12s %12s %12s",
+			line.Date.Format("2006-01-02 15:04:05"),
+			line.TransactionID,
+			truncate(line.Type, 18),
+			truncate(line.Description, 22),
+			money(line.Credit),
+			money(line.Debit),
+			money(line.RunningBalance),
+		))
+	}
+	lines = append(lines,
+		strings.Repeat("-", 111),
+		fmt.Sprintf("%-19s %-10s %-18s %-22s %12s %12s %12s", st.To.Format("2006-01-02 15:04:05"), "", "Closing balance", "", money(st.TotalCredits), money(st.TotalDebits), money(st.ClosingBalance)),
+		"",
+		"Totals by type",
+		fmt.Sprintf("%-18s %8s %12s %12s", "Type", "Count", "Credits", "Debits"),
+	)
+	for _, total := range st.Totals {
+		lines = append(lines, fmt.Sprintf("%-18s %8d %12s %12s", truncate(total.Type, 18), total.Count, money(total.Credits), money(total.Debits)))
+	}
+
+	return lines
+}
+
+// writePDF writes text lines as a PDF, paginating as needed
+func writePDF(w io.Writer, lines []string) error {
+	var pages [][]string
+	for len(lines) > pdfLinesPerPage {
+		pages = append(pages, lines[:pdfLinesPerPage])
+		lines = lines[pdfLinesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	// Objects: 1 catalog, 2 page tree, 3 font, then a page and its content stream per page
+	var objects []string
+	objects = append(objects, "<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects,
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+	)
+
+	for i, page := range pages {
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT /F1 %d Tf %d TL %d %d Td\n", pdfFontSize, pdfLineHeight, pdfMargin, pdfPageHeight-pdfMargin)
+		for _, line := range page {
+			fmt.Fprintf(&content, "(%s) '\n", escapePDF(line))
+		}
+		fmt.Fprintf(&content, "ET")
+
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				pdfPageWidth, pdfPageHeight, 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// escapePDF escapes a PDF string literal, replacing characters outside printable ASCII
+func escapePDF(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// truncate shortens s to at most n characters
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "~"
+}
@@ -0,0 +1,93 @@
+package statement
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ContentType returns the MIME type of format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatPDF:
+		return "application/pdf"
+	default:
+		return "application/json; charset=utf-8"
+	}
+}
+
+// FileName returns the download file name of a statement in format
+func FileName(userID int, currency string, from, to time.Time, format string) string {
+	return fmt.Sprintf("statement-%d-%s-%s-%s.%s", userID, currency,
+		from.Format("20060102"), to.Format("20060102"), format)
+}
+
+// Write renders st to w in format
+func Write(w io.Writer, st *Statement, format string) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, st)
+	case FormatJSON:
+		return WriteJSON(w, st)
+	case FormatPDF:
+		return WritePDF(w, st)
+	default:
+		return ErrUnsupportedFormat
+	}
+}
+
+// WriteJSON renders st as a JSON document
+func WriteJSON(w io.Writer, st *Statement) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(st)
+}
+
+// WriteCSV renders st as CSV: the transaction lines framed by the opening and
+// closing balances, followed by the totals by type
+func WriteCSV(w io.Writer, st *Statement) error {
+	writer := csv.NewWriter(w)
+	records := [][]string{
+		{"user_id", strconv.Itoa(st.UserID)},
+		{"currency", st.Currency},
+		{"from", st.From.Format(time.RFC3339)},
+		{"to", st.To.Format(time.RFC3339)},
+		{},
+		{"date", "transaction_id", "type", "description", "credit", "debit", "balance"},
+		{st.From.Format(time.RFC3339), "", "opening_balance", "", "", "", money(st.OpeningBalance)},
+	}
+	for _, line := range st.Lines {
+		records = append(records, []string{
+			line.Date.Format(time.RFC3339),
+			strconv.FormatUint(uint64(line.TransactionID), 10),
+			line.Type,
+			line.Description,
+			money(line.Credit),
+			money(line.Debit),
+			money(line.RunningBalance),
+		})
+	}
+	records = append(records,
+		[]string{st.To.Format(time.RFC3339), "", "closing_balance", "", money(st.TotalCredits), money(st.TotalDebits), money(st.ClosingBalance)},
+		[]string{},
+		[]string{"type", "count", "credits", "debits"},
+	)
+	for _, total := range st.Totals {
+		records = append(records, []string{total.Type, strconv.Itoa(total.Count), money(total.Credits), money(total.Debits)})
+	}
+
+	if err := writer.WriteAll(records); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// money formats an amount with two decimals
+func money(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
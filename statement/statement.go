@@ -0,0 +1,116 @@
+package statement
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"wallet/models"
+)
+
+// Supported output formats
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatPDF  = "pdf"
+)
+
+// ErrUnsupportedFormat is returned for formats other than csv, json and pdf
+var ErrUnsupportedFormat = errors.New("unsupported statement format")
+
+// Statement is a wallet's account statement for the period [From, To)
+type Statement struct {
+	UserID         int         `json:"user_id"`
+	Currency       string      `json:"currency"`
+	From           time.Time   `json:"from"`
+	To             time.Time   `json:"to"`
+	OpeningBalance float64     `json:"opening_balance"`
+	TotalCredits   float64     `json:"total_credits"`
+	TotalDebits    float64     `json:"total_debits"`
+	ClosingBalance float64     `json:"closing_balance"`
+	Totals         []TypeTotal `json:"totals"`
+	Lines          []Line      `json:"lines"`
+	GeneratedAt    time.Time   `json:"generated_at"`
+}
+
+// Line is one transaction on a statement with the balance after it
+type Line struct {
+	TransactionID  uint      `json:"transaction_id"`
+	Date           time.Time `json:"date"`
+	Type           string    `json:"type"`
+	Description    string    `json:"description,omitempty"`
+	Credit         float64   `json:"credit"`
+	Debit          float64   `json:"debit"`
+	RunningBalance float64   `json:"running_balance"`
+}
+
+// TypeTotal sums a statement's transactions of one type
+type TypeTotal struct {
+	Type    string  `json:"type"`
+	Count   int     `json:"count"`
+	Credits float64 `json:"credits"`
+	Debits  float64 `json:"debits"`
+}
+
+// New builds a statement for userID's main balance from the opening balance and the
+// period's transactions in chronological order. Transactions paid to the user are
+// credits, transactions paid by the user are debits.
+func New(userID int, currency string, from, to time.Time, openingBalance float64, transactions []models.Transaction) *Statement {
+	st := &Statement{
+		UserID:         userID,
+		Currency:       currency,
+		From:           from,
+		To:             to,
+		OpeningBalance: openingBalance,
+		Totals:         []TypeTotal{},
+		Lines:          make([]Line, 0, len(transactions)),
+		GeneratedAt:    time.Now(),
+	}
+
+	balance := openingBalance
+	totals := make(map[string]*TypeTotal)
+	for _, transaction := range transactions {
+		line := Line{
+			TransactionID: transaction.ID,
+			Date:          transaction.CreatedAt,
+			Type:          transaction.Type,
+			Description:   transaction.Description,
+		}
+		if transaction.ToUserID == userID {
+			line.Credit = transaction.Amount
+		}
+		if transaction.FromUserID == userID {
+			line.Debit = transaction.Amount
+		}
+		balance = round(balance + line.Credit - line.Debit)
+		line.RunningBalance = balance
+		st.Lines = append(st.Lines, line)
+
+		total, ok := totals[transaction.Type]
+		if !ok {
+			total = &TypeTotal{Type: transaction.Type}
+			totals[transaction.Type] = total
+		}
+		total.Count++
+		total.Credits = round(total.Credits + line.Credit)
+		total.Debits = round(total.Debits + line.Debit)
+		st.TotalCredits = round(st.TotalCredits + line.Credit)
+		st.TotalDebits = round(st.TotalDebits + line.Debit)
+	}
+	st.ClosingBalance = balance
+
+	for _, total := range totals {
+		st.Totals = append(st.Totals, *total)
+	}
+	sort.Slice(st.Totals, func(i, j int) bool {
+		return st.Totals[i].Type < st.Totals[j].Type
+	})
+
+	return st
+}
+
+// round rounds a money amount to cents
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
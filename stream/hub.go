@@ -0,0 +1,285 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"wallet/config"
+	"wallet/events"
+	"wallet/models"
+)
+
+// Subscriber receives the updates of one user. C is closed when the hub drops
+// the subscriber because it fell behind; the client then reconnects with the
+// last event ID it received and catches up from the outbox.
+type Subscriber struct {
+	UserID int
+	C      chan Update
+	closed bool
+}
+
+// Hub reads new outbox events and fans them out to the subscribers of the users
+// they concern. It reads the outbox table itself rather than being an outbox
+// sink, so every instance sees every event whichever instance relays them.
+//
+// Outbox IDs are assigned at insert but become visible at commit, so a lower ID
+// can show up after a higher one. The hub keeps delivering above such gaps and
+// keeps looking for the missing IDs until GapTimeout, then gives up on them
+// (they were most likely rolled back).
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[int]map[*Subscriber]struct{}
+	closed      bool // set on shutdown; new subscribers are closed immediately
+
+	started bool
+	cursor  uint               // every ID up to cursor has been handled
+	maxSeen uint               // highest ID handled
+	seen    map[uint]bool      // handled IDs above cursor
+	gaps    map[uint]time.Time // missing IDs above cursor and when they were noticed
+	conf    config.StreamConf
+}
+
+var (
+	defaultHub *Hub
+	hubMu      sync.Mutex
+)
+
+// DefaultHub returns the hub shared by the streaming endpoint and its poll job
+func DefaultHub() *Hub {
+	hubMu.Lock()
+	defer hubMu.Unlock()
+
+	if defaultHub == nil {
+		defaultHub = NewHub(config.GetConf().Stream)
+	}
+	return defaultHub
+}
+
+// NewHub creates a hub; it starts from the newest outbox event on its first Poll
+func NewHub(conf config.StreamConf) *Hub {
+	return &Hub{
+		subscribers: make(map[int]map[*Subscriber]struct{}),
+		seen:        make(map[uint]bool),
+		gaps:        make(map[uint]time.Time),
+		conf:        conf,
+	}
+}
+
+// Subscribe registers a subscriber for userID's updates
+func (h *Hub) Subscribe(userID int) *Subscriber {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := &Subscriber{UserID: userID, C: make(chan Update, h.conf.BufferSize)}
+	if h.closed {
+		sub.closed = true
+		close(sub.C)
+		return sub
+	}
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*Subscriber]struct{})
+	}
+	h.subscribers[userID][sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes a subscriber and closes its channel
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.drop(sub)
+}
+
+// Close closes every subscriber so open streams end, and refuses new ones. It is
+// called on shutdown; clients reconnect to another instance and resume there.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.subscribers {
+		for sub := range subs {
+			h.drop(sub)
+		}
+	}
+}
+
+// Poll delivers outbox events committed since the last poll. It is run as a
+// background job at stream.poll_interval.
+func (h *Hub) Poll(ctx context.Context) error {
+	db := config.GetDB().WithContext(ctx)
+
+	if !h.started {
+		newest, err := h.Newest(ctx)
+		if err != nil {
+			return err
+		}
+		h.cursor, h.maxSeen, h.started = newest, newest, true
+		return nil
+	}
+
+	// Missing IDs first, then events above everything handled so far
+	var rows []models.OutboxEvents
+	if len(h.gaps) > 0 {
+		ids := make([]uint, 0, len(h.gaps))
+		for id := range h.gaps {
+			ids = append(ids, id)
+		}
+		if err := db.Where("id IN ?", ids).Find(&rows).Error; err != nil {
+			return err
+		}
+	}
+	var newer []models.OutboxEvents
+	if err := db.Where("id > ?", h.maxSeen).Order("id ASC").Limit(h.conf.MaxReplay).Find(&newer).Error; err != nil {
+		return err
+	}
+	rows = append(rows, newer...)
+
+	now := time.Now()
+	batch := make([]events.Event, 0, len(rows))
+	for i := range rows {
+		id := rows[i].ID
+		if id <= h.cursor || h.seen[id] {
+			continue
+		}
+		for missing := h.maxSeen + 1; missing < id; missing++ {
+			h.gaps[missing] = now
+		}
+		delete(h.gaps, id)
+		h.seen[id] = true
+		if id > h.maxSeen {
+			h.maxSeen = id
+		}
+		batch = append(batch, events.FromOutbox(&rows[i]))
+	}
+
+	if len(batch) > 0 {
+		recipients, err := events.Recipients(db, batch)
+		if err != nil {
+			return err
+		}
+		h.publish(batch, recipients)
+	}
+
+	h.advance(now)
+	return nil
+}
+
+// Newest returns the sequence of the newest outbox event
+func (h *Hub) Newest(ctx context.Context) (uint, error) {
+	var newest uint
+	err := config.GetDB().WithContext(ctx).Model(&models.OutboxEvents{}).Select("COALESCE(MAX(id), 0)").Scan(&newest).Error
+	return newest, err
+}
+
+// Backlog returns userID's updates after lastSeq, for a client reconnecting with
+// Last-Event-ID. It reports false, with no updates, when there are more than
+// stream.max_replay events to replay; the client should then reload its balances.
+func (h *Hub) Backlog(ctx context.Context, userID int, lastSeq uint) ([]Update, bool, error) {
+	db := config.GetDB().WithContext(ctx)
+
+	// Keys of everything the user can see: their wallets, shared wallets and themselves
+	keys := []string{events.UserKey(userID)}
+	var walletIDs, sharedWalletIDs []uint
+	if err := db.Model(&models.Wallets{}).Where("user_id = ?", userID).Pluck("id", &walletIDs).Error; err != nil {
+		return nil, false, err
+	}
+	if err := db.Model(&models.SharedWalletMembers{}).Where("user_id = ?", userID).Pluck("shared_wallet_id", &sharedWalletIDs).Error; err != nil {
+		return nil, false, err
+	}
+	for _, id := range walletIDs {
+		keys = append(keys, events.WalletKey(id))
+	}
+	for _, id := range sharedWalletIDs {
+		keys = append(keys, events.SharedWalletKey(id))
+	}
+
+	query := db.Where("id > ?", lastSeq)
+	match := db.Where("FIND_IN_SET(?, ordering_keys) > 0", keys[0])
+	for _, key := range keys[1:] {
+		match = match.Or("FIND_IN_SET(?, ordering_keys) > 0", key)
+	}
+
+	var rows []models.OutboxEvents
+	if err := query.Where(match).Order("id ASC").Limit(h.conf.MaxReplay + 1).Find(&rows).Error; err != nil {
+		return nil, false, err
+	}
+
+	if len(rows) > h.conf.MaxReplay {
+		return nil, false, nil
+	}
+
+	updates := make([]Update, 0, len(rows))
+	for i := range rows {
+		event := events.FromOutbox(&rows[i])
+		var linked []string
+		for _, key := range event.Keys {
+			for _, own := range keys {
+				if key == own {
+					linked = append(linked, key)
+				}
+			}
+		}
+		if update, ok := Project(event, linked); ok {
+			updates = append(updates, update)
+		}
+	}
+
+	return updates, true, nil
+}
+
+// publish sends each event's updates to the subscribers of the users it concerns
+func (h *Hub) publish(batch []events.Event, recipients []map[int][]string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, event := range batch {
+		for userID, keys := range recipients[i] {
+			subs := h.subscribers[userID]
+			if len(subs) == 0 {
+				continue
+			}
+			update, ok := Project(event, keys)
+			if !ok {
+				continue
+			}
+			for sub := range subs {
+				select {
+				case sub.C <- update:
+				default:
+					// Too slow to keep up; it reconnects and replays from the outbox
+					h.drop(sub)
+				}
+			}
+		}
+	}
+}
+
+// advance moves the cursor over handled IDs and gaps older than GapTimeout
+func (h *Hub) advance(now time.Time) {
+	for h.cursor < h.maxSeen {
+		next := h.cursor + 1
+		if h.seen[next] {
+			delete(h.seen, next)
+		} else if since, ok := h.gaps[next]; ok && now.Sub(since) >= h.conf.GapTimeout {
+			delete(h.gaps, next)
+		} else {
+			return
+		}
+		h.cursor = next
+	}
+}
+
+// drop removes sub and closes its channel; the caller holds h.mu
+func (h *Hub) drop(sub *Subscriber) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.C)
+	delete(h.subscribers[sub.UserID], sub)
+	if len(h.subscribers[sub.UserID]) == 0 {
+		delete(h.subscribers, sub.UserID)
+	}
+}
@@ -0,0 +1,54 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// SSE event names. Updates are sent as unnamed events, so an EventSource
+// receives them in onmessage.
+const (
+	EventReset = "reset" // the client missed too much and should reload its balances
+)
+
+// WriteRetry tells the client how long to wait before reconnecting
+func WriteRetry(w io.Writer, delay time.Duration) error {
+	_, err := fmt.Fprintf(w, "retry: %d\n\n", delay.Milliseconds())
+	return err
+}
+
+// WriteUpdate writes an update with its sequence as the event ID, which the
+// client sends back in Last-Event-ID when it reconnects
+func WriteUpdate(w io.Writer, update Update) error {
+	data, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	return WriteEvent(w, fmt.Sprintf("%d", update.Seq), "", data)
+}
+
+// WriteEvent writes one Server-Sent Event; empty id and event fields are omitted
+func WriteEvent(w io.Writer, id, event string, data []byte) error {
+	var b strings.Builder
+	if id != "" {
+		fmt.Fprintf(&b, "id: %s\n", id)
+	}
+	if event != "" {
+		fmt.Fprintf(&b, "event: %s\n", event)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteHeartbeat writes a comment line that keeps idle connections open
+func WriteHeartbeat(w io.Writer) error {
+	_, err := io.WriteString(w, ": ping\n\n")
+	return err
+}
@@ -0,0 +1,143 @@
+package stream
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"wallet/events"
+)
+
+// Update is what one user sees of a domain event: the transactions it added and
+// the resulting balances of that user's wallets, pockets and shared wallets
+type Update struct {
+	Seq          uint                `json:"seq"` // outbox sequence, sent as the SSE event ID
+	Type         string              `json:"type"`
+	OccurredAt   time.Time           `json:"occurred_at"`
+	Transactions []TransactionUpdate `json:"transactions,omitempty"`
+	Balances     []BalanceUpdate     `json:"balances,omitempty"`
+}
+
+// TransactionUpdate is a new transaction from the user's point of view
+type TransactionUpdate struct {
+	ID             uint    `json:"id"`
+	Currency       string  `json:"currency"`
+	Amount         float64 `json:"amount"` // positive when credited to the user, negative when debited
+	SharedWalletID uint    `json:"shared_wallet_id,omitempty"`
+	Description    string  `json:"description,omitempty"`
+}
+
+// BalanceUpdate is the balance of a wallet, pocket or shared wallet after the event
+type BalanceUpdate struct {
+	WalletID       uint    `json:"wallet_id,omitempty"`
+	PocketID       uint    `json:"pocket_id,omitempty"`
+	SharedWalletID uint    `json:"shared_wallet_id,omitempty"`
+	Currency       string  `json:"currency"`
+	Balance        float64 `json:"balance"`
+}
+
+// Project builds the update for a user linked to event through keys (see
+// events.Recipients). Only the parts reached through those keys are included,
+// so a transfer recipient never sees the sender's balance. It reports false
+// when nothing of the event concerns the user.
+func Project(event events.Event, keys []string) (Update, bool) {
+	update := Update{Seq: event.Seq, Type: event.Type, OccurredAt: event.OccurredAt}
+	has := func(key string) bool { return slices.Contains(keys, key) }
+
+	switch event.Type {
+	case events.UserRegistered:
+		var data events.UserRegisteredData
+		if json.Unmarshal(event.Data, &data) != nil {
+			return update, false
+		}
+		if has(events.UserKey(data.UserID)) {
+			update.Balances = append(update.Balances, BalanceUpdate{WalletID: data.WalletID, Currency: data.Currency})
+		}
+
+	case events.Deposited, events.Withdrawn, events.InterestPaid, events.OverdraftInterestCharged:
+		var data events.BalanceChangedData
+		if json.Unmarshal(event.Data, &data) != nil {
+			return update, false
+		}
+		if has(events.WalletKey(data.WalletID)) {
+			amount := data.Amount
+			if event.Type == events.Withdrawn || event.Type == events.OverdraftInterestCharged {
+				amount = -amount
+			}
+			update.Transactions = append(update.Transactions, TransactionUpdate{ID: data.TransactionID, Currency: data.Currency, Amount: amount, Description: data.Description})
+			update.Balances = append(update.Balances, BalanceUpdate{WalletID: data.WalletID, Currency: data.Currency, Balance: data.Balance})
+		}
+
+	case events.Transferred:
+		var data events.TransferredData
+		if json.Unmarshal(event.Data, &data) != nil {
+			return update, false
+		}
+		if has(events.WalletKey(data.FromWalletID)) {
+			update.Transactions = append(update.Transactions, TransactionUpdate{ID: data.TransactionID, Currency: data.Currency, Amount: -data.Amount, Description: data.Description})
+			update.Balances = append(update.Balances, BalanceUpdate{WalletID: data.FromWalletID, Currency: data.Currency, Balance: data.FromBalance})
+		}
+		if has(events.WalletKey(data.ToWalletID)) {
+			update.Transactions = append(update.Transactions, TransactionUpdate{ID: data.TransactionID, Currency: data.Currency, Amount: data.Amount, Description: data.Description})
+			update.Balances = append(update.Balances, BalanceUpdate{WalletID: data.ToWalletID, Currency: data.Currency, Balance: data.ToBalance})
+		}
+
+	case events.PocketMoved:
+		var data events.PocketMovedData
+		if json.Unmarshal(event.Data, &data) != nil {
+			return update, false
+		}
+		if has(events.WalletKey(data.WalletID)) {
+			amount := data.Amount
+			if data.Direction == "in" {
+				amount = -amount
+			}
+			update.Transactions = append(update.Transactions, TransactionUpdate{ID: data.TransactionID, Currency: data.Currency, Amount: amount})
+			update.Balances = append(update.Balances,
+				BalanceUpdate{WalletID: data.WalletID, Currency: data.Currency, Balance: data.Balance},
+				BalanceUpdate{PocketID: data.PocketID, Currency: data.Currency, Balance: data.PocketBalance},
+			)
+		}
+
+	case events.SharedWalletDeposited, events.SharedWalletSpent:
+		var data events.SharedWalletMovedData
+		if json.Unmarshal(event.Data, &data) != nil {
+			return update, false
+		}
+		if data.WalletID != 0 && has(events.WalletKey(data.WalletID)) {
+			// The member funding a deposit pays, the recipient of a transfer is paid
+			amount := data.Amount
+			if event.Type == events.SharedWalletDeposited {
+				amount = -amount
+			}
+			update.Transactions = append(update.Transactions, TransactionUpdate{ID: data.TransactionID, Currency: data.Currency, Amount: amount, SharedWalletID: data.SharedWalletID})
+			update.Balances = append(update.Balances, BalanceUpdate{WalletID: data.WalletID, Currency: data.Currency, Balance: data.Balance})
+		}
+		if has(events.SharedWalletKey(data.SharedWalletID)) {
+			if len(update.Transactions) == 0 {
+				amount := data.Amount
+				if event.Type == events.SharedWalletSpent {
+					amount = -amount
+				}
+				update.Transactions = append(update.Transactions, TransactionUpdate{ID: data.TransactionID, Currency: data.Currency, Amount: amount, SharedWalletID: data.SharedWalletID})
+			}
+			update.Balances = append(update.Balances, BalanceUpdate{SharedWalletID: data.SharedWalletID, Currency: data.Currency, Balance: data.SharedBalance})
+		}
+
+	case events.CurrencyExchanged:
+		var data events.CurrencyExchangedData
+		if json.Unmarshal(event.Data, &data) != nil {
+			return update, false
+		}
+		if has(events.WalletKey(data.FromWalletID)) {
+			update.Transactions = append(update.Transactions, TransactionUpdate{ID: data.DebitTransactionID, Currency: data.FromCurrency, Amount: -data.FromAmount})
+			update.Balances = append(update.Balances, BalanceUpdate{WalletID: data.FromWalletID, Currency: data.FromCurrency, Balance: data.FromBalance})
+		}
+		if has(events.WalletKey(data.ToWalletID)) {
+			update.Transactions = append(update.Transactions, TransactionUpdate{ID: data.CreditTransactionID, Currency: data.ToCurrency, Amount: data.ToAmount})
+			update.Balances = append(update.Balances, BalanceUpdate{WalletID: data.ToWalletID, Currency: data.ToCurrency, Balance: data.ToBalance})
+		}
+	}
+
+	return update, len(update.Transactions) > 0 || len(update.Balances) > 0
+}
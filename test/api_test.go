@@ -1,16 +1,29 @@
 package test
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"wallet/config"
+	"wallet/events"
+	"wallet/health"
+	"wallet/models"
 	"wallet/router"
+	"wallet/service"
+	"wallet/stream"
+	"wallet/tracing"
+	"wallet/webhook"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -37,12 +50,21 @@ func TestAPI(t *testing.T) {
 		t.Fatalf("Failed to get config")
 	}
 
+	// Requests act as a user the way the API gateway sends them, with its token
+	cfg.Auth.GatewayToken = "test-gateway-token"
+	asUser := func(req *http.Request, userID int) {
+		req.Header.Set("X-User-ID", strconv.Itoa(userID))
+		req.Header.Set("X-Gateway-Token", cfg.Auth.GatewayToken)
+	}
+
 	// Initialize database connection
 	_, err = config.InitDB(cfg)
 	if err != nil {
 		t.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	health.SetState(health.Ready)
+
 	// Create router
 	r := router.SetupRouter()
 
@@ -290,7 +312,7 @@ func TestAPI(t *testing.T) {
 
 	// Test 11: Get User Transactions
 	t.Run("GetUserTransactions", func(t *testing.T) {
-		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/transactions/%d?page=1&limit=10", userID1), nil)
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/users/%d/transactions?page=1&limit=10", userID1), nil)
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
 
@@ -337,4 +359,1243 @@ func TestAPI(t *testing.T) {
 		assert.NoError(t, err)
 		assert.Equal(t, "ok", response["status"])
 	})
+
+	// Test 14: Create Pocket
+	var pocketID int
+	t.Run("CreatePocket", func(t *testing.T) {
+		pocketReq := map[string]interface{}{
+			"name":        "vacation",
+			"goal_amount": 500.00,
+		}
+		body, _ := json.Marshal(pocketReq)
+
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/wallets/%d/pockets", userID1), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response struct {
+			Code    int                    `json:"code"`
+			Message string                 `json:"message"`
+			Data    map[string]interface{} `json:"data"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		if idFloat, ok := response.Data["id"].(float64); ok {
+			pocketID = int(idFloat)
+		}
+	})
+
+	// Test 15: Move Funds Into Pocket
+	t.Run("DepositToPocket", func(t *testing.T) {
+		moveReq := map[string]interface{}{
+			"amount": 10.00,
+		}
+		body, _ := json.Marshal(moveReq)
+
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/wallets/%d/pockets/%d/deposit", userID1, pocketID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Code    int                `json:"code"`
+			Message string             `json:"message"`
+			Data    map[string]float64 `json:"data"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, 10.00, response.Data["pocket_balance"])
+	})
+
+	// Test 16: Move More Than Pocket Holds (should fail)
+	t.Run("WithdrawFromPocketInsufficient", func(t *testing.T) {
+		moveReq := map[string]interface{}{
+			"amount": 1000.00,
+		}
+		body, _ := json.Marshal(moveReq)
+
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/wallets/%d/pockets/%d/withdraw", userID1, pocketID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test 17: Balance Includes Pockets
+	t.Run("GetBalanceWithPockets", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/wallets/%d/balance", userID1), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Code    int                    `json:"code"`
+			Message string                 `json:"message"`
+			Data    map[string]interface{} `json:"data"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		balance, _ := response.Data["balance"].(float64)
+		pocketsBalance, _ := response.Data["pockets_balance"].(float64)
+		total, _ := response.Data["total"].(float64)
+		assert.Equal(t, 10.00, pocketsBalance)
+		assert.InDelta(t, balance+pocketsBalance, total, 0.001)
+	})
+
+	// Test 18: Create Shared Wallet With Approval Rule
+	var sharedWalletID int
+	t.Run("CreateSharedWallet", func(t *testing.T) {
+		createReq := map[string]interface{}{
+			"name":               "family",
+			"approval_threshold": 5.00,
+			"required_approvals": 1,
+		}
+		body, _ := json.Marshal(createReq)
+
+		// The acting user must be authenticated, X-User-ID alone is not enough
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/shared-wallets", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-ID", strconv.Itoa(userID1))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/v1/shared-wallets", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		asUser(req, userID1)
+
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response struct {
+			Code    int                    `json:"code"`
+			Message string                 `json:"message"`
+			Data    map[string]interface{} `json:"data"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		if idFloat, ok := response.Data["id"].(float64); ok {
+			sharedWalletID = int(idFloat)
+		}
+	})
+
+	// Test 19: Add Spender And Fund Shared Wallet
+	t.Run("FundSharedWallet", func(t *testing.T) {
+		memberReq := map[string]interface{}{
+			"member_user_id": userID2,
+			"role":           "spender",
+		}
+		body, _ := json.Marshal(memberReq)
+
+		// A non-member cannot add themselves
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/shared-wallets/%d/members", sharedWalletID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		asUser(req, userID2)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		req = httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/shared-wallets/%d/members", sharedWalletID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		asUser(req, userID1)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// Two approvals cannot be met with one other member able to approve
+		body, _ = json.Marshal(map[string]interface{}{"approval_threshold": 5.00, "required_approvals": 2})
+		req = httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/shared-wallets/%d/rule", sharedWalletID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		asUser(req, userID1)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		depositReq := map[string]interface{}{
+			"amount": 10.00,
+		}
+		body, _ = json.Marshal(depositReq)
+
+		req = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/shared-wallets/%d/deposit", sharedWalletID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		asUser(req, userID1)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	// Test 20: Withdrawal Above Threshold Waits For Approval
+	t.Run("SharedWithdrawNeedsApproval", func(t *testing.T) {
+		withdrawReq := map[string]interface{}{
+			"amount": 8.00,
+		}
+		body, _ := json.Marshal(withdrawReq)
+
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/shared-wallets/%d/withdraw", sharedWalletID), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		asUser(req, userID1)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		var response struct {
+			Code    int                    `json:"code"`
+			Message string                 `json:"message"`
+			Data    map[string]interface{} `json:"data"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "pending", response.Data["status"])
+		opID, _ := response.Data["id"].(float64)
+
+		// The requester cannot approve as another member by naming them
+		approvePath := fmt.Sprintf("/api/v1/shared-wallets/%d/operations/%d/approve", sharedWalletID, int(opID))
+		body, _ = json.Marshal(map[string]interface{}{"user_id": userID2})
+		req = httptest.NewRequest(http.MethodPost, approvePath, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		asUser(req, userID1)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		req = httptest.NewRequest(http.MethodPost, approvePath, nil)
+		asUser(req, userID2)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "executed", response.Data["status"])
+	})
+
+	// Test 21: Withdraw Into Overdraft
+	t.Run("WithdrawWithOverdraft", func(t *testing.T) {
+		cfg.Auth.AdminToken = "test-admin-token"
+		overdraftReq := map[string]interface{}{
+			"limit": 50.00,
+		}
+		body, _ := json.Marshal(overdraftReq)
+
+		// Credit lines are granted by admins only
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/wallets/%d/overdraft", userID2), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		req = httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/wallets/%d/overdraft", userID2), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Admin-Token", cfg.Auth.AdminToken)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/wallets/%d/balance", userID2), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var response struct {
+			Code    int                    `json:"code"`
+			Message string                 `json:"message"`
+			Data    map[string]interface{} `json:"data"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		balance, _ := response.Data["balance"].(float64)
+
+		withdrawReq := map[string]interface{}{
+			"amount":      balance + 10.00,
+			"description": "Overdraft withdrawal",
+		}
+		body, _ = json.Marshal(withdrawReq)
+
+		req = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/wallets/%d/withdraw", userID2), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/wallets/%d/balance", userID2), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.InDelta(t, 10.00, response.Data["credit_used"], 0.001)
+		assert.InDelta(t, 40.00, response.Data["credit_available"], 0.001)
+	})
+
+	// Test 22: Repeated Interest Accrual For The Same Day Is A No-op
+	t.Run("InterestAccrualIdempotent", func(t *testing.T) {
+		day := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+		body, _ := json.Marshal(map[string]string{"date": day})
+
+		// Interest runs are started by admins only
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/interest/accrue", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		accrued := make([]float64, 0, 2)
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/interest/accrue", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Admin-Token", cfg.Auth.AdminToken)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response struct {
+				Code    int                `json:"code"`
+				Message string             `json:"message"`
+				Data    map[string]float64 `json:"data"`
+			}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			accrued = append(accrued, response.Data["accrued"])
+		}
+		assert.Equal(t, 0.0, accrued[1])
+
+		req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/wallets/%d/interest", userID1), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	// Test 23: Quote And Exchange Between Currency Wallets
+	t.Run("ExchangeCurrency", func(t *testing.T) {
+		quoteReq := map[string]interface{}{
+			"user_id":       userID1,
+			"from_currency": "USD",
+			"to_currency":   "EUR",
+		}
+		body, _ := json.Marshal(quoteReq)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/fx/quotes", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response struct {
+			Code    int                    `json:"code"`
+			Message string                 `json:"message"`
+			Data    map[string]interface{} `json:"data"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		quoteID, _ := response.Data["id"].(float64)
+
+		exchangeReq := map[string]interface{}{
+			"user_id":  userID1,
+			"quote_id": int(quoteID),
+			"amount":   5.00,
+		}
+		body, _ = json.Marshal(exchangeReq)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/v1/fx/exchange", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// A quote can only be used once
+		req = httptest.NewRequest(http.MethodPost, "/api/v1/fx/exchange", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/wallets/%d/balance?currency=EUR", userID1), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "EUR", response.Data["currency"])
+		balance, _ := response.Data["balance"].(float64)
+		assert.Greater(t, balance, 0.0)
+	})
+
+	// Test 24: Transaction History With Filters And Cursor Pagination
+	t.Run("GetUserTransactionsCursor", func(t *testing.T) {
+		type transactionPage struct {
+			Items      []map[string]interface{} `json:"items"`
+			Total      int                      `json:"total"`
+			HasMore    bool                     `json:"has_more"`
+			NextCursor string                   `json:"next_cursor"`
+		}
+		var response struct {
+			Code    int             `json:"code"`
+			Message string          `json:"message"`
+			Data    transactionPage `json:"data"`
+		}
+
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/users/%d/transactions?limit=2&direction=out", userID1), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		first := response.Data
+		assert.LessOrEqual(t, len(first.Items), 2)
+		for _, item := range first.Items {
+			assert.Equal(t, float64(userID1), item["from_user_id"])
+		}
+
+		if first.HasMore {
+			req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/users/%d/transactions?limit=2&direction=out&cursor=%s", userID1, first.NextCursor), nil)
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			err = json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, response.Data.Items)
+			assert.NotEqual(t, first.Items[0]["id"], response.Data.Items[0]["id"])
+		}
+
+		for _, query := range []string{"limit=0", "limit=-1", "page=0", "direction=sideways", "cursor=%%%", "min_amount=5&max_amount=1"} {
+			req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/users/%d/transactions?%s", userID1, query), nil)
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
+	// Test 25: Transaction Detail Is Limited To Its Parties
+	t.Run("GetTransactionDetail", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/users/%d/transactions?type=transfer&direction=out&counterparty_id=%d&limit=1", userID1, userID2), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var listResponse struct {
+			Code int `json:"code"`
+			Data struct {
+				Items []map[string]interface{} `json:"items"`
+			} `json:"data"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &listResponse)
+		assert.NoError(t, err)
+		if !assert.NotEmpty(t, listResponse.Data.Items) {
+			return
+		}
+		path := fmt.Sprintf("/api/v1/transactions/%d", int(listResponse.Data.Items[0]["id"].(float64)))
+		get := func(path string, headers ...string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			for i := 0; i+1 < len(headers); i += 2 {
+				req.Header.Set(headers[i], headers[i+1])
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		// X-User-ID alone is not trusted, admins see every transaction
+		assert.Equal(t, http.StatusUnauthorized, get(path, "X-User-ID", strconv.Itoa(userID1)).Code)
+		assert.Equal(t, http.StatusUnauthorized, get(path).Code)
+		assert.Equal(t, http.StatusOK, get(path, "X-Admin-Token", cfg.Auth.AdminToken).Code)
+
+		// An API client sees the transactions of the users it registered
+		apiClientService := service.NewApiClientService()
+		_, apiKey, err := apiClientService.CreateClient("Detail viewer")
+		assert.NoError(t, err)
+		_, otherKey, err := apiClientService.CreateClient("Other client")
+		assert.NoError(t, err)
+
+		body, _ := json.Marshal(map[string]string{"username": "Detail Viewer", "email": fmt.Sprintf("viewer%d@example.com", time.Now().UnixNano())})
+		req = httptest.NewRequest(http.MethodPost, "/api/v1/users", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", apiKey)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var registerResponse struct {
+			Data struct {
+				User struct {
+					ID int `json:"id"`
+				} `json:"user"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &registerResponse))
+		clientUserID := registerResponse.Data.User.ID
+
+		_, _, err = service.NewWalletService().Transfer(userID1, clientUserID, "", 1, "Detail test")
+		if !assert.NoError(t, err) {
+			return
+		}
+		req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/users/%d/transactions?limit=1", clientUserID), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listResponse))
+		if !assert.NotEmpty(t, listResponse.Data.Items) {
+			return
+		}
+		transactionID := int(listResponse.Data.Items[0]["id"].(float64))
+		path = fmt.Sprintf("/api/v1/transactions/%d", transactionID)
+
+		w = get(path, "X-API-Key", apiKey, "X-User-ID", strconv.Itoa(clientUserID))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Code int `json:"code"`
+			Data struct {
+				StatusHistory []map[string]interface{} `json:"status_history"`
+				Counterparty  map[string]interface{}   `json:"counterparty"`
+			} `json:"data"`
+		}
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, response.Data.StatusHistory)
+		assert.Equal(t, float64(userID1), response.Data.Counterparty["id"])
+		assert.NotEmpty(t, response.Data.Counterparty["username"])
+		assert.Equal(t, http.StatusOK, get(path, "X-API-Key", apiKey).Code)
+
+		// The client cannot act for users it did not register, nor see their transactions
+		assert.Equal(t, http.StatusForbidden, get(path, "X-API-Key", apiKey, "X-User-ID", strconv.Itoa(userID1)).Code)
+		assert.Equal(t, http.StatusForbidden, get(path, "X-API-Key", otherKey).Code)
+
+		// A user authenticated by the gateway sees the transactions they are a party to
+		w = get(path, "X-Gateway-Token", cfg.Auth.GatewayToken, "X-User-ID", strconv.Itoa(userID1))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, float64(clientUserID), response.Data.Counterparty["id"])
+		assert.Equal(t, http.StatusForbidden, get(path, "X-Gateway-Token", cfg.Auth.GatewayToken, "X-User-ID", strconv.Itoa(userID2)).Code)
+		assert.Equal(t, http.StatusUnauthorized, get(path, "X-Gateway-Token", "wrong", "X-User-ID", strconv.Itoa(userID1)).Code)
+
+		// The interim detail path still answers, marked deprecated
+		w = get(fmt.Sprintf("/api/v1/transaction-details/%d", transactionID), "X-Admin-Token", cfg.Auth.AdminToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "true", w.Header().Get("Deprecation"))
+		assert.Equal(t, fmt.Sprintf(`</api/v1/transactions/%d>; rel="successor-version"`, transactionID), w.Header().Get("Link"))
+	})
+
+	// Test 26: Wallet Statement In Each Format
+	t.Run("GetStatement", func(t *testing.T) {
+		from := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+		to := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/wallets/%d/statement?from=%s&to=%s", userID1, from, to), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Code int                    `json:"code"`
+			Data map[string]interface{} `json:"data"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, response.Data["lines"])
+		assert.Contains(t, response.Data, "opening_balance")
+		assert.Contains(t, response.Data, "closing_balance")
+
+		for format, contentType := range map[string]string{"csv": "text/csv", "pdf": "application/pdf"} {
+			req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/wallets/%d/statement?from=%s&to=%s&format=%s", userID1, from, to, format), nil)
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code, format)
+			assert.Contains(t, w.Header().Get("Content-Type"), contentType)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/wallets/%d/statement?from=%s&to=%s&format=xml", userID1, from, to), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test 27: Balance As Of A Past Time
+	t.Run("GetBalanceAsOf", func(t *testing.T) {
+		var response struct {
+			Code int                    `json:"code"`
+			Data map[string]interface{} `json:"data"`
+		}
+
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/wallets/%d/balance", userID1), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		current := response.Data["balance"]
+
+		req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/wallets/%d/balance/as-of?at=%s", userID1, time.Now().Add(time.Minute).Format(time.RFC3339)), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, current, response.Data["balance"])
+
+		// Before the user existed the balance was zero
+		req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/wallets/%d/balance/as-of?at=2000-01-01", userID1), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, 0.0, response.Data["balance"])
+
+		req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/wallets/%d/balance/as-of?at=yesterday", userID1), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test 28: Reconciliation And Wallet Freeze Require Admin
+	t.Run("Reconciliation", func(t *testing.T) {
+		cfg.Auth.AdminToken = "test-admin-token"
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/reconciliation/runs", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/v1/reconciliation/runs", nil)
+		req.Header.Set("X-Admin-Token", cfg.Auth.AdminToken)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Code int `json:"code"`
+			Data struct {
+				Run        map[string]interface{}   `json:"run"`
+				Invariants []map[string]interface{} `json:"invariants"`
+			} `json:"data"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.NotZero(t, response.Data.Run["id"])
+		assert.NotEmpty(t, response.Data.Invariants)
+
+		req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/reconciliation/runs/%d", int(response.Data.Run["id"].(float64))), nil)
+		req.Header.Set("X-Admin-Token", cfg.Auth.AdminToken)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// A frozen wallet rejects withdrawals until it is unfrozen
+		freeze := func(frozen bool) {
+			body, _ := json.Marshal(map[string]interface{}{"frozen": frozen})
+			req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("/api/v1/wallets/%d/freeze", userID2), bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Admin-Token", cfg.Auth.AdminToken)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+		withdraw := func() int {
+			body, _ := json.Marshal(map[string]interface{}{"amount": 1.00, "description": "Frozen check"})
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/wallets/%d/withdraw", userID2), bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		freeze(true)
+		assert.Equal(t, http.StatusForbidden, withdraw())
+		freeze(false)
+		assert.Equal(t, http.StatusOK, withdraw())
+	})
+
+	// Test 29: Transaction Hash Chain Verifies
+	t.Run("VerifyTransactionChain", func(t *testing.T) {
+		// Transactions are chained after they commit, by the transaction-chain job
+		var unchained int64
+		config.GetDB().Unscoped().Model(&models.Transaction{}).Where("seq = 0").Count(&unchained)
+		assert.NotZero(t, unchained)
+		appended, err := service.NewChainService().Append(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, int(unchained), appended)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/reconciliation/chain", nil)
+		req.Header.Set("X-Admin-Token", cfg.Auth.AdminToken)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Code int                    `json:"code"`
+			Data map[string]interface{} `json:"data"`
+		}
+		err = json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, true, response.Data["ok"])
+		assert.NotZero(t, response.Data["checked"])
+		assert.Equal(t, float64(0), response.Data["unchained"])
+	})
+
+	// Test 30: Outbox Relay Publishes Domain Events
+	t.Run("OutboxRelay", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{"amount": 3.00, "description": "Outbox check"})
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/wallets/%d/deposit", userID1), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var published []events.Event
+		relay := events.NewRelay([]events.Sink{events.SinkFunc(func(ctx context.Context, event events.Event) error {
+			published = append(published, event)
+			return nil
+		})}, cfg.Outbox.BatchSize, cfg.Outbox.MaxBackoff)
+		assert.NoError(t, relay.Run(context.Background()))
+
+		found := false
+		for _, event := range published {
+			if event.Type != events.Deposited {
+				continue
+			}
+			var data events.BalanceChangedData
+			assert.NoError(t, json.Unmarshal(event.Data, &data))
+			if data.UserID == userID1 && data.Description == "Outbox check" {
+				found = true
+			}
+		}
+		assert.True(t, found)
+
+		// Published events are not delivered again
+		published = nil
+		assert.NoError(t, relay.Run(context.Background()))
+		assert.Empty(t, published)
+	})
+
+	// Test 31: Signed Webhooks With Retries, Dead Letters and Replay
+	t.Run("Webhooks", func(t *testing.T) {
+		var received []*http.Request
+		var bodies [][]byte
+		status := http.StatusOK
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received = append(received, r)
+			bodies = append(bodies, body)
+			w.WriteHeader(status)
+		}))
+		defer server.Close()
+
+		send := func(method, path string, payload interface{}, header, value string) (int, map[string]interface{}) {
+			var body io.Reader
+			if payload != nil {
+				data, _ := json.Marshal(payload)
+				body = bytes.NewBuffer(data)
+			}
+			req := httptest.NewRequest(method, path, body)
+			req.Header.Set("Content-Type", "application/json")
+			if header != "" {
+				req.Header.Set(header, value)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			var response struct {
+				Data map[string]interface{} `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			return w.Code, response.Data
+		}
+		list := func(path, apiKey string) []map[string]interface{} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("X-API-Key", apiKey)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response struct {
+				Data []map[string]interface{} `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			return response.Data
+		}
+
+		code, data := send(http.MethodPost, "/api/v1/clients", map[string]string{"name": "Partner"}, "X-Admin-Token", cfg.Auth.AdminToken)
+		assert.Equal(t, http.StatusCreated, code)
+		apiKey, _ := data["api_key"].(string)
+		assert.NotEmpty(t, apiKey)
+
+		code, _ = send(http.MethodGet, "/api/v1/webhooks/subscriptions", nil, "X-API-Key", "wk_invalid")
+		assert.Equal(t, http.StatusUnauthorized, code)
+
+		code, data = send(http.MethodPost, "/api/v1/users", map[string]string{"username": "Partner User", "email": "partner@example.com"}, "X-API-Key", apiKey)
+		assert.Equal(t, http.StatusCreated, code)
+		partnerUserID := int(data["user"].(map[string]interface{})["id"].(float64))
+
+		code, _ = send(http.MethodPost, "/api/v1/webhooks/subscriptions", map[string]interface{}{"url": server.URL, "event_types": []string{"Nope"}}, "X-API-Key", apiKey)
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		// Internal callback addresses are refused unless private networks are allowed
+		code, _ = send(http.MethodPost, "/api/v1/webhooks/subscriptions", map[string]interface{}{"url": server.URL}, "X-API-Key", apiKey)
+		assert.Equal(t, http.StatusBadRequest, code)
+		code, _ = send(http.MethodPost, "/api/v1/webhooks/subscriptions", map[string]interface{}{"url": "http://169.254.169.254/latest/meta-data"}, "X-API-Key", apiKey)
+		assert.Equal(t, http.StatusBadRequest, code)
+		cfg.Webhook.AllowPrivateNetworks = true
+		defer func() { cfg.Webhook.AllowPrivateNetworks = false }()
+
+		code, data = send(http.MethodPost, "/api/v1/webhooks/subscriptions", map[string]interface{}{"url": server.URL, "event_types": []string{events.Deposited}}, "X-API-Key", apiKey)
+		assert.Equal(t, http.StatusCreated, code)
+		secret, _ := data["secret"].(string)
+		subscriptionID := int(data["subscription"].(map[string]interface{})["id"].(float64))
+
+		code, _ = send(http.MethodPost, fmt.Sprintf("/api/v1/wallets/%d/deposit", partnerUserID), map[string]interface{}{"amount": 25.00}, "", "")
+		assert.Equal(t, http.StatusOK, code)
+
+		// The relay queues the deposit for the subscription, then the dispatcher sends it
+		webhooks := service.NewWebhookService()
+		relay := events.NewRelay([]events.Sink{webhooks}, cfg.Outbox.BatchSize, cfg.Outbox.MaxBackoff)
+		assert.NoError(t, relay.Run(context.Background()))
+		_, err := webhooks.Dispatch(context.Background())
+		assert.NoError(t, err)
+
+		if assert.Len(t, received, 1) {
+			assert.Equal(t, events.Deposited, received[0].Header.Get("X-Webhook-Event-Type"))
+			assert.NoError(t, webhook.Verify(secret, received[0].Header.Get("X-Webhook-Signature"), bodies[0], time.Now(), 5*time.Minute))
+		}
+
+		deliveriesPath := fmt.Sprintf("/api/v1/webhooks/subscriptions/%d/deliveries", subscriptionID)
+		deliveries := list(deliveriesPath, apiKey)
+		if !assert.Len(t, deliveries, 1) {
+			return
+		}
+		assert.Equal(t, "succeeded", deliveries[0]["status"])
+		eventID := deliveries[0]["event_id"].(string)
+
+		// Replaying sends the event again; when the partner keeps failing the
+		// delivery is dead-lettered and the subscription paused
+		replayPath := fmt.Sprintf("/api/v1/webhooks/subscriptions/%d/replay", subscriptionID)
+		status = http.StatusInternalServerError
+		maxAttempts := cfg.Webhook.MaxAttempts
+		cfg.Webhook.MaxAttempts = 1
+		defer func() { cfg.Webhook.MaxAttempts = maxAttempts }()
+
+		code, _ = send(http.MethodPost, replayPath, map[string]string{"event_id": eventID}, "X-API-Key", apiKey)
+		assert.Equal(t, http.StatusAccepted, code)
+		_, err = webhooks.Dispatch(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, received, 2)
+		assert.Len(t, list(deliveriesPath+"?status=dead", apiKey), 1)
+
+		code, data = send(http.MethodGet, fmt.Sprintf("/api/v1/webhooks/subscriptions/%d", subscriptionID), nil, "X-API-Key", apiKey)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "dead_letter", data["status"])
+
+		code, data = send(http.MethodGet, fmt.Sprintf("/api/v1/webhooks/deliveries/%d", int(deliveries[0]["id"].(float64))), nil, "X-API-Key", apiKey)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Len(t, data["attempt_log"], 2)
+
+		// After reactivating, a replay is delivered again
+		status = http.StatusOK
+		code, _ = send(http.MethodPut, fmt.Sprintf("/api/v1/webhooks/subscriptions/%d", subscriptionID), map[string]interface{}{"active": true}, "X-API-Key", apiKey)
+		assert.Equal(t, http.StatusOK, code)
+		code, _ = send(http.MethodPost, replayPath, map[string]string{"event_id": eventID}, "X-API-Key", apiKey)
+		assert.Equal(t, http.StatusAccepted, code)
+		_, err = webhooks.Dispatch(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, received, 3)
+		assert.Len(t, list(deliveriesPath+"?status=succeeded", apiKey), 1)
+	})
+
+	// Test 32: Streaming Updates With Resume From Last-Event-ID
+	t.Run("StreamUpdates", func(t *testing.T) {
+		hub := stream.DefaultHub()
+		assert.NoError(t, hub.Poll(context.Background()))
+
+		server := httptest.NewServer(r)
+		defer server.Close()
+
+		connect := func(ctx context.Context, lastEventID string) (*http.Response, *bufio.Reader) {
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/stream", nil)
+			asUser(req, userID1)
+			if lastEventID != "" {
+				req.Header.Set("Last-Event-ID", lastEventID)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+			return resp, bufio.NewReader(resp.Body)
+		}
+		// next reads up to the next update, skipping the retry field and heartbeats
+		next := func(reader *bufio.Reader) (string, stream.Update) {
+			var id string
+			var update stream.Update
+			for {
+				line, err := reader.ReadString('\n')
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				line = strings.TrimRight(line, "\n")
+				switch {
+				case strings.HasPrefix(line, "id: "):
+					id = strings.TrimPrefix(line, "id: ")
+				case strings.HasPrefix(line, "data: "):
+					assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &update))
+				case line == "" && id != "":
+					return id, update
+				}
+			}
+		}
+		deposit := func(amount float64) {
+			body, _ := json.Marshal(map[string]interface{}{"amount": amount, "description": "Stream check"})
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/wallets/%d/deposit", userID1), bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/stream", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// X-User-ID alone does not open another user's stream
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/stream", nil)
+		req.Header.Set("X-User-ID", strconv.Itoa(userID1))
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		// A live update is pushed once the hub picks up the committed event
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_, reader := connect(ctx, "")
+		deposit(4.00)
+		assert.NoError(t, hub.Poll(context.Background()))
+		lastID, update := next(reader)
+		assert.Equal(t, events.Deposited, update.Type)
+		if assert.Len(t, update.Transactions, 1) {
+			assert.Equal(t, 4.00, update.Transactions[0].Amount)
+		}
+		assert.Len(t, update.Balances, 1)
+		cancel()
+
+		// Reconnecting with the last event ID replays what happened while disconnected
+		deposit(6.00)
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, reader = connect(ctx, lastID)
+		id, update := next(reader)
+		assert.NotEqual(t, lastID, id)
+		assert.Equal(t, events.Deposited, update.Type)
+		if assert.Len(t, update.Transactions, 1) {
+			assert.Equal(t, 6.00, update.Transactions[0].Amount)
+		}
+	})
+
+	// Test 33: Event-Sourced Wallets Rebuilt By Replay
+	t.Run("EventStoreReplay", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{"amount": 12.50, "description": "Replay check"})
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/wallets/%d/deposit", userID2), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// The deposit is projected into the wallet's entries
+		req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/wallets/%d/entries?limit=1", userID2), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var entries struct {
+			Data []models.WalletEntries `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+		if assert.Len(t, entries.Data, 1) {
+			assert.Equal(t, 12.50, entries.Data[0].Amount)
+			assert.Equal(t, "deposit", entries.Data[0].TransactionType)
+		}
+
+		var wallet models.Wallets
+		assert.NoError(t, config.GetDB().Where("user_id = ? AND currency = ?", userID2, cfg.Wallet.DefaultCurrency).First(&wallet).Error)
+		replay := service.NewReplayService()
+
+		// The stored wallet agrees with its event stream
+		report, err := replay.Rebuild(service.ReplayOptions{WalletID: wallet.ID, FromScratch: true})
+		assert.NoError(t, err)
+		assert.Empty(t, report.Failures)
+		assert.Empty(t, report.Mismatches)
+
+		// A balance changed behind the event store's back is restored by replay
+		assert.NoError(t, config.GetDB().Model(&wallet).UpdateColumn("balance", wallet.Balance+100).Error)
+		report, err = replay.Rebuild(service.ReplayOptions{WalletID: wallet.ID, DryRun: true})
+		assert.NoError(t, err)
+		if assert.Len(t, report.Mismatches, 1) {
+			assert.Equal(t, "balance", report.Mismatches[0].Field)
+		}
+		report, err = replay.Rebuild(service.ReplayOptions{WalletID: wallet.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Fixed)
+
+		var restored models.Wallets
+		assert.NoError(t, config.GetDB().First(&restored, wallet.ID).Error)
+		assert.Equal(t, wallet.Balance, restored.Balance)
+	})
+
+	// Test 34: Liveness And Readiness Probes
+	t.Run("Probes", func(t *testing.T) {
+		defer health.SetState(health.Ready)
+		checks := health.DefaultRegistry()
+		checks.Register("database", func(ctx context.Context) (interface{}, error) {
+			return nil, config.PingDB(ctx)
+		})
+		checks.Register("migrations", func(ctx context.Context) (interface{}, error) {
+			missing, err := config.PendingMigrations(ctx)
+			if err == nil && len(missing) > 0 {
+				err = fmt.Errorf("missing columns %v", missing)
+			}
+			return nil, err
+		})
+
+		probe := func(path string) (int, health.Report) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			var report health.Report
+			json.Unmarshal(w.Body.Bytes(), &report)
+			return w.Code, report
+		}
+
+		code, report := probe("/readyz")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ok", report.Status)
+		assert.Equal(t, "ok", report.Checks["database"].Status)
+		assert.Equal(t, "ok", report.Checks["migrations"].Status)
+
+		// Not ready while starting: probes answer, the API is turned away
+		health.SetState(health.Starting)
+		code, report = probe("/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "starting", report.Status)
+		code, _ = probe("/livez")
+		assert.Equal(t, http.StatusOK, code)
+		code, _ = probe("/api/v1/users")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+
+		// Not ready while stopping, but requests are still served while draining
+		health.SetState(health.Stopping)
+		code, report = probe("/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "stopping", report.Status)
+		code, _ = probe("/api/v1/users")
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	// Test 35: Metrics
+	t.Run("Metrics", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{"amount": 12.5, "description": "metrics"})
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/wallets/%d/deposit", userID1), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `wallet_movements_total{currency="USD",outcome="success",type="deposit"}`)
+		assert.Contains(t, w.Body.String(), `wallet_http_requests_total{method="POST",route="/api/v1/wallets/:user_id/deposit",status="200"}`)
+		assert.Contains(t, w.Body.String(), "wallet_lock_wait_seconds_count{operation=\"deposit\"}")
+	})
+
+	// Test 36: Tracing
+	t.Run("Tracing", func(t *testing.T) {
+		recorder := recordSpans()
+		assert.NoError(t, config.GetDB().Use(tracing.GormPlugin{}))
+
+		body, _ := json.Marshal(map[string]interface{}{"amount": 3.5, "description": "traced"})
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/wallets/%d/deposit", userID1), bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		spans := recorder.Ended()
+		server := findSpan(spans, "POST /api/v1/wallets/:user_id/deposit")
+		deposit := findSpan(spans, "WalletService.Deposit")
+		if assert.NotNil(t, server) && assert.NotNil(t, deposit) {
+			assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", server.SpanContext().TraceID().String())
+			assert.Equal(t, server.SpanContext().SpanID(), deposit.Parent().SpanID())
+
+			// Every statement of the deposit is a child of its service span
+			statements := 0
+			for _, span := range spans {
+				if span.Parent().SpanID() == deposit.SpanContext().SpanID() {
+					statements++
+				}
+			}
+			assert.NotNil(t, findSpan(spans, "INSERT transaction"))
+			assert.GreaterOrEqual(t, statements, 3)
+		}
+	})
+
+	// Test 37: Log level
+	t.Run("LogLevel", func(t *testing.T) {
+		setLevel := func(level string, headers ...string) int {
+			body, _ := json.Marshal(map[string]string{"level": level})
+			req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/log-level", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			for i := 0; i+1 < len(headers); i += 2 {
+				req.Header.Set(headers[i], headers[i+1])
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+		previous := config.GetLogger().Level()
+		defer config.GetLogger().SetLevel(previous)
+
+		assert.Equal(t, http.StatusForbidden, setLevel(config.LevelWarn))
+		assert.Equal(t, http.StatusBadRequest, setLevel("verbose", "X-Admin-Token", cfg.Auth.AdminToken))
+		assert.Equal(t, http.StatusOK, setLevel(config.LevelWarn, "X-Admin-Token", cfg.Auth.AdminToken))
+		assert.Equal(t, config.LevelWarn, config.GetLogger().Level())
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/log-level", nil)
+		req.Header.Set("X-Admin-Token", cfg.Auth.AdminToken)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"level":"warn"`)
+	})
+
+	// Test 38: Effective config
+	t.Run("EffectiveConfig", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/config", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/admin/config", nil)
+		req.Header.Set("X-Admin-Token", cfg.Auth.AdminToken)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "admin_token: '******'")
+		assert.NotContains(t, w.Body.String(), cfg.Auth.AdminToken)
+	})
+
+	// Test 39: Rate limit headers
+	t.Run("RateLimitHeaders", func(t *testing.T) {
+		if !cfg.RateLimit.Enabled {
+			t.Skip("rate limiting disabled")
+		}
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/v1/wallets/%d/balance", userID1), nil)
+		req.Header.Set("X-User-ID", strconv.Itoa(userID1))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, strconv.Itoa(cfg.RateLimit.Read.Burst), w.Header().Get("X-RateLimit-Limit"))
+		assert.NotEmpty(t, w.Header().Get("X-RateLimit-Remaining"))
+		assert.NotEmpty(t, w.Header().Get("X-RateLimit-Reset"))
+	})
+
+	// Test 40: Transfers in opposite directions run concurrently without deadlocking
+	t.Run("ConcurrentOppositeTransfers", func(t *testing.T) {
+		walletService := service.NewWalletService()
+		_, err := walletService.Deposit(userID1, "", 10, "Concurrency test")
+		assert.NoError(t, err)
+		_, err = walletService.Deposit(userID2, "", 10, "Concurrency test")
+		assert.NoError(t, err)
+
+		errs := make(chan error, 20)
+		for i := 0; i < 10; i++ {
+			go func() {
+				_, _, err := walletService.Transfer(userID1, userID2, "", 0.5, "Concurrency test")
+				errs <- err
+			}()
+			go func() {
+				_, _, err := walletService.Transfer(userID2, userID1, "", 0.5, "Concurrency test")
+				errs <- err
+			}()
+		}
+		for i := 0; i < 20; i++ {
+			assert.NoError(t, <-errs)
+		}
+	})
+
+	// Test 41: Events of a shared wallet spend carry its operation
+	t.Run("SharedSpendEventOperation", func(t *testing.T) {
+		sharedWalletService := service.NewSharedWalletService()
+		sharedWallet, err := sharedWalletService.CreateSharedWallet(userID1, "Event check", 0, 0)
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, err = sharedWalletService.Deposit(sharedWallet.ID, userID1, 5, "Event check")
+		assert.NoError(t, err)
+		op, err := sharedWalletService.RequestWithdraw(sharedWallet.ID, userID1, 2, "Event check")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "executed", op.Status)
+		assert.NotZero(t, op.ID)
+
+		var row models.OutboxEvents
+		err = config.GetDB().Where("type = ?", events.SharedWalletSpent).Order("id DESC").First(&row).Error
+		if !assert.NoError(t, err) {
+			return
+		}
+		var data events.SharedWalletMovedData
+		assert.NoError(t, json.Unmarshal(events.FromOutbox(&row).Data, &data))
+		assert.Equal(t, sharedWallet.ID, data.SharedWalletID)
+		assert.Equal(t, op.ID, data.OperationID)
+	})
+
+	// Test 42: Interest payouts skip wallets the house cannot pay, and need no house user
+	t.Run("InterestPayoutWithoutHouse", func(t *testing.T) {
+		interestConf, houseUserID := cfg.Interest, cfg.Wallet.HouseUserID
+		defer func() { cfg.Interest, cfg.Wallet.HouseUserID = interestConf, houseUserID }()
+		cfg.Interest = config.InterestConf{Enabled: true, Tiers: []config.InterestTier{{MinBalance: 0, AnnualRate: 0.01}}}
+
+		userService := service.NewUserService()
+		house, _, err := userService.RegisterUser("House", fmt.Sprintf("house%d@example.com", time.Now().UnixNano()), 0)
+		if !assert.NoError(t, err) {
+			return
+		}
+		saver, _, err := userService.RegisterUser("Saver", fmt.Sprintf("saver%d@example.com", time.Now().UnixNano()), 0)
+		if !assert.NoError(t, err) {
+			return
+		}
+		_, err = service.NewWalletService().Deposit(saver.ID, "", 1000, "Interest check")
+		assert.NoError(t, err)
+
+		interestService := service.NewInterestService()
+		_, err = interestService.Accrue(time.Now())
+		assert.NoError(t, err)
+
+		// The empty house wallet cannot pay; the run carries on without paying anyone
+		cfg.Wallet.HouseUserID = house.ID
+		paid, err := interestService.Payout(time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, paid)
+
+		// Without a house user the interest is paid from no wallet
+		cfg.Wallet.HouseUserID = 0
+		paid, err = interestService.Payout(time.Now())
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, paid, 1)
+
+		history, err := interestService.GetInterestHistory(saver.ID, "", 10)
+		if assert.NoError(t, err) && assert.Len(t, history.Payouts, 1) {
+			assert.Equal(t, 0.03, history.Payouts[0].Amount)
+		}
+	})
 }
@@ -0,0 +1,50 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"wallet/models"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestTransactionHash tests that the chain hash covers the content and the previous link
+func TestTransactionHash(t *testing.T) {
+	base := models.Transaction{
+		Seq:         2,
+		Type:        "transfer",
+		FromUserID:  1,
+		ToUserID:    2,
+		Amount:      10.5,
+		Currency:    "USD",
+		Description: "rent",
+		Status:      "completed",
+		CreatedAt:   time.UnixMilli(1767225600123),
+		PrevHash:    "0f1e",
+	}
+	hash := base.ComputeHash()
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, base.ComputeHash())
+
+	// Row identity and soft deletes are not part of the hashed content
+	same := base
+	same.ID = 99
+	same.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	same.CreatedAt = base.CreatedAt.In(time.FixedZone("UTC+8", 8*3600))
+	assert.Equal(t, hash, same.ComputeHash())
+
+	for name, edit := range map[string]func(*models.Transaction){
+		"amount":      func(tx *models.Transaction) { tx.Amount = 10.51 },
+		"recipient":   func(tx *models.Transaction) { tx.ToUserID = 3 },
+		"description": func(tx *models.Transaction) { tx.Description = "rent " },
+		"created_at":  func(tx *models.Transaction) { tx.CreatedAt = tx.CreatedAt.Add(time.Millisecond) },
+		"prev_hash":   func(tx *models.Transaction) { tx.PrevHash = "0f1f" },
+		"seq":         func(tx *models.Transaction) { tx.Seq = 3 },
+	} {
+		edited := base
+		edit(&edited)
+		assert.NotEqual(t, hash, edited.ComputeHash(), name)
+	}
+}
@@ -0,0 +1,246 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wallet/config"
+	"wallet/controller"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfig writes a minimal valid config file and points CONFIG_PATH at it
+func writeConfig(t *testing.T, content string) string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	t.Setenv("CONFIG_PATH", path)
+	return dir
+}
+
+// TestConfigOverrides tests that env vars override the file, flags override env
+// vars, and secrets are read from *_FILE paths
+func TestConfigOverrides(t *testing.T) {
+	dir := writeConfig(t, `
+mysql:
+  host: db
+  port: 3306
+  db_name: wallet
+  user: wallet
+  password: from-file
+wallet:
+  currencies: [USD]
+`)
+	secret := filepath.Join(dir, "admin_token")
+	assert.NoError(t, os.WriteFile(secret, []byte("token-from-secret\n"), 0600))
+
+	t.Setenv("WALLET_MYSQL_PORT", "3307")
+	t.Setenv("WALLET_MYSQL_PASSWORD", "from-env")
+	t.Setenv("WALLET_HTTP_READ_TIMEOUT", "5s")
+	t.Setenv("WALLET_WALLET_CURRENCIES", "usd, eur")
+	t.Setenv("WALLET_FX_RATES", "{USD: 1, EUR: 0.9}")
+	t.Setenv("WALLET_AUTH_ADMIN_TOKEN_FILE", secret)
+	t.Cleanup(func() { config.ParseFlags(nil) })
+
+	args, err := config.ParseFlags([]string{"-mysql.port", "3308", "-tracing.insecure", "reconcile", "-freeze"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"reconcile", "-freeze"}, args)
+
+	if !assert.NoError(t, config.InitConfig()) {
+		return
+	}
+	conf := config.GetConf()
+	assert.Equal(t, "db", conf.MySQL.Host)
+	assert.Equal(t, 3308, conf.MySQL.Port)
+	assert.Equal(t, "from-env", conf.MySQL.Password)
+	assert.Equal(t, 5*time.Second, conf.Http.ReadTimeout)
+	assert.Equal(t, []string{"USD", "EUR"}, conf.Wallet.Currencies)
+	assert.Equal(t, map[string]float64{"USD": 1, "EUR": 0.9}, conf.FX.Rates)
+	assert.Equal(t, "token-from-secret", conf.Auth.AdminToken)
+	assert.True(t, conf.Tracing.Insecure)
+
+	// Invalid values and ambiguous secrets are rejected
+	t.Setenv("WALLET_MYSQL_PORT", "not-a-port")
+	config.ParseFlags(nil)
+	assert.ErrorContains(t, config.InitConfig(), "WALLET_MYSQL_PORT")
+	t.Setenv("WALLET_MYSQL_PORT", "3307")
+	t.Setenv("WALLET_AUTH_ADMIN_TOKEN", "token")
+	assert.ErrorContains(t, config.InitConfig(), "both WALLET_AUTH_ADMIN_TOKEN and WALLET_AUTH_ADMIN_TOKEN_FILE are set")
+}
+
+// TestConfigDump tests that the effective config dump masks secrets without
+// changing the config in use
+func TestConfigDump(t *testing.T) {
+	writeConfig(t, `
+mysql:
+  host: db
+  db_name: wallet
+  user: wallet
+  password: hunter2
+auth:
+  admin_token: ""
+`)
+	t.Setenv("WALLET_HTTP_PORT", "9100")
+	if !assert.NoError(t, config.InitConfig()) {
+		return
+	}
+
+	out, err := config.GetConf().Dump()
+	assert.NoError(t, err)
+	assert.Contains(t, string(out), "password: '******'")
+	assert.Contains(t, string(out), `admin_token: ""`)
+	assert.Contains(t, string(out), "port: 9100")
+	assert.NotContains(t, string(out), "hunter2")
+	assert.Equal(t, "hunter2", config.GetConf().MySQL.Password)
+}
+
+// TestConfigReload tests that a changed config file is applied atomically,
+// that restart-only changes keep their running values, and that subscribers
+// see the change
+func TestConfigReload(t *testing.T) {
+	var logs bytes.Buffer
+	config.SetLogger(config.NewLogger(&logs, config.LevelInfo))
+	dir := writeConfig(t, `
+http:
+  port: 8090
+mysql: {host: db, db_name: wallet, user: wallet}
+fx: {spread: 0.01}
+`)
+	path := filepath.Join(dir, "config.yaml")
+	if !assert.NoError(t, config.InitConfig()) {
+		return
+	}
+	before := config.GetConf()
+
+	var notified []float64
+	config.Subscribe(func(prev, next *config.Config) {
+		notified = append(notified, prev.FX.Spread, next.FX.Spread)
+	})
+
+	// Unchanged file
+	assert.NoError(t, config.ReloadIfChanged(context.Background()))
+	assert.Same(t, before, config.GetConf())
+
+	assert.NoError(t, os.WriteFile(path, []byte(`
+http:
+  port: 9999
+mysql: {host: db, db_name: wallet, user: wallet}
+fx: {spread: 0.02}
+`), 0600))
+	assert.NoError(t, config.ReloadIfChanged(context.Background()))
+	after := config.GetConf()
+	assert.Equal(t, 0.02, after.FX.Spread)
+	assert.Equal(t, 8090, after.Http.Port)
+	assert.Equal(t, 0.01, before.FX.Spread)
+	assert.Equal(t, []float64{0.01, 0.02}, notified)
+
+	lines := logLines(t, &logs)
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "warn", lines[0]["level"])
+		assert.Equal(t, []interface{}{"http.port"}, lines[0]["fields"])
+		assert.Equal(t, "config reloaded", lines[1]["message"])
+		assert.Equal(t, []interface{}{"fx.spread"}, lines[1]["fields"])
+	}
+
+	// An invalid file keeps the current config and is reported once
+	assert.NoError(t, os.WriteFile(path, []byte(`
+mysql: {host: db, db_name: wallet, user: wallet}
+fx: {spread: 2}
+`), 0600))
+	assert.Error(t, config.ReloadIfChanged(context.Background()))
+	assert.NoError(t, config.ReloadIfChanged(context.Background()))
+	assert.Same(t, after, config.GetConf())
+
+	// Reload reports what it applied and rejected
+	assert.NoError(t, os.WriteFile(path, []byte(`
+mysql: {host: db2, db_name: wallet, user: wallet}
+fx: {spread: 0.03}
+`), 0600))
+	result, err := config.Reload()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"fx.spread"}, result.Applied)
+		assert.Equal(t, []string{"mysql.host"}, result.Rejected)
+	}
+	assert.Equal(t, "db", config.GetConf().MySQL.Host)
+	assert.Len(t, notified, 4)
+
+	// Existing wallets are opened and looked up by the default currency and the
+	// house user, so those keep their running values
+	assert.NoError(t, os.WriteFile(path, []byte(`
+mysql: {host: db, db_name: wallet, user: wallet}
+fx: {spread: 0.03}
+wallet: {default_currency: EUR, house_user_id: 7, overdraft_daily_rate: 0.001}
+`), 0600))
+	result, err = config.Reload()
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"wallet.overdraft_daily_rate"}, result.Applied)
+		assert.Equal(t, []string{"wallet.default_currency", "wallet.currencies", "wallet.house_user_id"}, result.Rejected)
+	}
+	assert.Equal(t, "USD", config.GetConf().Wallet.DefaultCurrency)
+	assert.Equal(t, 0, config.GetConf().Wallet.HouseUserID)
+	assert.Equal(t, 0.001, config.GetConf().Wallet.OverdraftDailyRate)
+
+	// The admin endpoint reports the same result
+	assert.NoError(t, os.WriteFile(path, []byte(`
+mysql: {host: db, db_name: wallet, user: wallet}
+fx: {spread: 0.04}
+wallet: {overdraft_daily_rate: 0.001}
+`), 0600))
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/reload", controller.ReloadConfig)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/reload", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"applied":["fx.spread"],"rejected":[]`)
+}
+
+// TestMySQLConfig tests the pool defaults and that replicas inherit unset
+// connection settings from the primary, with their passwords masked
+func TestMySQLConfig(t *testing.T) {
+	writeConfig(t, `
+mysql:
+  host: primary
+  port: 3307
+  db_name: wallet
+  user: wallet
+  password: hunter2
+  query_timeout: 5s
+  replicas:
+    - host: replica-1
+    - host: replica-2
+      port: 3308
+      user: reader
+      password: reader-secret
+`)
+	if !assert.NoError(t, config.InitConfig()) {
+		return
+	}
+
+	mysqlConf := config.GetConf().MySQL
+	assert.Equal(t, 10, mysqlConf.MaxIdleConns)
+	assert.Equal(t, 100, mysqlConf.MaxOpenConns)
+	assert.Equal(t, time.Hour, mysqlConf.ConnMaxLifetime)
+	assert.Equal(t, 5*time.Second, mysqlConf.QueryTimeout)
+	assert.Equal(t, []config.MySQLReplica{
+		{Host: "replica-1", Port: 3307, User: "wallet", Password: "hunter2"},
+		{Host: "replica-2", Port: 3308, User: "reader", Password: "reader-secret"},
+	}, mysqlConf.Replicas)
+
+	out, err := config.GetConf().Dump()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), "hunter2")
+	assert.NotContains(t, string(out), "reader-secret")
+	assert.Equal(t, "reader-secret", config.GetConf().MySQL.Replicas[1].Password)
+
+	t.Setenv("WALLET_MYSQL_MAX_IDLE_CONNS", "200")
+	assert.ErrorContains(t, config.InitConfig(), "max idle connections")
+}
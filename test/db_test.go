@@ -0,0 +1,60 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"wallet/config"
+	"wallet/models"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// TestQueryTimeout tests that statements run with the configured timeout unless
+// the caller's deadline is earlier, and that a reused statement gets a fresh one
+func TestQueryTimeout(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:1)/wallet", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, db.Use(config.QueryTimeout(time.Minute)))
+
+	var deadlines []time.Time
+	inspect := func(db *gorm.DB) {
+		deadline, _ := db.Statement.Context.Deadline()
+		deadlines = append(deadlines, deadline)
+	}
+	assert.NoError(t, db.Callback().Query().Before("gorm:query").After("query_timeout:before_query").Register("test:inspect_query", inspect))
+	assert.NoError(t, db.Callback().Create().Before("gorm:create").After("query_timeout:before_create").Register("test:inspect_create", inspect))
+
+	start := time.Now()
+	var wallet models.Wallets
+	query := db.Model(&models.Wallets{}).Where("user_id = ?", 1)
+	query.First(&wallet)
+	query.First(&wallet)
+	db.Create(&models.Transaction{Type: "deposit", Amount: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	callerDeadline, _ := ctx.Deadline()
+	db.WithContext(ctx).Where("user_id = ?", 1).First(&wallet)
+
+	if assert.Len(t, deadlines, 4) {
+		for _, deadline := range deadlines[:3] {
+			assert.WithinDuration(t, start.Add(time.Minute), deadline, 5*time.Second)
+		}
+		assert.Equal(t, callerDeadline, deadlines[3])
+	}
+	_, hasDeadline := query.Statement.Context.Deadline()
+	assert.False(t, hasDeadline)
+}
+
+// TestGetReadDB tests that reads use the primary when no replica is configured
+func TestGetReadDB(t *testing.T) {
+	assert.Same(t, config.GetDB(), config.GetReadDB())
+	assert.Empty(t, config.ReplicaStatus())
+}
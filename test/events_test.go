@@ -0,0 +1,79 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wallet/events"
+	"wallet/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFromOutbox tests conversion of outbox rows into delivered events
+func TestFromOutbox(t *testing.T) {
+	row := &models.OutboxEvents{
+		ID:           7,
+		EventID:      "2c5ea4c0-4067-4a3f-a8f1-3b1d2a9e0f11",
+		Type:         events.Transferred,
+		OrderingKeys: events.WalletKey(1) + "," + events.WalletKey(2),
+		Payload:      `{"amount":10}`,
+		CreatedAt:    time.Now(),
+	}
+	event := events.FromOutbox(row)
+	assert.Equal(t, uint(7), event.Seq)
+	assert.Equal(t, row.EventID, event.ID)
+	assert.Equal(t, []string{"wallet:1", "wallet:2"}, event.Keys)
+	assert.JSONEq(t, `{"amount":10}`, string(event.Data))
+
+	// Events without keys are not ordered against anything
+	row.OrderingKeys = ""
+	assert.Empty(t, events.FromOutbox(row).Keys)
+}
+
+// TestHTTPSink tests that the HTTP sink posts the event and reports failures
+func TestHTTPSink(t *testing.T) {
+	var received events.Event
+	var header http.Header
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := events.NewHTTPSink(server.URL, time.Second)
+	event := events.Event{
+		ID:   "2c5ea4c0-4067-4a3f-a8f1-3b1d2a9e0f11",
+		Seq:  3,
+		Type: events.Deposited,
+		Keys: []string{events.WalletKey(4)},
+		Data: json.RawMessage(`{"amount":5}`),
+	}
+	assert.NoError(t, sink.Publish(context.Background(), event))
+	assert.Equal(t, event.ID, header.Get("X-Event-ID"))
+	assert.Equal(t, events.Deposited, header.Get("X-Event-Type"))
+	assert.Equal(t, event.Seq, received.Seq)
+	assert.Equal(t, event.Keys, received.Keys)
+
+	status = http.StatusServiceUnavailable
+	assert.Error(t, sink.Publish(context.Background(), event))
+}
+
+// TestParseKey tests splitting ordering keys into kind and ID
+func TestParseKey(t *testing.T) {
+	kind, id, ok := events.ParseKey(events.SharedWalletKey(12))
+	assert.True(t, ok)
+	assert.Equal(t, events.KeySharedWallet, kind)
+	assert.Equal(t, uint(12), id)
+
+	for _, key := range []string{"", "wallet", "wallet:", "wallet:x", "wallet:-1"} {
+		_, _, ok := events.ParseKey(key)
+		assert.False(t, ok, key)
+	}
+}
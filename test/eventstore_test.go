@@ -0,0 +1,70 @@
+package test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"wallet/eventstore"
+	"wallet/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// walletEvent builds the stored event of change at version
+func walletEvent(version uint, change eventstore.Change) *models.WalletEvents {
+	data, _ := json.Marshal(change.Data)
+	return &models.WalletEvents{ID: version, WalletID: 10, Version: version, Type: change.Type, TransactionID: change.TransactionID, Data: string(data)}
+}
+
+// TestWalletAggregate tests that applying a wallet's events rebuilds its state
+func TestWalletAggregate(t *testing.T) {
+	wallet := &models.Wallets{ID: 10, UserID: 1, Currency: "USD", Balance: 5}
+	stream := []*models.WalletEvents{walletEvent(1, eventstore.Opened(wallet))}
+
+	wallet.Balance = 25.10
+	stream = append(stream, walletEvent(2, eventstore.Credited(wallet, &models.Transaction{ID: 1, Type: "deposit", Amount: 20.10})))
+	wallet.Balance = 15.05
+	stream = append(stream, walletEvent(3, eventstore.Debited(wallet, &models.Transaction{ID: 2, Type: "withdraw", Amount: 10.05})))
+	stream = append(stream, walletEvent(4, eventstore.LimitSet(50)), walletEvent(5, eventstore.Frozen(true)))
+
+	aggregate := &eventstore.Wallet{}
+	for _, event := range stream {
+		assert.NoError(t, aggregate.Apply(event))
+	}
+	assert.Equal(t, eventstore.Wallet{ID: 10, UserID: 1, Currency: "USD", Balance: 15.05, OverdraftLimit: 50, Frozen: true, Version: 5}, *aggregate)
+
+	// Replaying from a snapshot gives the same state
+	snapshot := &eventstore.Wallet{}
+	for _, event := range stream[:3] {
+		assert.NoError(t, snapshot.Apply(event))
+	}
+	restored := eventstore.FromSnapshot(snapshot.Snapshot())
+	for _, event := range stream[3:] {
+		assert.NoError(t, restored.Apply(event))
+	}
+	assert.Equal(t, *aggregate, *restored)
+}
+
+// TestWalletAggregateRejectsAlteredStream tests that gaps and altered balances are detected
+func TestWalletAggregateRejectsAlteredStream(t *testing.T) {
+	wallet := &models.Wallets{ID: 10, UserID: 1, Currency: "USD"}
+	opened := walletEvent(1, eventstore.Opened(wallet))
+
+	wallet.Balance = 10
+	credit := eventstore.Credited(wallet, &models.Transaction{ID: 1, Type: "deposit", Amount: 10})
+
+	// Stream must start with the opening event
+	assert.Error(t, (&eventstore.Wallet{}).Apply(walletEvent(1, credit)))
+
+	// Versions must follow each other
+	aggregate := &eventstore.Wallet{}
+	assert.NoError(t, aggregate.Apply(opened))
+	assert.Error(t, aggregate.Apply(walletEvent(3, credit)))
+
+	// The recorded balance must follow from the amount
+	altered := walletEvent(2, credit)
+	altered.Data = `{"transaction_type":"deposit","amount":12,"balance":10}`
+	assert.Error(t, aggregate.Apply(altered))
+	assert.NoError(t, aggregate.Apply(walletEvent(2, credit)))
+	assert.Equal(t, 10.0, aggregate.Balance)
+}
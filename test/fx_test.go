@@ -0,0 +1,51 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"wallet/fx"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStaticProvider tests cross rates derived through the base currency
+func TestStaticProvider(t *testing.T) {
+	provider := fx.NewStaticProvider("usd", map[string]float64{
+		"EUR": 0.8,
+		"CNY": 7.2,
+	})
+
+	rate, err := provider.Rate("USD", "EUR")
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.8, rate, 1e-9)
+
+	rate, err = provider.Rate("eur", "usd")
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.25, rate, 1e-9)
+
+	rate, err = provider.Rate("EUR", "CNY")
+	assert.NoError(t, err)
+	assert.InDelta(t, 9.0, rate, 1e-9)
+
+	_, err = provider.Rate("USD", "JPY")
+	assert.ErrorIs(t, err, fx.ErrRateUnavailable)
+}
+
+// TestLoadStaticProvider tests loading rates from a YAML file
+func TestLoadStaticProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.yaml")
+	err := os.WriteFile(path, []byte("base: EUR\nrates:\n  USD: 1.1\n"), 0644)
+	assert.NoError(t, err)
+
+	provider, err := fx.LoadStaticProvider(path)
+	assert.NoError(t, err)
+
+	rate, err := provider.Rate("EUR", "USD")
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.1, rate, 1e-9)
+
+	_, err = fx.LoadStaticProvider(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"wallet/health"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestReadinessReport tests that readiness needs the ready state and every check passing
+func TestReadinessReport(t *testing.T) {
+	defer health.SetState(health.CurrentState())
+
+	checks := health.NewRegistry()
+	checks.Register("database", func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	})
+	checks.Register("workers", func(ctx context.Context) (interface{}, error) {
+		return map[string]string{"state": "running"}, nil
+	})
+
+	health.SetState(health.Starting)
+	report := checks.Run(context.Background(), time.Second)
+	assert.False(t, report.Ready())
+	assert.Equal(t, "starting", report.Status)
+	assert.Equal(t, "ok", report.Checks["database"].Status)
+
+	health.SetState(health.Ready)
+	report = checks.Run(context.Background(), time.Second)
+	assert.True(t, report.Ready())
+	assert.Equal(t, map[string]string{"state": "running"}, report.Checks["workers"].Details)
+
+	// A failing check makes the process unready and is reported with its error
+	checks.Register("database", func(ctx context.Context) (interface{}, error) {
+		return nil, errors.New("connection refused")
+	})
+	report = checks.Run(context.Background(), time.Second)
+	assert.Equal(t, "fail", report.Status)
+	assert.Equal(t, "connection refused", report.Checks["database"].Error)
+	assert.Len(t, report.Checks, 2)
+
+	// A check that hangs fails at the timeout
+	checks.Register("database", func(ctx context.Context) (interface{}, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	})
+	start := time.Now()
+	report = checks.Run(context.Background(), 20*time.Millisecond)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, "fail", report.Checks["database"].Status)
+
+	health.SetState(health.Stopping)
+	checks.Register("database", func(ctx context.Context) (interface{}, error) {
+		return nil, nil
+	})
+	report = checks.Run(context.Background(), time.Second)
+	assert.Equal(t, "stopping", report.Status)
+}
@@ -0,0 +1,178 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"wallet/config"
+	"wallet/models"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// here returns file:line of its caller, offset by delta lines
+func here(delta int) string {
+	_, file, line, _ := runtime.Caller(1)
+	return fmt.Sprintf("%s:%d", filepath.Base(file), line+delta)
+}
+
+// TestLoggerCaller tests that lines point at the code that logged them, for
+// logger methods as well as the package level helpers
+func TestLoggerCaller(t *testing.T) {
+	var logs bytes.Buffer
+	logger := config.NewLogger(&logs, config.LevelInfo)
+	config.SetLogger(logger)
+
+	logger.Info("method")
+	method := here(-1)
+	config.Warn("helper")
+	helper := here(-1)
+	logger.With("k", "v").WithContext(context.Background()).Error("derived")
+	derived := here(-1)
+
+	lines := logLines(t, &logs)
+	if assert.Len(t, lines, 3) {
+		assert.Equal(t, method, lines[0]["caller"])
+		assert.Equal(t, "info", lines[0]["level"])
+		assert.Equal(t, "wallet", lines[0]["service"])
+		assert.Equal(t, helper, lines[1]["caller"])
+		assert.Equal(t, "warn", lines[1]["level"])
+		assert.Equal(t, derived, lines[2]["caller"])
+		assert.Equal(t, "v", lines[2]["k"])
+	}
+}
+
+// TestLoggerSetLevel tests that the level changes at runtime for the logger and
+// every logger derived from it
+func TestLoggerSetLevel(t *testing.T) {
+	var logs bytes.Buffer
+	logger := config.NewLogger(&logs, config.LevelInfo)
+	derived := logger.With("request_id", "r1")
+
+	derived.Debug("hidden")
+	assert.Empty(t, logs.String())
+
+	assert.NoError(t, logger.SetLevel(config.LevelDebug))
+	assert.Equal(t, config.LevelDebug, derived.Level())
+	derived.Debug("shown")
+	lines := logLines(t, &logs)
+	if assert.Len(t, lines, 1) {
+		assert.Equal(t, "debug", lines[0]["level"])
+		assert.Equal(t, "r1", lines[0]["request_id"])
+	}
+
+	assert.NoError(t, derived.SetLevel(config.LevelError))
+	assert.False(t, logger.Enabled(config.LevelWarn))
+	assert.Error(t, logger.SetLevel("verbose"))
+	assert.Equal(t, config.LevelError, logger.Level())
+
+	// Fields named like the built-in keys are written as they are
+	assert.NotPanics(t, func() { logger.Error("conflict", "level", "custom", "time", "custom") })
+}
+
+// TestLogWriter tests that each line written through LogWriter becomes a log line
+// at the writer's level
+func TestLogWriter(t *testing.T) {
+	var logs bytes.Buffer
+	config.SetLogger(config.NewLogger(&logs, config.LevelInfo))
+
+	fmt.Fprint(config.LogWriter(config.LevelWarn), "first\n\nsecond\n")
+	fmt.Fprint(config.LogWriter(config.LevelDebug), "below level\n")
+
+	lines := logLines(t, &logs)
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "first", lines[0]["message"])
+		assert.Equal(t, "second", lines[1]["message"])
+		assert.Equal(t, "warn", lines[1]["level"])
+		assert.NotContains(t, lines[1], "caller")
+	}
+}
+
+// TestLoggerFileOutput tests the text format written to a rotated log file
+func TestLoggerFileOutput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	logger, closer, err := config.NewLoggerFromConf(config.LogConf{
+		Level:       config.LevelInfo,
+		Format:      "text",
+		ServiceName: "wallet-test",
+		Outputs:     []string{"file"},
+		File:        config.LogFileConf{Path: path, MaxSizeMB: 1, MaxBackups: 2},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	logger.Info("started", "port", 8080)
+	// Exceed max_size_mb so the file rotates
+	padding := strings.Repeat("x", 1024)
+	for i := 0; i < 1100; i++ {
+		logger.Debug("hidden", "padding", padding)
+		logger.Info("filler", "padding", padding)
+	}
+	assert.NoError(t, closer.Close())
+
+	files, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "level=info")
+	assert.Contains(t, string(content), "service=wallet-test")
+	assert.NotContains(t, string(content), "hidden")
+}
+
+// TestGormLogger tests that SQL is logged at debug level through the request
+// logger with the caller outside GORM, and that failed and slow statements are
+// logged at error and warn level
+func TestGormLogger(t *testing.T) {
+	var logs bytes.Buffer
+	logger := config.NewLogger(&logs, config.LevelInfo)
+	ctx := config.ContextWithLogger(context.Background(), logger.With("request_id", "r2"))
+
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:1)/wallet", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, Logger: config.NewGormLogger(time.Second)})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var wallet models.Wallets
+	db.WithContext(ctx).Where("user_id = ?", 1).First(&wallet)
+	assert.Empty(t, logs.String())
+
+	assert.NoError(t, logger.SetLevel(config.LevelDebug))
+	db.WithContext(ctx).Where("user_id = ?", 1).First(&wallet)
+	caller := here(-1)
+	gormLogger := db.Logger
+	gormLogger.Trace(ctx, time.Now().Add(-2*time.Second), func() (string, int64) { return "SELECT SLEEP(2)", 1 }, nil)
+	gormLogger.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT 1", 0 }, errors.New("bad connection"))
+	gormLogger.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT 2", 0 }, gorm.ErrRecordNotFound)
+
+	lines := logLines(t, &logs)
+	if assert.Len(t, lines, 4) {
+		assert.Equal(t, "sql", lines[0]["message"])
+		assert.Equal(t, "debug", lines[0]["level"])
+		assert.Equal(t, caller, lines[0]["caller"])
+		assert.Equal(t, "r2", lines[0]["request_id"])
+		assert.Contains(t, lines[0]["sql"], "WHERE user_id = 1")
+
+		assert.Equal(t, "slow sql", lines[1]["message"])
+		assert.Equal(t, "warn", lines[1]["level"])
+
+		assert.Equal(t, "sql failed", lines[2]["message"])
+		assert.Equal(t, "error", lines[2]["level"])
+		assert.Equal(t, "bad connection", lines[2]["error"])
+
+		assert.Equal(t, "sql", lines[3]["message"])
+	}
+}
@@ -0,0 +1,57 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wallet/metrics"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// scrapeMetrics returns the text exposition of every metric
+func scrapeMetrics(t *testing.T) string {
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+// TestHTTPMetrics tests that requests are counted by route pattern and status
+func TestHTTPMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(metrics.HTTP())
+	r.GET("/metrics-test/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	for _, path := range []string{"/metrics-test/1", "/metrics-test/2", "/metrics-test-missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrapeMetrics(t)
+	assert.Contains(t, body, `wallet_http_requests_total{method="GET",route="/metrics-test/:id",status="204"} 2`)
+	assert.Contains(t, body, `wallet_http_request_duration_seconds_count{method="GET",route="/metrics-test/:id",status="204"} 2`)
+	assert.Contains(t, body, `wallet_http_requests_total{method="GET",route="unmatched",status="404"}`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+// TestMoneyMetrics tests the money flow counters
+func TestMoneyMetrics(t *testing.T) {
+	metrics.Movement("deposit", "JPY", "success", 150)
+	metrics.Movement("deposit", "JPY", "success", 50)
+	metrics.Movement("withdraw", "JPY", "insufficient_balance", 70)
+	metrics.InsufficientBalance("metrics_test")
+	metrics.LockWait("metrics_test", 3*time.Millisecond)
+
+	body := scrapeMetrics(t)
+	assert.Contains(t, body, `wallet_movements_total{currency="JPY",outcome="success",type="deposit"} 2`)
+	assert.Contains(t, body, `wallet_movement_volume_total{currency="JPY",outcome="success",type="deposit"} 200`)
+	assert.Contains(t, body, `wallet_movements_total{currency="JPY",outcome="insufficient_balance",type="withdraw"} 1`)
+	assert.Contains(t, body, `wallet_insufficient_balance_rejections_total{operation="metrics_test"} 1`)
+	assert.Contains(t, body, `wallet_lock_wait_seconds_count{operation="metrics_test"} 1`)
+}
@@ -0,0 +1,165 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wallet/config"
+	"wallet/middleware"
+	"wallet/ratelimit"
+	"wallet/router"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingStore is a rate limit store that is unavailable
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store unavailable")
+}
+
+// TestMemoryStore tests token bucket refill, limit changes and that full
+// buckets are dropped
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := ratelimit.NewMemoryStoreWithClock(func() time.Time { return now })
+	ctx := context.Background()
+	limit := ratelimit.Limit{Rate: 2, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		result, err := store.Take(ctx, "a", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, i, result.Remaining)
+	}
+	result, _ := store.Take(ctx, "a", limit)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, result.ResetAfter)
+
+	// Other keys have their own bucket
+	result, _ = store.Take(ctx, "b", limit)
+	assert.True(t, result.Allowed)
+
+	// One token refilled after half a second
+	now = now.Add(500 * time.Millisecond)
+	result, _ = store.Take(ctx, "a", limit)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	// A lowered burst caps existing buckets
+	now = now.Add(10 * time.Second)
+	result, _ = store.Take(ctx, "a", ratelimit.Limit{Rate: 2, Burst: 1})
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	// Buckets full again are dropped once a minute
+	now = now.Add(time.Minute)
+	result, _ = store.Take(ctx, "c", limit)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 1, store.Len())
+}
+
+// TestRateLimit tests that requests are charged to the client IP and to the
+// authenticated user, that money routes are also held to the money limit, the
+// response headers, and that X-Forwarded-For is only taken from trusted proxies
+func TestRateLimit(t *testing.T) {
+	writeConfig(t, `
+mysql: {host: db, db_name: wallet, user: wallet}
+http: {trusted_proxies: [10.0.0.9]}
+auth: {gateway_token: test-gateway-token}
+rate_limit:
+  enabled: true
+  read: {rate: 0.001, burst: 3}
+  money: {rate: 0.001, burst: 1}
+`)
+	if !assert.NoError(t, config.InitConfig()) {
+		return
+	}
+	ratelimit.SetDefaultStore(ratelimit.NewMemoryStore())
+	t.Cleanup(func() { ratelimit.SetDefaultStore(ratelimit.NewMemoryStore()) })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	router.TrustProxies(r)
+	r.Use(middleware.Identity(), middleware.RateLimit(middleware.LimitRead))
+	r.GET("/balance", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/transfer", middleware.RateLimit(middleware.LimitMoney), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// sendAs sends a request from ip with the given headers, send one from the
+	// gateway on behalf of userID
+	sendAs := func(method, path, ip string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	send := func(method, path, ip, userID string) *httptest.ResponseRecorder {
+		header := http.Header{}
+		if userID != "" {
+			header.Set(middleware.HeaderUserID, userID)
+			header.Set(middleware.HeaderGatewayToken, "test-gateway-token")
+		}
+		return sendAs(method, path, ip, header)
+	}
+
+	w := send(http.MethodGet, "/balance", "10.0.0.1", "1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "3", w.Header().Get(middleware.HeaderRateLimitLimit))
+	assert.Equal(t, "2", w.Header().Get(middleware.HeaderRateLimitRemaining))
+	assert.Equal(t, "1000", w.Header().Get(middleware.HeaderRateLimitReset))
+
+	// The money limit applies on top of the read limit
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/transfer", "10.0.0.1", "1").Code)
+	w = send(http.MethodPost, "/transfer", "10.0.0.1", "1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1000", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get(middleware.HeaderRateLimitRemaining))
+	assert.Contains(t, w.Body.String(), "Rate limit exceeded")
+
+	// The read limit is used up by the three requests above
+	assert.Equal(t, http.StatusTooManyRequests, send(http.MethodGet, "/balance", "10.0.0.1", "1").Code)
+
+	// The user's bucket follows the user to another IP, which has a bucket of its own
+	assert.Equal(t, http.StatusTooManyRequests, send(http.MethodGet, "/balance", "10.0.0.2", "1").Code)
+	w = send(http.MethodGet, "/balance", "10.0.0.2", "2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get(middleware.HeaderRateLimitRemaining))
+
+	// A new X-User-ID on every request does not get around the IP limit
+	for i, userID := range []string{"11", "12", "13", "14"} {
+		want := http.StatusOK
+		if i == 3 {
+			want = http.StatusTooManyRequests
+		}
+		assert.Equal(t, want, sendAs(http.MethodGet, "/balance", "10.0.0.3", http.Header{middleware.HeaderUserID: {userID}}).Code)
+	}
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/transfer", "10.0.0.4", "").Code)
+
+	// An unauthenticated X-User-ID is not charged to, nor held to, that user's bucket
+	w = sendAs(http.MethodGet, "/balance", "10.0.0.5", http.Header{middleware.HeaderUserID: {"1"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get(middleware.HeaderRateLimitRemaining))
+
+	// X-Forwarded-For is ignored unless the request comes from a trusted proxy
+	forwardedFor := func(ip string) http.Header { return http.Header{"X-Forwarded-For": {ip}} }
+	assert.Equal(t, http.StatusTooManyRequests, sendAs(http.MethodGet, "/balance", "10.0.0.1", forwardedFor("10.0.0.50")).Code)
+	assert.Equal(t, http.StatusTooManyRequests, sendAs(http.MethodGet, "/balance", "10.0.0.9", forwardedFor("10.0.0.1")).Code)
+	assert.Equal(t, http.StatusOK, sendAs(http.MethodGet, "/balance", "10.0.0.9", forwardedFor("10.0.0.51")).Code)
+
+	// Requests are let through when the store fails
+	ratelimit.SetDefaultStore(failingStore{})
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/balance", "10.0.0.1", "1").Code)
+	assert.Contains(t, scrapeMetrics(t), `wallet_rate_limited_requests_total{limit="money"}`)
+}
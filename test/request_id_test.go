@@ -0,0 +1,90 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"wallet/config"
+	"wallet/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// logLines decodes the JSON log lines written to buf
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var line map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(raw), &line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// TestRequestID tests that request IDs are assigned or propagated, and that
+// handler and access log lines carry them
+func TestRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	config.SetLogger(config.NewLogger(&logs, config.LevelInfo))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.RequestID(), middleware.Identity())
+	r.GET("/items/:id", func(c *gin.Context) {
+		config.LoggerFromContext(c.Request.Context()).Info("handled", "item", c.Param("id"))
+		c.Status(http.StatusNoContent)
+	})
+
+	// Generated when the caller sends none
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/items/3", nil)
+	req.Header.Set(middleware.HeaderUserID, "42")
+	r.ServeHTTP(w, req)
+	requestID := w.Header().Get(middleware.HeaderRequestID)
+	assert.Len(t, requestID, 32)
+
+	lines := logLines(t, &logs)
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, "handled", lines[0]["message"])
+		assert.Equal(t, requestID, lines[0]["request_id"])
+		assert.Equal(t, "3", lines[0]["item"])
+
+		assert.Equal(t, "request completed", lines[1]["message"])
+		assert.Equal(t, requestID, lines[1]["request_id"])
+		assert.Equal(t, "/items/:id", lines[1]["route"])
+		assert.Equal(t, float64(http.StatusNoContent), lines[1]["status"])
+		assert.Equal(t, float64(42), lines[1]["user_id"])
+		assert.Contains(t, lines[1], "latency_ms")
+	}
+
+	// Propagated when the caller sends a usable one
+	logs.Reset()
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/items/4", nil)
+	req.Header.Set(middleware.HeaderRequestID, "gateway-7f3a.1")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "gateway-7f3a.1", w.Header().Get(middleware.HeaderRequestID))
+	for _, line := range logLines(t, &logs) {
+		assert.Equal(t, "gateway-7f3a.1", line["request_id"])
+		assert.NotContains(t, line, "user_id")
+	}
+
+	// Replaced when it could forge log content
+	logs.Reset()
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/missing", nil)
+	req.Header.Set(middleware.HeaderRequestID, `x","level":"debug`)
+	r.ServeHTTP(w, req)
+	replaced := w.Header().Get(middleware.HeaderRequestID)
+	assert.Len(t, replaced, 32)
+	lines = logLines(t, &logs)
+	if assert.Len(t, lines, 1) {
+		assert.Equal(t, replaced, lines[0]["request_id"])
+		assert.Equal(t, "warn", lines[0]["level"])
+	}
+}
@@ -0,0 +1,68 @@
+package test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"wallet/models"
+	"wallet/statement"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStatementBalances tests running balances, totals by type and the closing balance
+func TestStatementBalances(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	transactions := []models.Transaction{
+		{ID: 1, Type: "deposit", ToUserID: 7, Amount: 100, CreatedAt: from.Add(time.Hour)},
+		{ID: 2, Type: "transfer", FromUserID: 7, ToUserID: 8, Amount: 30.5, CreatedAt: from.Add(2 * time.Hour)},
+		{ID: 3, Type: "transfer", FromUserID: 9, ToUserID: 7, Amount: 10, CreatedAt: from.Add(3 * time.Hour)},
+		{ID: 4, Type: "withdraw", FromUserID: 7, Amount: 20, CreatedAt: from.Add(4 * time.Hour)},
+	}
+
+	st := statement.New(7, "USD", from, to, 50, transactions)
+
+	balances := make([]float64, 0, len(st.Lines))
+	for _, line := range st.Lines {
+		balances = append(balances, line.RunningBalance)
+	}
+	assert.Equal(t, []float64{150, 119.5, 129.5, 109.5}, balances)
+	assert.Equal(t, 109.5, st.ClosingBalance)
+	assert.Equal(t, 110.0, st.TotalCredits)
+	assert.Equal(t, 50.5, st.TotalDebits)
+
+	assert.Equal(t, []statement.TypeTotal{
+		{Type: "deposit", Count: 1, Credits: 100},
+		{Type: "transfer", Count: 2, Credits: 10, Debits: 30.5},
+		{Type: "withdraw", Count: 1, Debits: 20},
+	}, st.Totals)
+}
+
+// TestStatementFormats tests the csv and pdf renderings
+func TestStatementFormats(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	st := statement.New(7, "USD", from, from.AddDate(0, 0, 1), 10, []models.Transaction{
+		{ID: 1, Type: "deposit", ToUserID: 7, Amount: 5, Description: "salary (jan)", CreatedAt: from.Add(time.Hour)},
+	})
+
+	var buf bytes.Buffer
+	assert.NoError(t, statement.Write(&buf, st, statement.FormatCSV))
+	reader := csv.NewReader(&buf)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	assert.NoError(t, err)
+	assert.Contains(t, records, []string{from.Format(time.RFC3339), "", "opening_balance", "", "", "", "10.00"})
+	assert.Contains(t, records, []string{from.Add(time.Hour).Format(time.RFC3339), "1", "deposit", "salary (jan)", "5.00", "0.00", "15.00"})
+	assert.Contains(t, records, []string{"deposit", "1", "5.00", "0.00"})
+
+	buf.Reset()
+	assert.NoError(t, statement.Write(&buf, st, statement.FormatPDF))
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-1.4")))
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), []byte("%%EOF\n")))
+	assert.Contains(t, buf.String(), `salary \(jan\)`)
+
+	assert.ErrorIs(t, statement.Write(&buf, st, "xml"), statement.ErrUnsupportedFormat)
+}
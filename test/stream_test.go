@@ -0,0 +1,96 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"wallet/config"
+	"wallet/events"
+	"wallet/stream"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestProjectTransfer tests that each side of a transfer only sees its own balance
+func TestProjectTransfer(t *testing.T) {
+	data, _ := json.Marshal(events.TransferredData{
+		TransactionID: 9,
+		FromUserID:    1,
+		ToUserID:      2,
+		FromWalletID:  10,
+		ToWalletID:    20,
+		Currency:      "USD",
+		Amount:        15,
+		FromBalance:   85,
+		ToBalance:     115,
+	})
+	event := events.Event{Seq: 4, Type: events.Transferred, Keys: []string{events.WalletKey(10), events.WalletKey(20)}, Data: data}
+
+	sender, ok := stream.Project(event, []string{events.WalletKey(10)})
+	assert.True(t, ok)
+	assert.Equal(t, uint(4), sender.Seq)
+	assert.Equal(t, []stream.TransactionUpdate{{ID: 9, Currency: "USD", Amount: -15}}, sender.Transactions)
+	assert.Equal(t, []stream.BalanceUpdate{{WalletID: 10, Currency: "USD", Balance: 85}}, sender.Balances)
+
+	recipient, ok := stream.Project(event, []string{events.WalletKey(20)})
+	assert.True(t, ok)
+	assert.Equal(t, []stream.TransactionUpdate{{ID: 9, Currency: "USD", Amount: 15}}, recipient.Transactions)
+	assert.Equal(t, []stream.BalanceUpdate{{WalletID: 20, Currency: "USD", Balance: 115}}, recipient.Balances)
+
+	_, ok = stream.Project(event, []string{events.WalletKey(30)})
+	assert.False(t, ok)
+}
+
+// TestProjectSharedWallet tests that a shared wallet transfer recipient does not see the shared balance
+func TestProjectSharedWallet(t *testing.T) {
+	data, _ := json.Marshal(events.SharedWalletMovedData{
+		TransactionID:  5,
+		SharedWalletID: 3,
+		Type:           "transfer",
+		UserID:         7,
+		WalletID:       70,
+		Currency:       "EUR",
+		Amount:         20,
+		Balance:        120,
+		SharedBalance:  480,
+	})
+	event := events.Event{Type: events.SharedWalletSpent, Keys: []string{events.SharedWalletKey(3), events.WalletKey(70)}, Data: data}
+
+	recipient, ok := stream.Project(event, []string{events.WalletKey(70)})
+	assert.True(t, ok)
+	assert.Equal(t, 20.0, recipient.Transactions[0].Amount)
+	assert.Equal(t, []stream.BalanceUpdate{{WalletID: 70, Currency: "EUR", Balance: 120}}, recipient.Balances)
+
+	member, ok := stream.Project(event, []string{events.SharedWalletKey(3)})
+	assert.True(t, ok)
+	assert.Equal(t, -20.0, member.Transactions[0].Amount)
+	assert.Equal(t, []stream.BalanceUpdate{{SharedWalletID: 3, Currency: "EUR", Balance: 480}}, member.Balances)
+}
+
+// TestWriteEvent tests the Server-Sent Events wire format
+func TestWriteEvent(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, stream.WriteEvent(&buf, "12", stream.EventReset, []byte("a\nb")))
+	assert.Equal(t, "id: 12\nevent: reset\ndata: a\ndata: b\n\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, stream.WriteUpdate(&buf, stream.Update{Seq: 3, Type: events.Deposited}))
+	assert.Contains(t, buf.String(), "id: 3\ndata: {")
+	assert.NotContains(t, buf.String(), "event:")
+}
+
+// TestHubClose tests that closing the hub ends open streams and refuses new ones
+func TestHubClose(t *testing.T) {
+	hub := stream.NewHub(config.StreamConf{BufferSize: 1})
+	sub := hub.Subscribe(1)
+
+	hub.Close()
+	_, open := <-sub.C
+	assert.False(t, open)
+
+	late := hub.Subscribe(1)
+	_, open = <-late.C
+	assert.False(t, open)
+	hub.Unsubscribe(late)
+}
@@ -0,0 +1,135 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wallet/config"
+	"wallet/models"
+	"wallet/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// recordSpans installs a tracer provider that keeps every ended span in memory
+func recordSpans() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return recorder
+}
+
+// findSpan returns the ended span called name
+func findSpan(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+// TestTracingMiddleware tests that the request span continues the caller's trace,
+// that service spans are its children and that logs carry the trace
+func TestTracingMiddleware(t *testing.T) {
+	recorder := recordSpans()
+	var logs bytes.Buffer
+	logger := config.NewLogger(&logs, config.LevelInfo)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(tracing.Middleware())
+	r.GET("/traced/:id", func(c *gin.Context) {
+		ctx, span := tracing.Start(c.Request.Context(), "Service.Call")
+		logger.WithContext(ctx).Info("handling")
+		tracing.End(span, nil)
+		c.Status(http.StatusNoContent)
+	})
+	r.GET("/failing", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/traced/7", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/failing", nil))
+
+	spans := recorder.Ended()
+	server := findSpan(spans, "GET /traced/:id")
+	call := findSpan(spans, "Service.Call")
+	if assert.NotNil(t, server) && assert.NotNil(t, call) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+		assert.True(t, server.Parent().IsRemote())
+		assert.Equal(t, server.SpanContext().SpanID(), call.Parent().SpanID())
+		assert.Equal(t, codes.Unset, server.Status().Code)
+
+		var line map[string]interface{}
+		assert.NoError(t, json.Unmarshal(logs.Bytes(), &line))
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", line["trace_id"])
+		assert.Equal(t, call.SpanContext().SpanID().String(), line["span_id"])
+	}
+
+	failing := findSpan(spans, "GET /failing")
+	if assert.NotNil(t, failing) {
+		assert.False(t, failing.Parent().IsValid())
+		assert.Equal(t, codes.Error, failing.Status().Code)
+	}
+}
+
+// TestLoggerWithoutTrace tests that logs outside a trace have no trace fields
+func TestLoggerWithoutTrace(t *testing.T) {
+	var logs bytes.Buffer
+	logger := config.NewLogger(&logs, config.LevelInfo)
+	logger.Info("plain")
+	logger.WithContext(context.Background()).Debug("below level")
+
+	var line map[string]interface{}
+	assert.NoError(t, json.Unmarshal(logs.Bytes(), &line))
+	assert.Equal(t, "plain", line["message"])
+	assert.NotContains(t, line, "trace_id")
+}
+
+// TestGormPlugin tests that statements run within a trace get a span holding
+// their SQL, and statements outside one do not
+func TestGormPlugin(t *testing.T) {
+	recorder := recordSpans()
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user:pass@tcp(127.0.0.1:1)/wallet", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Use(tracing.GormPlugin{}))
+
+	ctx, span := tracing.Start(context.Background(), "Service.Call")
+	var wallet models.Wallets
+	db.WithContext(ctx).Where("user_id = ?", 1).First(&wallet)
+	db.WithContext(ctx).Create(&models.Transaction{Type: "deposit", Amount: 1})
+	tracing.End(span, nil)
+
+	db.Where("user_id = ?", 2).First(&wallet)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 3)
+	query := findSpan(spans, "SELECT wallets")
+	insert := findSpan(spans, "INSERT transaction")
+	if assert.NotNil(t, query) && assert.NotNil(t, insert) {
+		assert.Equal(t, span.SpanContext().SpanID(), query.Parent().SpanID())
+		assert.Equal(t, span.SpanContext().SpanID(), insert.Parent().SpanID())
+		for _, attr := range query.Attributes() {
+			if attr.Key == "db.query.text" {
+				assert.Contains(t, attr.Value.AsString(), "WHERE user_id = ?")
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wallet/webhook"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWebhookSignature tests signing and verifying webhook payloads
+func TestWebhookSignature(t *testing.T) {
+	body := []byte(`{"id":"2c5ea4c0-4067-4a3f-a8f1-3b1d2a9e0f11","type":"Deposited"}`)
+	sentAt := time.Unix(1767225600, 0)
+	header := webhook.Sign("secret", sentAt, body)
+	assert.Regexp(t, `^t=1767225600,v1=[0-9a-f]{64}$`, header)
+
+	assert.NoError(t, webhook.Verify("secret", header, body, sentAt.Add(time.Minute), 5*time.Minute))
+	assert.NoError(t, webhook.Verify("secret", header, body, sentAt.Add(time.Hour), 0))
+
+	assert.ErrorIs(t, webhook.Verify("other", header, body, sentAt, 5*time.Minute), webhook.ErrInvalidSignature)
+	assert.ErrorIs(t, webhook.Verify("secret", header, []byte(`{}`), sentAt, 5*time.Minute), webhook.ErrInvalidSignature)
+	assert.ErrorIs(t, webhook.Verify("secret", "v1=abc", body, sentAt, 5*time.Minute), webhook.ErrInvalidSignature)
+	assert.ErrorIs(t, webhook.Verify("secret", header, body, sentAt.Add(time.Hour), 5*time.Minute), webhook.ErrSignatureExpired)
+
+	// The timestamp is signed, so it cannot be moved forward to pass the tolerance check
+	forged := webhook.Sign("secret", sentAt, body)
+	forged = "t=1767229200" + forged[len("t=1767225600"):]
+	assert.ErrorIs(t, webhook.Verify("secret", forged, body, sentAt.Add(time.Hour), 5*time.Minute), webhook.ErrInvalidSignature)
+}
+
+// TestWebhookAddress tests that callbacks to internal addresses are refused, both
+// when the host is checked and when the delivery client connects
+func TestWebhookAddress(t *testing.T) {
+	for _, ip := range []string{"127.0.0.1", "::1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "fe80::1", "fc00::1", "0.0.0.0", "224.0.0.1", "::ffff:127.0.0.1"} {
+		assert.ErrorIs(t, webhook.CheckIP(net.ParseIP(ip)), webhook.ErrForbiddenAddress, ip)
+	}
+	for _, ip := range []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"} {
+		assert.NoError(t, webhook.CheckIP(net.ParseIP(ip)), ip)
+	}
+	assert.ErrorIs(t, webhook.CheckHost(context.Background(), "localhost"), webhook.ErrForbiddenAddress)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := webhook.NewClient(time.Second, false).Get(server.URL)
+	assert.ErrorIs(t, err, webhook.ErrForbiddenAddress)
+
+	resp, err := webhook.NewClient(time.Second, true).Get(server.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+}
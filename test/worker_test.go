@@ -0,0 +1,57 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"wallet/worker"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestManagerShutdown tests that shutdown waits for jobs to return, up to its deadline
+func TestManagerShutdown(t *testing.T) {
+	started := make(chan struct{})
+	finished := make(chan struct{})
+	jobs := worker.NewManager()
+	jobs.Register(worker.Job{
+		Name:     "cooperative",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			close(finished)
+			return nil
+		},
+	})
+	jobs.Start(context.Background())
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, jobs.Shutdown(ctx))
+	select {
+	case <-finished:
+	default:
+		t.Fatal("shutdown returned before the job finished")
+	}
+
+	// A job ignoring cancellation does not hold shutdown past its deadline
+	release := make(chan struct{})
+	defer close(release)
+	stuck := worker.NewManager()
+	stuck.Register(worker.Job{
+		Name:     "stuck",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			<-release
+			return nil
+		},
+	})
+	stuck.Start(context.Background())
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, stuck.Shutdown(ctx), context.DeadlineExceeded)
+}
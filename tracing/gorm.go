@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey stores the span of the statement being executed
+const spanKey = "tracing:span"
+
+// GormPlugin records a client span for every SQL statement run with a context
+// that carries a trace (db.WithContext(ctx)). Statements outside a trace, such as
+// those of background jobs, are not recorded.
+type GormPlugin struct{}
+
+// Name implements gorm.Plugin
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+// Initialize implements gorm.Plugin
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("tracing:before_create", beforeStatement("INSERT")),
+		cb.Create().After("gorm:create").Register("tracing:after_create", afterStatement("INSERT")),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", beforeStatement("SELECT")),
+		cb.Query().After("gorm:query").Register("tracing:after_query", afterStatement("SELECT")),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", beforeStatement("UPDATE")),
+		cb.Update().After("gorm:update").Register("tracing:after_update", afterStatement("UPDATE")),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", beforeStatement("DELETE")),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", afterStatement("DELETE")),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", beforeStatement("SELECT")),
+		cb.Row().After("gorm:row").Register("tracing:after_row", afterStatement("SELECT")),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", beforeStatement("RAW")),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", afterStatement("RAW")),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// beforeStatement starts the span of a statement
+func beforeStatement(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil || !trace.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+		_, span := Tracer().Start(ctx, operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemNameMySQL, semconv.DBOperationName(operation)),
+		)
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+// afterStatement ends the span of a statement with its table, its SQL without
+// the bound values, and its outcome. Record not found is not a failure.
+func afterStatement(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, _ := db.InstanceGet(spanKey)
+		span, ok := value.(trace.Span)
+		if !ok {
+			return
+		}
+		// The statement may be reused by a chained query that runs again
+		db.InstanceSet(spanKey, nil)
+
+		if db.Statement.Table != "" {
+			span.SetName(operation + " " + db.Statement.Table)
+			span.SetAttributes(semconv.DBCollectionName(db.Statement.Table))
+		}
+		span.SetAttributes(
+			semconv.DBQueryText(db.Statement.SQL.String()),
+			semconv.DBResponseReturnedRows(int(db.RowsAffected)),
+		)
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			span.RecordError(db.Error)
+			span.SetStatus(codes.Error, db.Error.Error())
+		}
+		span.End()
+	}
+}
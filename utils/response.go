@@ -31,6 +31,15 @@ func Created(c *gin.Context, data interface{}) {
 	})
 }
 
+// Accepted 请求已受理，等待后续处理
+func Accepted(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusAccepted, Response{
+		Code:    http.StatusAccepted,
+		Message: "accepted",
+		Data:    data,
+	})
+}
+
 // Error response
 func Error(c *gin.Context, statusCode int, message string) {
 	c.JSON(statusCode, Response{
@@ -50,7 +59,27 @@ func NotFound(c *gin.Context, message string) {
 	Error(c, http.StatusNotFound, message)
 }
 
+// Unauthorized 未认证
+func Unauthorized(c *gin.Context, message string) {
+	Error(c, http.StatusUnauthorized, message)
+}
+
+// Forbidden 无权限
+func Forbidden(c *gin.Context, message string) {
+	Error(c, http.StatusForbidden, message)
+}
+
 // InternalError 服务器内部错误
 func InternalError(c *gin.Context, message string) {
 	Error(c, http.StatusInternalServerError, message)
 }
+
+// TooManyRequests 请求过于频繁
+func TooManyRequests(c *gin.Context, message string) {
+	Error(c, http.StatusTooManyRequests, message)
+}
+
+// ServiceUnavailable 服务暂不可用（启动中或停机中）
+func ServiceUnavailable(c *gin.Context, message string) {
+	Error(c, http.StatusServiceUnavailable, message)
+}
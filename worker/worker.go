@@ -0,0 +1,163 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a named task run periodically by the Manager
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// JobStatus is the state of a job as reported by the readiness probe
+type JobStatus struct {
+	Name                string    `json:"name"`
+	Running             bool      `json:"running"` // a run is in progress
+	Runs                int       `json:"runs"`
+	LastRun             time.Time `json:"last_run,omitempty"` // when the last run finished
+	LastError           string    `json:"last_error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+}
+
+// Manager runs registered jobs in background goroutines
+type Manager struct {
+	jobs   []Job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	state  string // idle, running or stopped
+	status map[string]*JobStatus
+}
+
+// NewManager creates an empty job manager
+func NewManager() *Manager {
+	return &Manager{state: "idle", status: make(map[string]*JobStatus)}
+}
+
+// Register adds a job; jobs registered after Start are not run
+func (m *Manager) Register(job Job) {
+	m.jobs = append(m.jobs, job)
+
+	m.mu.Lock()
+	m.status[job.Name] = &JobStatus{Name: job.Name}
+	m.mu.Unlock()
+}
+
+// Start launches every registered job. Each job runs once immediately and then on its interval.
+func (m *Manager) Start(ctx context.Context) {
+	m.mu.Lock()
+	m.state = "running"
+	m.mu.Unlock()
+
+	ctx, m.cancel = context.WithCancel(ctx)
+	for _, job := range m.jobs {
+		m.wg.Add(1)
+		go m.loop(ctx, job)
+	}
+}
+
+// Stop cancels all jobs and waits for running ones to return
+func (m *Manager) Stop() {
+	m.Shutdown(context.Background())
+}
+
+// Shutdown cancels all jobs and waits for running ones to return, giving up when
+// ctx is done. Jobs still running then are left to finish on their own.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.state = "stopped"
+	m.mu.Unlock()
+
+	if m.cancel != nil {
+		m.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status returns the manager's state and the status of every job in registration order
+func (m *Manager) Status() (string, []JobStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]JobStatus, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, *m.status[job.Name])
+	}
+	return m.state, jobs
+}
+
+// Check reports the jobs' status for the readiness probe. It fails only when the
+// jobs are not running; a failing job is reported but does not stop the API from
+// serving.
+func (m *Manager) Check(ctx context.Context) (interface{}, error) {
+	state, jobs := m.Status()
+	details := map[string]interface{}{"state": state, "jobs": jobs}
+	if state != "running" {
+		return details, errors.New("background jobs are " + state)
+	}
+	return details, nil
+}
+
+// loop runs a job until ctx is cancelled
+func (m *Manager) loop(ctx context.Context, job Job) {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.record(job.Name, true, nil)
+		err := job.Run(ctx)
+		m.record(job.Name, false, err)
+		if err != nil {
+			log.Printf("Job %s failed: %v", job.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// record updates a job's status when a run starts or finishes
+func (m *Manager) record(name string, starting bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := m.status[name]
+	if starting {
+		status.Running = true
+		return
+	}
+	status.Running = false
+	status.Runs++
+	status.LastRun = time.Now()
+	if err != nil {
+		status.LastError = err.Error()
+		status.ConsecutiveFailures++
+	} else {
+		status.LastError = ""
+		status.ConsecutiveFailures = 0
+	}
+}